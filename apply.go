@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+func runApply(args []string) {
+	if err := applyPlanCommand(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitUpToDate)
+		}
+		logErrorf("apply: %v", err)
+		os.Exit(exitFatal)
+	}
+}
+
+// applyPlanCommand implements `apply`: it merges the version changes of a previously
+// written .updated file back into the original file.
+func applyPlanCommand(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.StringVar(&filename, "file", "helmwave.yml.tpl", "path to helmwave yaml file")
+	planFile := fs.String("plan", "", "updated file to apply (defaults to FILE.updated)")
+	dryRun := fs.Bool("dry-run", false, "print the merged file to stdout instead of writing it")
+	fs.StringVar(&auditLog, "audit-log", "", "append every applied update as a JSON line to this audit log")
+	fs.StringVar(&stateFile, "state-file", "", "record the applied updates in this state file (see -cooldown)")
+	addLoggingFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyLoggingFlags(fs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *planFile == "" {
+		*planFile = filename + ".updated"
+	}
+
+	out, updates, err := mergePlan(filename, *planFile)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		logInfof("%s already matches %s; nothing to apply", filename, *planFile)
+		return nil
+	}
+	for _, u := range updates {
+		logInfof("applying release %s: %s -> %s", u.ID(), u.FromVersion, u.ToVersion)
+	}
+	if *dryRun {
+		fmt.Print(out)
+		return nil
+	}
+	if err := writeUpdated(filename, out, true); err != nil {
+		return err
+	}
+	// applied plans are recorded like update runs, so rollback can undo them and the
+	// cooldown counts from here
+	auditEdits(filename, updates)
+	s, err := loadState(statePath())
+	if err == nil {
+		err = saveState(statePath(), s, time.Now(), updates)
+	}
+	if err != nil {
+		logWarnf("⚠️ failed to write state file %s: %v", statePath(), err)
+	}
+	return nil
+}
+
+// mergePlan edits the version lines of file to the versions pinned in planFile and returns
+// the result together with the updates it applies; the result is "" when file already
+// matches planFile. The edit must reproduce planFile exactly: any other difference means
+// the file changed after the plan was written (or the plan was edited by hand) and is an
+// error, so nothing but version lines is ever merged.
+func mergePlan(file, planFile string) (string, []releaseUpdate, error) {
+	in, hw, err := updater.ReadIncluded(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read helmwave: %w", err)
+	}
+	helmwaveIncludes = in
+	data := []byte(in.Text)
+	planData, plan, err := readPlan(in, planFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	versions := make(map[string]string)
+	chartVersions := make(map[string]string)
+	chartNames := make(map[string]string)
+	var updates []releaseUpdate
+	for _, d := range updater.CompareVersions(&hw, &plan) {
+		if d.A == nil || d.B == nil {
+			return "", nil, fmt.Errorf("release %s is only in one of %s and %s; re-run the update", d.Release, file, planFile)
+		}
+		release, err := findRelease(&hw, d.Release)
+		if err != nil {
+			return "", nil, err
+		}
+		u := updater.NewReleaseUpdate(release, d.B.Version, "", "")
+
+		// git-sourced charts carry their version as the ref inside chart.name, digest-pinned
+		// OCI charts their digest; any other chart name change is not a version change
+		gitA, okA := updater.ParseGitChart(d.A.Name)
+		gitB, okB := updater.ParseGitChart(d.B.Name)
+		refA, digestA := updater.SplitOCIDigest(d.A.Name)
+		refB, digestB := updater.SplitOCIDigest(d.B.Name)
+		switch {
+		case d.A.Name == d.B.Name:
+		case okA && okB && gitA.Repo == gitB.Repo && gitA.Path == gitB.Path:
+			chartNames[release.ID()] = d.B.Name
+			u.FromVersion, u.ToVersion = gitA.Ref, gitB.Ref
+			u.Importance = updater.UpdateImportance(gitA.Ref, gitB.Ref, "", "")
+		case refA == refB && digestB != digestA:
+			chartNames[release.ID()] = d.B.Name
+			u.Digest = digestB
+		default:
+			return "", nil, fmt.Errorf("release %s: chart changed from %s to %s; re-run the update", d.Release, d.A.Name, d.B.Name)
+		}
+		if d.A.Version != d.B.Version {
+			versions[release.ID()] = d.B.Version
+			chartVersions[d.B.Name] = d.B.Version
+		}
+		updates = append(updates, u)
+	}
+
+	out := updater.UpdateText(data, versions, chartVersions)
+	out = updater.UpdateChartNames([]byte(out), chartNames)
+	if plan.Version != hw.Version {
+		out = updater.UpdateTopLevelScalar([]byte(out), "version", plan.Version)
+	}
+	out = adoptAnnotations(out, string(planData))
+	if out != string(planData) {
+		line, got, want := firstDifferentLine(out, string(planData))
+		return "", nil, fmt.Errorf("%s diverged from %s outside version lines (line %d: %q, plan has %q); re-run the update",
+			file, planFile, line, got, want)
+	}
+	if out == string(data) {
+		return "", nil, nil
+	}
+	if plan.Version != hw.Version {
+		logInfof("applying helmwave version: %s -> %s", hw.Version, plan.Version)
+	}
+	return out, updates, nil
+}
+
+// readPlan reads planFile as the plan for the main file of in, and the .updated copy of
+// each included file that has one, and inlines them like in.
+func readPlan(in *updater.Included, planFile string) ([]byte, Helmwave, error) {
+	data, err := os.ReadFile(planFile)
+	if err != nil {
+		return nil, Helmwave{}, err
+	}
+	if len(in.Files) > 1 {
+		plans := map[string]string{in.Files[0]: string(data)}
+		for _, file := range in.Files[1:] {
+			if plan, err := os.ReadFile(file + ".updated"); err == nil {
+				plans[file] = string(plan)
+			}
+		}
+		text, err := in.Join(plans)
+		if err != nil {
+			return nil, Helmwave{}, fmt.Errorf("%s does not match the included files; re-run the update: %w", planFile, err)
+		}
+		data = []byte(text)
+	}
+	plan, err := updater.Parse(data)
+	if err != nil {
+		return nil, Helmwave{}, fmt.Errorf("%s: %w", planFile, err)
+	}
+	return data, plan, nil
+}
+
+// firstDifferentLine returns the 1-based number and contents of the first line where a and
+// b differ. A missing line is returned as "".
+func firstDifferentLine(a, b string) (int, string, string) {
+	la, lb := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; i < max(len(la), len(lb)); i++ {
+		var x, y string
+		if i < len(la) {
+			x = la[i]
+		}
+		if i < len(lb) {
+			y = lb[i]
+		}
+		if x != y {
+			return i + 1, x, y
+		}
+	}
+	return 0, "", ""
+}
+
+// adoptAnnotations takes the -annotate and -app-version-comment comments of plan over onto
+// out, so an annotated plan merges like a plain one. Only lines that differ from the plan by
+// those comments alone are taken over.
+func adoptAnnotations(out, plan string) string {
+	merged, planned := strings.Split(out, "\n"), strings.Split(plan, "\n")
+	if len(merged) != len(planned) {
+		return out
+	}
+	for i := range merged {
+		if merged[i] != planned[i] && updater.StripComments(planned[i]) == updater.StripComments(merged[i]) {
+			merged[i] = planned[i]
+		}
+	}
+	return strings.Join(merged, "\n")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// auditLog is the path of the append-only JSONL audit log (-audit-log); empty disables auditing.
+var auditLog string
+
+// auditEntry is a single line of the audit log: one applied version change.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	RunID      string    `json:"runId"`
+	File       string    `json:"file"`
+	Output     string    `json:"output"`
+	Release    string    `json:"release"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Context    string    `json:"context,omitempty"`
+	Chart      string    `json:"chart"`
+	OldVersion string    `json:"oldVersion"`
+	NewVersion string    `json:"newVersion"`
+	User       string    `json:"user,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Command    string    `json:"command"`
+}
+
+// newRunID returns a sortable identifier for one invocation, e.g. 20240601T101500Z-3f2a9c1b.
+func newRunID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// appendAuditLog appends one JSON line per update to path.
+func appendAuditLog(path, runID, file, output string, updates []releaseUpdate) error {
+	if path == "" || len(updates) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now().UTC()
+	who := currentUser()
+	host, _ := os.Hostname()
+	command := strings.Join(os.Args, " ")
+	enc := json.NewEncoder(f)
+	recorded := 0
+	for _, u := range updates {
+		if u.File != "" {
+			// rollback edits the helmwave file only; Chart.yaml changes are left to git
+			continue
+		}
+		entry := auditEntry{
+			Time:       now,
+			RunID:      runID,
+			File:       file,
+			Output:     output,
+			Release:    u.Release,
+			Namespace:  u.Namespace,
+			Context:    u.Context,
+			Chart:      u.Chart,
+			OldVersion: u.FromVersion,
+			NewVersion: u.ToVersion,
+			User:       who,
+			Host:       host,
+			Command:    command,
+		}
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+		recorded++
+	}
+	logInfof("recorded %d updates in audit log %s (run %s)", recorded, path, runID)
+	return nil
+}
+
+// fileUpdates are the updates made in one file.
+type fileUpdates struct {
+	file    string
+	updates []releaseUpdate
+}
+
+// auditEdits appends updates rewritten into file to the audit log as one run, each entry
+// naming the file that defines its release (see updatesByFile).
+func auditEdits(file string, updates []releaseUpdate) {
+	runID := newRunID()
+	for _, g := range updatesByFile(file, updates) {
+		if err := appendAuditLog(auditLog, runID, g.file, g.file, g.updates); err != nil {
+			logWarnf("⚠️ failed to append audit log %s: %v", auditLog, err)
+			return
+		}
+	}
+}
+
+// updatesByFile groups updates by the file defining their release: file itself, or a file it
+// includes through readFile (see helmwaveIncludes).
+func updatesByFile(file string, updates []releaseUpdate) []fileUpdates {
+	if helmwaveIncludes == nil || len(helmwaveIncludes.Files) == 1 {
+		return []fileUpdates{{file: file, updates: updates}}
+	}
+	ids := make([]string, len(updates))
+	for i, u := range updates {
+		ids[i] = u.ID()
+	}
+	lines := updater.ReleaseLines([]byte(helmwaveIncludes.Text), ids)
+	var groups []fileUpdates
+	for _, u := range updates {
+		f := file
+		if line, ok := lines[u.ID()]; ok {
+			f, _ = helmwaveIncludes.Locate(line)
+		}
+		i := slices.IndexFunc(groups, func(g fileUpdates) bool { return g.file == f })
+		if i < 0 {
+			groups = append(groups, fileUpdates{file: f})
+			i = len(groups) - 1
+		}
+		groups[i].updates = append(groups[i].updates, u)
+	}
+	return groups
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
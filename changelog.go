@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// changelogFile is the Markdown file that gets a dated section per run with applied updates (-changelog).
+var changelogFile string
+
+// appendChangelog appends a section listing updates to path, e.g.
+//
+//	## 2024-06-01 10:15 UTC — helmwave.yml.tpl
+//
+//	- nginx@web: 15.0.0 → 15.1.0 (minor)
+func appendChangelog(path, file string, now time.Time, updates []releaseUpdate) error {
+	if path == "" || len(updates) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "## %s — %s\n\n", now.UTC().Format("2006-01-02 15:04 MST"), file)
+	for _, u := range updates {
+		name := u.ID()
+		if u.File != "" {
+			name += " (" + u.Chart + " in " + u.File + ")"
+		}
+		fmt.Fprintf(&sb, "- %s: %s → %s (%s)\n", name, u.FromVersion, u.ToVersion, u.Importance)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(icons(sb.String())); err != nil {
+		return err
+	}
+	logInfof("appended %d updates to changelog %s", len(updates), path)
+	return nil
+}
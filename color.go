@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// colorMode is one of auto, always, never (-color / -no-color)
+var colorMode = "auto"
+var noColor bool
+
+var colorEnabled bool
+
+// noEmoji replaces icons in messages with plain text (-no-emoji, or noEmoji: true in the config)
+var noEmoji bool
+
+// plainIcons maps the icons used in messages to plain text; the warning sign is dropped
+// because log records carry their level anyway.
+var plainIcons = strings.NewReplacer("⚠️ ", "", "⚠️", "", "→", "->")
+
+// icons returns s with its icons replaced by plain text when -no-emoji is set.
+func icons(s string) string {
+	if !noEmoji {
+		return s
+	}
+	return plainIcons.Replace(s)
+}
+
+// setupColor decides once whether ANSI colors are written to stdout.
+// Explicit -color=always/never wins; otherwise NO_COLOR (https://no-color.org) and
+// a non-terminal stdout disable colors.
+func setupColor() error {
+	mode := strings.ToLower(strings.TrimSpace(colorMode))
+	if noColor {
+		mode = "never"
+	}
+	switch mode {
+	case "always":
+		colorEnabled = true
+	case "never":
+		colorEnabled = false
+	case "", "auto":
+		colorEnabled = os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	default:
+		return fmt.Errorf("unknown color mode %q (expected auto, always or never)", colorMode)
+	}
+	return nil
+}
+
+// colorize wraps s in the given ANSI color when colors are enabled
+func colorize(color, s string) string {
+	if !colorEnabled || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
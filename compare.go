@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// runCompare implements `compare FILE_A FILE_B`: it lists releases whose charts are pinned
+// differently, e.g. between staging and production files. It exits with
+// exitUpdatesAvailable when the files differ.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	ignoreMissing := fs.Bool("ignore-missing", false, "do not report releases that exist in only one of the files")
+	addLoggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: helmwave-updater compare [flags] FILE_A FILE_B")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if err := applyLoggingFlags(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitFatal)
+	}
+
+	n, err := compareFiles(os.Stdout, fs.Arg(0), fs.Arg(1), *ignoreMissing)
+	if err != nil {
+		logErrorf("compare: %v", err)
+		os.Exit(exitFatal)
+	}
+	if n > 0 {
+		os.Exit(exitUpdatesAvailable)
+	}
+}
+
+// compareFiles writes a table of the releases pinned differently in pathA and pathB and
+// returns how many there are.
+func compareFiles(out io.Writer, pathA, pathB string, ignoreMissing bool) (int, error) {
+	_, a, err := updater.ReadFile(pathA)
+	if err != nil {
+		return 0, err
+	}
+	_, b, err := updater.ReadFile(pathB)
+	if err != nil {
+		return 0, err
+	}
+
+	var diffs []updater.VersionDiff
+	for _, d := range updater.CompareVersions(&a, &b) {
+		if ignoreMissing && (d.A == nil || d.B == nil) {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	if len(diffs) == 0 {
+		logInfof("%s and %s pin the same chart versions", pathA, pathB)
+		return 0, nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", tr("RELEASE"), pathA, pathB)
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Release, pinned(d.A, d.B), pinned(d.B, d.A))
+	}
+	return len(diffs), w.Flush()
+}
+
+// pinned describes chart c for the compare table; the chart name is only shown when it
+// differs from the other side.
+func pinned(c, other *updater.Chart) string {
+	switch {
+	case c == nil:
+		return tr("(missing)")
+	case other != nil && other.Name == c.Name:
+		return orDash(c.Version)
+	}
+	return c.Name + " " + orDash(c.Version)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when present; -config points elsewhere.
+const defaultConfigFile = ".helmwave-updater.yml"
+
+var configFile string
+
+// fileConfig is the tool's own configuration, kept next to the helmwave file.
+type fileConfig struct {
+	// Sources maps release names to the version provider used for them.
+	Sources map[string]updater.ProviderConfig `yaml:"sources,omitempty"`
+	// NoEmoji is the config form of -no-emoji.
+	NoEmoji bool `yaml:"noEmoji,omitempty"`
+	// Repos sets default update policies per helm repository name.
+	Repos map[string]repoPolicy `yaml:"repos,omitempty"`
+	// PolicyCommand is the config form of -policy-command; the flag wins.
+	PolicyCommand string `yaml:"policyCommand,omitempty"`
+	// Hosts overrides -host-rate and -host-concurrency per host name.
+	Hosts map[string]hostLimitConfig `yaml:"hosts,omitempty"`
+}
+
+// repoPolicy is the config form of updater.Policy; minAge accepts days (7d) or Go durations.
+type repoPolicy struct {
+	Strategy string `yaml:"strategy,omitempty"`
+	MinAge   string `yaml:"minAge,omitempty"`
+}
+
+// repoPolicies are the parsed policies of config.Repos.
+var repoPolicies map[string]updater.Policy
+
+// config is the loaded configuration (empty when there is no file).
+var config fileConfig
+
+// policyFor returns the config policy of the helm repository a release's chart comes from.
+func policyFor(kind string, release Release) (updater.Policy, bool) {
+	if kind != providerHelm {
+		return updater.Policy{}, false
+	}
+	repoName, _, ok := updater.SplitRepoChart(release.Chart.Name)
+	if !ok {
+		return updater.Policy{}, false
+	}
+	p, ok := repoPolicies[repoName]
+	return p, ok
+}
+
+// loadConfig reads -config. A missing default file is fine; a missing explicit file is not.
+func loadConfig() error {
+	path := configFile
+	if path == "" {
+		path = defaultConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if configFile == "" && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var c fileConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	// entries are checked in name order, so a file with several mistakes always reports the same one first
+	for _, name := range sortedKeys(c.Sources) {
+		if src := c.Sources[name]; !slices.Contains(providerTypes(), strings.ToLower(src.Type)) {
+			return fmt.Errorf("config %s: source for release %q has unknown type %q (known: %s)", path, name, src.Type, strings.Join(providerTypes(), ", "))
+		}
+	}
+	policies := make(map[string]updater.Policy, len(c.Repos))
+	for _, name := range sortedKeys(c.Repos) {
+		rp := c.Repos[name]
+		p := updater.Policy{Strategy: strings.ToLower(strings.TrimSpace(rp.Strategy))}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("config %s: repo %q: %w", path, name, err)
+		}
+		if rp.MinAge != "" {
+			var d durationValue
+			if err := d.Set(rp.MinAge); err != nil {
+				return fmt.Errorf("config %s: repo %q: invalid minAge: %w", path, name, err)
+			}
+			p.MinAge = time.Duration(d)
+		}
+		policies[name] = p
+	}
+	for _, host := range sortedKeys(c.Hosts) {
+		if err := c.Hosts[host].validate(); err != nil {
+			return fmt.Errorf("config %s: host %q: %w", path, host, err)
+		}
+	}
+	config, repoPolicies = c, policies
+	resetHostLimits()
+	noEmoji = noEmoji || c.NoEmoji
+	if policyCommand == "" {
+		policyCommand = c.PolicyCommand
+	}
+	logDebugf("loaded config from %s (%d sources)", path, len(c.Sources))
+	return nil
+}
@@ -1,328 +1,507 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"go.opentelemetry.io/otel/attribute"
 	"helm.sh/helm/v4/pkg/cli"
 )
 
-// readHelmwave reads and unmarshals helmwave YAML file into structures.
-func readHelmwave(filename string) ([]byte, Helmwave, error) {
-	vlog("reading input file: %s", filename)
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, Helmwave{}, err
-	}
-	vlog("read %d bytes from %s", len(data), filename)
-	// Preprocess: remove `repositories:` section from the raw YAML text before unmarshalling.
-	// The file may contain templating expressions (e.g. {{ env "..." }}) which break strict YAML parsing.
-	// We must NOT modify the on-disk file; instead, strip the repositories block only from the in-memory bytes
-	// used for YAML unmarshalling.
-	// remove repositories and registries sections from in-memory text before parsing
-	processed := removeTopLevelSection(data, "repositories")
-	processed = removeTopLevelSection(processed, "registries")
+// timeout bounds the whole run (-timeout); 0 disables it
+var timeout time.Duration
 
-	var hw Helmwave
-	if err := yaml.Unmarshal(processed, &hw); err != nil {
-		return nil, Helmwave{}, err
+// writeOutput atomically writes content to outFile and logs result.
+func writeOutput(outFile, out string) error {
+	if err := writeFileAtomic(outFile, []byte(out), 0644); err != nil {
+		return err
 	}
-	return data, hw, nil
+	logDebugf("wrote %d bytes to %s", len(out), outFile)
+	logInfof("Wrote updated file: %s", outFile)
+	return nil
 }
 
-// removeTopLevelSection removes a top-level YAML section (including its indented block)
-// by name from the provided byte slice and returns the processed bytes.
-// It is a conservative line-based stripper: it finds the line that starts with the
-// section key followed by ':' and removes that line and all following lines that are
-// indented (have greater indent) until a line with indent <= sectionIndent is found.
-func removeTopLevelSection(input []byte, section string) []byte {
-	text := string(input)
-	lines := strings.Split(text, "\n")
-	out := make([]string, 0, len(lines))
-
-	skip := false
-	sectionIndent := 0
-	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-		trimmed := strings.TrimLeft(line, " ")
-		indent := len(line) - len(trimmed)
-
-		if !skip {
-			// detect top-level section line like "repositories:" possibly with leading/trailing spaces
-			if strings.HasPrefix(strings.TrimSpace(line), section+":") {
-				skip = true
-				sectionIndent = indent
-				// skip this line (do not append)
-				continue
-			}
-			out = append(out, line)
-		} else {
-			// currently skipping: continue skipping while indent > sectionIndent
-			if strings.TrimSpace(line) == "" {
-				// preserve empty lines inside skipped block (still skip them)
-				continue
-			}
-			if indent > sectionIndent {
-				// still inside the section block -> skip
-				continue
-			}
-			// reached a line that is at same or less indent -> stop skipping and include this line
-			skip = false
-			out = append(out, line)
-		}
+// writeUpdated writes out, the edited file, with writeEdited. When the file includes others,
+// out is split back into them first and every included file with an edited line is written
+// the same way.
+func writeUpdated(file, out string, inplace bool) error {
+	if helmwaveIncludes == nil || len(helmwaveIncludes.Files) == 1 {
+		_, err := writeEdited(file, out, inplace)
+		return err
 	}
-
-	return []byte(strings.Join(out, "\n"))
-}
-
-// updateFileText returns edited file content (string) with versions replaced according to versionMap.
-func updateFileText(original []byte, versionMap map[string]string, chartVersionMap map[string]string) string {
-	text := string(original)
-	lines := strings.Split(text, "\n")
-
-	for relName, newVer := range versionMap {
-		vlog("will update release %s -> %s in file text", relName, newVer)
-		inRelease := false
-		inChart := false
-		var chartIndent int
-
-		for i := 0; i < len(lines); i++ {
-			line := lines[i]
-			trimmed := strings.TrimSpace(line)
-			indent := len(line) - len(strings.TrimLeft(line, " "))
-
-			if strings.HasPrefix(trimmed, "- name:") {
-				namePart := strings.TrimSpace(strings.TrimPrefix(trimmed, "- name:"))
-				if idx := strings.Index(namePart, "#"); idx >= 0 {
-					namePart = strings.TrimSpace(namePart[:idx])
-				}
-				namePart = strings.Trim(namePart, "'\"")
-				if namePart == relName {
-					inRelease = true
-					inChart = false
-					continue
-				}
-				if inRelease {
-					inRelease = false
-					inChart = false
-				}
-			}
-
-			if !inRelease {
-				continue
-			}
-
-			if strings.HasPrefix(trimmed, "chart:") {
-				if strings.TrimSpace(trimmed) == "chart:" {
-					inChart = true
-					chartIndent = indent
-					continue
-				}
-			}
-
-			if inChart {
-				if indent <= chartIndent && !strings.HasPrefix(trimmed, "version:") {
-					inChart = false
-					continue
-				}
-
-				if strings.HasPrefix(trimmed, "version:") {
-					after := strings.TrimSpace(strings.TrimPrefix(trimmed, "version:"))
-					comment := ""
-					if idx := strings.Index(after, "#"); idx >= 0 {
-						comment = " " + strings.TrimSpace(after[idx:])
-					}
-					origVal := strings.TrimSpace(after)
-					origVal = strings.TrimRight(origVal, "# ")
-					origVal = strings.Trim(origVal, "'\"")
-
-					if origVal == newVer {
-						vlog("existing version for release %s equals target %s; skipping file edit", relName, newVer)
-						inChart = false
-						inRelease = false
-						// continue scanning for other occurrences of the same release later in the file
-						continue
-					}
-					useQuotes := strings.Contains(after, "\"") || strings.Contains(after, "'")
-					var valStr string
-					if useQuotes {
-						valStr = fmt.Sprintf("\"%s\"", newVer)
-					} else {
-						valStr = newVer
-					}
-					newLine := strings.Repeat(" ", indent) + "version: " + valStr + comment
-					vlog("replacing line %d for release %s: %q -> %q", i+1, relName, lines[i], newLine)
-					lines[i] = newLine
-					inChart = false
-					inRelease = false
-					// continue scanning to update possible additional occurrences of the same release
-					continue
-				}
-			}
-		}
+	files, err := helmwaveIncludes.Split(out)
+	if err != nil {
+		return err
 	}
-
-	// Second pass: update top-level anchors (for example ".options: &options") that contain a chart: block
-	// We look for top-level keys that start with '.' (like .options) and inside their chart block
-	// try to match chart.name and update chart.version according to chartVersionMap.
-	for chartFullName, newVer := range chartVersionMap {
-		inAnchor := false
-		inChart := false
-		var anchorIndent int
-		var foundChartName string
-
-		for i := 0; i < len(lines); i++ {
-			line := lines[i]
-			trimmed := strings.TrimSpace(line)
-			indent := len(line) - len(strings.TrimLeft(line, " "))
-
-			// detect top-level anchor like ".options: &options" or ".options:"
-			if !inAnchor && strings.HasPrefix(trimmed, ".") && strings.Contains(trimmed, ":") {
-				inAnchor = true
-				anchorIndent = indent
-				inChart = false
-				foundChartName = ""
-				continue
-			}
-
-			if inAnchor {
-				// if we hit another top-level key (same or smaller indent) that is not part of chart, exit anchor
-				if indent <= anchorIndent && !strings.HasPrefix(trimmed, "chart:") && !strings.HasPrefix(trimmed, "#") {
-					inAnchor = false
-					inChart = false
-					foundChartName = ""
-					continue
-				}
-
-				if strings.HasPrefix(trimmed, "chart:") {
-					if strings.TrimSpace(trimmed) == "chart:" {
-						inChart = true
-						// chartIndent equals current indent
-						// continue to next lines to find name/version
-						continue
-					}
-				}
-
-				if inChart {
-					// if we left chart block
-					if indent <= anchorIndent && !strings.HasPrefix(trimmed, "name:") && !strings.HasPrefix(trimmed, "version:") {
-						inChart = false
-						continue
-					}
-
-					if strings.HasPrefix(trimmed, "name:") {
-						nameVal := strings.TrimSpace(strings.TrimPrefix(trimmed, "name:"))
-						nameVal = strings.Trim(nameVal, "'\"")
-						// store found chart name to later compare when we see version
-						foundChartName = nameVal
-						continue
-					}
-
-					if strings.HasPrefix(trimmed, "version:") {
-						if foundChartName == chartFullName {
-							after := strings.TrimSpace(strings.TrimPrefix(trimmed, "version:"))
-							comment := ""
-							if idx := strings.Index(after, "#"); idx >= 0 {
-								comment = " " + strings.TrimSpace(after[idx:])
-							}
-							origVal := strings.TrimSpace(after)
-							origVal = strings.TrimRight(origVal, "# ")
-							origVal = strings.Trim(origVal, "'\"")
-
-							if origVal == newVer {
-								// already up-to-date
-								inChart = false
-								inAnchor = false
-								foundChartName = ""
-								continue
-							}
-							useQuotes := strings.Contains(after, "\"") || strings.Contains(after, "'")
-							var valStr string
-							if useQuotes {
-								valStr = fmt.Sprintf("\"%s\"", newVer)
-							} else {
-								valStr = newVer
-							}
-							newLine := strings.Repeat(" ", indent) + "version: " + valStr + comment
-							vlog("replacing anchor line %d for chart %s: %q -> %q", i+1, chartFullName, lines[i], newLine)
-							lines[i] = newLine
-							inChart = false
-							inAnchor = false
-							foundChartName = ""
-							continue
-						}
-					}
-				}
-			}
+	for _, file := range helmwaveIncludes.Files {
+		content, ok := files[file]
+		if !ok {
+			continue
+		}
+		if _, err := writeEdited(file, content, inplace); err != nil {
+			return err
 		}
 	}
-
-	return strings.Join(lines, "\n")
+	return nil
 }
 
-// writeOutput writes content to outFile and logs result.
-func writeOutput(outFile, out string) error {
-	if err := os.WriteFile(outFile, []byte(out), 0644); err != nil {
+// writeFileAtomic writes data to a temp file in the target directory and renames it over path,
+// so an interrupted run never leaves a half-written file. An existing file keeps its mode.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
 		return err
 	}
-	vlog("wrote %d bytes to %s", len(out), outFile)
-	log.Printf("Wrote updated file: %s", outFile)
 	return nil
 }
+
+// signalContext returns a context cancelled on SIGINT/SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 func main() {
+	// defaults for subcommands; reconfigured below once flags are parsed
+	_ = setupLogging()
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "version":
 			fmt.Println(version)
 			return
 		case "self-update":
-			runSelfUpdate(version)
+			runSelfUpdate(version, os.Args[2:])
+			return
+		case "rollback":
+			runRollback(os.Args[2:])
+			return
+		case "set":
+			runSet(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		case "apply":
+			runApply(os.Args[2:])
+			return
+		case "deps":
+			runDeps(os.Args[2:])
 			return
 		}
 	}
 
 	flag.StringVar(&filename, "file", "helmwave.yml.tpl", "path to helmwave yaml file")
+	flag.StringVar(&inputFormat, "format", inputFormat, "format of -file: helmwave, flux (HelmRelease and HelmRepository manifests), helmfile or argocd (Application manifests)")
+	flag.StringVar(&configFile, "config", "", "path to the helmwave-updater config file (default "+defaultConfigFile+" when present)")
 	flag.BoolVar(&inplace, "inplace", false, "modify the original file instead of creating a .updated copy")
-	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
+	flag.BoolVar(&annotate, "annotate", false, "append a \"# was <old version> (updated <date>)\" comment to every changed version line")
+	flag.BoolVar(&appVersionComments, "app-version-comment", false, "write a \"# appVersion: <appVersion>\" comment on every release's chart version line")
+	flag.BoolVar(&versionVars, "version-vars", false, "update the .env variable or versions file key a templated chart version reads ({{ env \"X\" }}, {{ get \"key\" (readFile \"versions.yaml\" | fromYaml) }}) instead of skipping the release")
+	flag.StringVar(&versionEnvFile, "version-env-file", "", "the .env file behind {{ env \"X\" }} versions for -version-vars (default .env next to -file)")
+	addLoggingFlags(flag.CommandLine)
 	flag.BoolVar(&noRepoUpdate, "no-repo-update", false, "skip helm repo update before checking versions")
-	flag.Parse()
+	flag.StringVar(&tagsExport, "tags-export", tagsExport, "which tags of updated releases to export: last, first or all")
+	flag.StringVar(&tagsTemplate, "tags-template", tagsTemplate, "Go template for the export line (fields: .Tags, .Releases; func: join)")
+	flag.BoolVar(&noTagsExport, "no-tags-export", false, "do not print the HELMWAVE_TAGS export line")
+	flag.BoolVar(&listMode, "list", false, "print a table of every release (version, latest, appVersion, status, tags) instead of writing the updated file")
+	flag.BoolVar(&outdatedMode, "outdated", false, "print only outdated releases, major updates and the furthest behind first, instead of writing the updated file")
+	flag.StringVar(&listSort, "list-sort", listSort, "order of the -list table: file, name or staleness")
+	printSchema := flag.Bool("report-schema", false, "print the JSON Schema of the -report-json output and exit")
+	flag.StringVar(&reportJSON, "report-json", "", "write the run's updates and skipped/failed releases (with reason codes) as JSON to this file, - for stdout")
+	flag.StringVar(&metricsTextfile, "metrics-textfile", "", "write staleness gauges in the Prometheus text format to this file (for node_exporter's textfile collector, e.g. /var/lib/node_exporter/helmwave.prom)")
+	flag.StringVar(&metricsPush, "metrics-push", "", "push staleness gauges to this Prometheus Pushgateway URL (job "+metricsJob+" unless the URL has a /metrics/job/ path)")
+	flag.StringVar(&statsdAddr, "statsd", "", "send outdated counts by importance and the run duration to this StatsD/DogStatsD agent (host:port, UDP)")
+	flag.StringVar(&statsdFormat, "statsd-format", statsdFormat, "metric format for -statsd: statsd (importance in the name) or dogstatsd (tags)")
+	flag.StringVar(&statsdPrefix, "statsd-prefix", statsdPrefix, "prefix of the -statsd metric names")
+	flag.StringVar(&statsdTags, "statsd-tags", "", "extra tags for every -statsd metric with -statsd-format dogstatsd (comma-separated key:value)")
+	flag.StringVar(&envFile, "env-file", "", "write HELMWAVE_TAGS and UPDATED_RELEASES counters to this dotenv file")
+	flag.StringVar(&auditLog, "audit-log", "", "append every applied update as a JSON line to this audit log")
+	flag.StringVar(&stateFile, "state-file", "", "record when each release was last bumped in this JSON file (default "+defaultStateFile+" with -cooldown)")
+	flag.Var((*durationValue)(&cooldown), "cooldown", "do not bump a release again within this window after its last bump (e.g. 7d or 12h); 0 disables")
+	flag.BoolVar(&allowLicenseChange, "allow-license-change", false, "update charts whose new version declares another license (they are held back with a warning by default)")
+	flag.StringVar(&policyCommand, "policy-command", "", "shell command deciding every proposed update: it reads the update as JSON on stdin and prints allow, deny or hold (e.g. an opa eval query)")
+	flag.BoolVar(&releaseNotes, "release-notes", false, "attach the GitHub release notes of the application versions an update skips over to the report (the chart's home or sources must point to the project; uses GITHUB_TOKEN if set)")
+	flag.StringVar(&outputFormat, "output", outputFormat, "what to print to stdout: text (the update report), yaml (the report as YAML), template (the result rendered with -template) or patch (the edits as a unified diff, instead of writing files)")
+	flag.BoolVar(&showDiff, "diff", false, "print the edits as a diff after the report; on a terminal only the changed words are colored")
+	flag.StringVar(&outputTemplateText, "template", "", "Go template for -output template over the JSON report fields (.Summary, .Updates, .Skipped, .Failed; funcs: join, upper, lower); @path reads it from a file")
+	flag.StringVar(&planFile, "planfile", "", "compare this helmwave planfile (e.g. .helmwave/planfile) with the file and the latest chart versions and report stale releases")
+	flag.StringVar(&changelogFile, "changelog", "", "append a dated Markdown section listing applied updates to this file (e.g. CHANGES.md)")
+	flag.BoolVar(&checkUpdate, "check-update", false, "check GitHub for a newer helmwave-updater release (cached for 24h, uses GITHUB_TOKEN if set)")
+	flag.Var(&setPins, "set", "pin a release to an explicit version (release=1.2.3, repeatable); skips the full update pass")
+	flag.StringVar(&helmwaveVersionMode, "helmwave-version", "", "check the file's top-level helmwave version against the latest helmwave release: warn or bump")
+	flag.BoolVar(&noFeatureCheck, "no-feature-check", false, "do not warn about helmwave file features unsupported by the pinned or installed helmwave version")
+	flag.BoolVar(&localDeps, "local-deps", false, "also update the dependency versions in the Chart.yaml of local charts")
+	flag.StringVar(&kubeContext, "context", "", "only check releases whose context option is this kube context")
+	flag.BoolVar(&noProgress, "no-progress", false, "do not show the progress line on a terminal stderr")
+	flag.BoolVar(&ignoreLocalCharts, "ignore-local-charts", false, "leave releases with local path charts (./charts/foo) out of the report entirely")
+	flag.IntVar(&limit, "limit", 0, "apply at most this many updates per run (see -limit-order); 0 applies all")
+	flag.StringVar(&limitOrder, "limit-order", limitOrder, "which updates -limit keeps: importance (major first) or oldest (longest since the last bump in the state file)")
+	flag.StringVar(&stepMode, "step", "", "propose one step instead of the latest version: next (next published version) or minor (newest patch of the next minor line)")
+	flag.IntVar(&workers, "workers", workers, "resolve up to this many releases concurrently; output stays in file order")
+	flag.BoolVar(&pinDigest, "pin-digest", false, "pin updated OCI charts by digest (chart.name gets @sha256:...); charts already pinned by digest always are")
+	flag.BoolVar(&noValidate, "no-validate", false, "with -set: do not check that the version exists in the repo index / registry")
+	flag.BoolVar(&strict, "strict", false, "fail without writing anything when a release cannot be resolved (missing index or chart, malformed chart.name)")
+	flag.BoolVar(&legacyExitCodes, "legacy-exit-codes", false, "exit 0 on any completed run (1 only on fatal errors) instead of the detailed exit codes")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "reuse indexes fetched within this duration (e.g. 1h) from the tool's own cache; 0 disables the cache")
+	flag.StringVar(&indexCacheDir, "cache-dir", "", "directory for the -cache-ttl index cache (default <user cache dir>/helmwave-updater/indexes)")
+	flag.DurationVar(&staleAfter, "stale-after", 0, "warn when a cached index used for resolution is older than this (e.g. 72h); 0 disables the warning")
+	flag.DurationVar(&autoRefreshOlderThan, "auto-refresh-older-than", 0, "with -no-repo-update, still re-fetch cached indexes older than this (e.g. 24h)")
+	flag.StringVar(&registryConfig, "registry-config", "", "helm registry credentials file for OCI lookups (default $HELM_REGISTRY_CONFIG or helm's registry/config.json)")
+	flag.DurationVar(&timeout, "timeout", 0, "abort the run (with -controller: each check) after this duration (e.g. 5m); 0 means no timeout")
+	flag.IntVar(&retries, "retries", retries, "retry failed index downloads and OCI tag listings this many times")
+	flag.DurationVar(&retryBackoff, "retry-backoff", retryBackoff, "initial delay between retries (doubles each attempt, with jitter)")
+	flag.Float64Var(&hostRate, "host-rate", 0, "send at most this many requests per second to each repository, registry or API host (e.g. 2 or 0.5); 0 is unlimited")
+	flag.IntVar(&hostConcurrency, "host-concurrency", 0, "keep at most this many requests in flight to each host; 0 is unlimited")
+	flag.StringVar(&indexDir, "index-dir", "", "load repo indexes from this directory (<repo>-index.yaml, <repo>.yaml or <repo>/index.yaml) instead of the helm cache")
+	flag.BoolVar(&offline, "offline", false, "forbid network access: no repo update, no OCI lookups, no update check")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the run to this file (inspect with go tool pprof)")
+	flag.StringVar(&memProfile, "memprofile", "", "write a heap profile at the end of the run to this file")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "export OpenTelemetry traces to this OTLP/HTTP endpoint (defaults to OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flag.BoolVar(&quiet, "quiet", false, "print only the final export line (safe for eval \"$(helmwave-updater ...)\")")
+	flag.StringVar(&colorMode, "color", colorMode, "colorize output: auto, always or never (auto honors NO_COLOR and disables colors when stdout is not a terminal)")
+	flag.BoolVar(&noColor, "no-color", false, "disable colored output (same as -color=never)")
+	flag.BoolVar(&controllerMode, "controller", false, "run in a cluster: check -git-repo or -configmap every -interval and publish the findings to -status-configmap")
+	flag.Var((*durationValue)(&controllerInterval), "interval", "with -controller: time between checks (e.g. 6h or 1d)")
+	flag.StringVar(&gitRepo, "git-repo", "", "with -controller: git repository to check out; -file is a path inside it")
+	flag.StringVar(&gitBranch, "git-branch", gitBranch, "with -controller: branch of -git-repo to check and to open pull requests against")
+	flag.StringVar(&sourceConfigMap, "configmap", "", "with -controller: [namespace/]name of a ConfigMap whose -file key holds the helmwave file")
+	flag.StringVar(&statusConfigMap, "status-configmap", statusConfigMap, "with -controller: [namespace/]name of the ConfigMap that receives the report and Events")
+	flag.StringVar(&healthAddr, "health-addr", "", "with -controller: serve /healthz and /readyz on this address (e.g. :8081)")
+	flag.BoolVar(&pprofEndpoints, "pprof", false, "with -controller and -health-addr: also serve the Go profiler under /debug/pprof/")
+	flag.BoolVar(&openPR, "open-pr", false, "with -controller and -git-repo: push updates to a helmwave-updater/<branch> branch and open a GitHub pull request (uses GITHUB_TOKEN)")
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	parseFlags(flag.CommandLine, os.Args[1:])
+	if err := applyLoggingFlags(flag.CommandLine); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	if *printSchema {
+		_, _ = os.Stdout.Write(reportSchema)
+		return
+	}
+	if err := setupColor(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	if err := loadConfig(); err != nil {
+		logErrorf("%v", err)
+		os.Exit(exitFatal)
+	}
+	if !slices.Contains(inputFormats, inputFormat) {
+		logErrorf("unknown -format %q (expected %s)", inputFormat, strings.Join(inputFormats, ", "))
+		os.Exit(exitFatal)
+	}
+	if stepMode != "" && stepMode != updater.StepNext && stepMode != updater.StepMinor {
+		logErrorf("unknown -step mode %q (expected next or minor)", stepMode)
+		os.Exit(exitFatal)
+	}
+	if statsdFormat != "statsd" && statsdFormat != "dogstatsd" {
+		logErrorf("unknown -statsd-format %q (expected statsd or dogstatsd)", statsdFormat)
+		os.Exit(exitFatal)
+	}
+	if limitOrder != "importance" && limitOrder != "oldest" {
+		logErrorf("unknown -limit-order %q (expected importance or oldest)", limitOrder)
+		os.Exit(exitFatal)
+	}
+	if err := validateOutput(); err != nil {
+		logErrorf("%v", err)
+		os.Exit(exitFatal)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	// the controller never ends on its own; it applies -timeout to every check instead
+	if timeout > 0 && !controllerMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stopProfiling, err := startProfiling()
+	if err != nil {
+		logErrorf("%v", err)
+		os.Exit(exitFatal)
+	}
+	shutdownTracing, err := initTracing(ctx)
+	if err != nil {
+		logWarnf("⚠️ failed to initialize tracing: %v", err)
+	}
+
+	if controllerMode {
+		err = runController(ctx)
+		stopProfiling()
+		if shutdownErr := shutdownTracing(context.WithoutCancel(ctx)); shutdownErr != nil {
+			logWarnf("⚠️ failed to flush traces: %v", shutdownErr)
+		}
+		if err != nil {
+			logErrorf("%v", err)
+			os.Exit(exitFatal)
+		}
+		return
+	}
+
+	if len(setPins) > 0 {
+		err = applyPins(ctx, setPins)
+		stopProfiling()
+		if shutdownErr := shutdownTracing(context.WithoutCancel(ctx)); shutdownErr != nil {
+			logWarnf("⚠️ failed to flush traces: %v", shutdownErr)
+		}
+		if err != nil {
+			logErrorf("set: %v", err)
+			os.Exit(exitFatal)
+		}
+		return
+	}
+
+	result, err := run(ctx, filename, inplace)
+	notifyNewVersion(ctx, os.Stderr, version)
+	stopProfiling()
+	// flush traces even when ctx was cancelled
+	if shutdownErr := shutdownTracing(context.WithoutCancel(ctx)); shutdownErr != nil {
+		logWarnf("⚠️ failed to flush traces: %v", shutdownErr)
+	}
+	if err != nil {
+		logErrorf("%v", err)
+		stop()
+		os.Exit(exitFatal)
+	}
+	stop()
+	os.Exit(exitCode(result))
+}
+
+// run executes a single check of file: read file → repo update → load indexes → process
+// releases → write output. The result is written over file when inplace, to file.updated
+// otherwise.
+func run(ctx context.Context, file string, inplace bool) (checkResult, error) {
+	ctx, span := startSpan(ctx, "run", attribute.String("file", file))
+	defer span.End()
+	resetRunCounters()
+	started := time.Now()
 
 	settings := cli.New()
 
-	vlog("starting: file=%s inplace=%v verbose=%v no-repo-update=%v", filename, inplace, verbose, noRepoUpdate)
-	vlog("helm settings: repo config=%s repo cache=%s namespace=%s", settings.RepositoryConfig, settings.RepositoryCache, settings.Namespace())
+	logDebugf("starting: file=%s inplace=%v verbose=%v no-repo-update=%v", file, inplace, verbose, noRepoUpdate)
+	logDebugf("helm settings: repo config=%s repo cache=%s namespace=%s", settings.RepositoryConfig, settings.RepositoryCache, settings.Namespace())
 
-	if !noRepoUpdate {
-		log.Println("running helm repo update...")
-		updateRepos(settings)
+	_, readSpan := startSpan(ctx, "readHelmwave")
+	data, hw, err := readInput(file)
+	spanError(readSpan, err)
+	readSpan.End()
+	if err != nil {
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to read %s: %w", inputFormat, err)
+	}
+	collectInsecureRepos(&hw)
+	dir := filepath.Dir(file)
+	vars := resolveVersionVars(dir, &hw)
+	// only repositories referenced by updatable releases are updated and loaded
+	wanted := referencedCharts(updatableReleases(&hw))
+	if localDeps {
+		addDependencyCharts(wanted, dir, updatableReleases(&hw))
 	}
 
-	indexes, err := loadIndexes(settings)
+	if offline || indexDir != "" {
+		logDebugf("skipping helm repo update (offline=%v index-dir=%q)", offline, indexDir)
+	} else if !noRepoUpdate {
+		logInfof("running helm repo update...")
+		updateRepos(ctx, settings, wanted)
+	} else if autoRefreshOlderThan > 0 {
+		refreshStaleRepos(ctx, settings, wanted)
+	}
+
+	indexes, err := loadIndexes(ctx, settings, wanted)
 	if err != nil {
-		log.Fatalf("failed to load repo file: %v", err)
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to load repo file: %w", err)
+	}
+
+	if bumps, err = loadState(statePath()); err != nil {
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to read state file: %w", err)
 	}
 
-	data, hw, err := readHelmwave(filename)
+	// releases are updated in memory; -planfile compares against the versions as written
+	source := Helmwave{Version: hw.Version, Releases: slices.Clone(hw.Releases)}
+	result, err := processReleases(ctx, file, &hw, indexes)
+	if err == nil {
+		err = strictError(result)
+	}
 	if err != nil {
-		log.Fatalf("failed to read helmwave: %v", err)
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("check aborted, no files written: %w", err)
+	}
+	if err := exportMetrics(ctx, file, result); err != nil {
+		logWarnf("⚠️ failed to export metrics: %v", err)
+	}
+	if err := emitStatsd(result, time.Since(started)); err != nil {
+		logWarnf("⚠️ failed to send statsd metrics: %v", err)
+	}
+	if listMode {
+		if err := printList(os.Stdout, result); err != nil {
+			spanError(span, err)
+			return checkResult{}, err
+		}
+		printSummary(os.Stdout, result)
+		return result, reportPlanfile(os.Stdout, file, &source, result)
+	}
+	if outdatedMode {
+		if err := printOutdated(os.Stdout, result); err != nil {
+			return result, err
+		}
+		return result, reportPlanfile(os.Stdout, file, &source, result)
+	}
+	updates := result.Updates()
+	if err := printTagsExport(updates); err != nil {
+		spanError(span, err)
+		return checkResult{}, err
 	}
 
-	processReleases(&hw, indexes)
+	versionMap := updater.VersionMap(&hw)
+	var out string
+	if inputFormat != formatHelmwave {
+		_, editSpan := startSpan(ctx, "updateText")
+		out, err = updateInput(data, versionMap)
+		spanError(editSpan, err)
+		editSpan.End()
+		if err != nil {
+			spanError(span, err)
+			return checkResult{}, fmt.Errorf("check aborted, no files written: %w", err)
+		}
+	} else {
+		chartVersionMap := updater.ChartVersionMap(&hw)
 
-	versionMap := buildVersionMap(&hw)
-	chartVersionMap := buildChartVersionMap(&hw)
+		_, editSpan := startSpan(ctx, "updateText")
+		out = updater.UpdateText(data, versionMap, chartVersionMap)
+		for _, conflict := range updater.ChartAliasConflicts(data, versionMap) {
+			logWarnf("⚠️ not updated: %s", conflict)
+		}
+		// git-sourced charts carry their version as the ref inside chart.name, digest-pinned
+		// OCI charts their digest
+		chartNames := updater.ChartNameMap(&hw)
+		for _, u := range updates {
+			if u.Digest != "" {
+				chartNames[u.ID()] = updater.WithOCIDigest(u.Chart, u.Digest)
+			}
+		}
+		out = updater.UpdateChartNames([]byte(out), chartNames)
+		if appVersionComments {
+			out = updater.UpdateAppVersionComments([]byte(out), result.AppVersions())
+		}
+		editSpan.End()
+		pinnedHelmwave := hw.Version
+		if next := checkHelmwaveVersion(ctx, &hw); next != "" {
+			out = updater.UpdateTopLevelScalar([]byte(out), "version", next)
+			pinnedHelmwave = next
+		}
+		checkFeatureCompatibility(ctx, data, &hw, pinnedHelmwave)
+	}
 
-	out := updateFileText(data, versionMap, chartVersionMap)
+	if annotate {
+		out = updater.Annotate(string(data), out, time.Now().UTC())
+	}
 
-	outFile := filename + ".updated"
+	outFile := file + ".updated"
 	if inplace {
-		outFile = filename
+		outFile = file
+	}
+	if err := ctx.Err(); err != nil {
+		return checkResult{}, fmt.Errorf("check aborted, no files written: %w", err)
+	}
+	patchEdits = nil
+	_, writeSpan := startSpan(ctx, "writeOutput", attribute.String("file", outFile))
+	err = writeUpdated(file, out, inplace)
+	spanError(writeSpan, err)
+	writeSpan.End()
+	if err != nil {
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to write %s: %w", outFile, err)
+	}
+
+	if err := writeVersionVars(dir, vars, updates, inplace); err != nil {
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to update version variables: %w", err)
+	}
+
+	if _, err := writeLocalDependencyUpdates(updates, inplace); err != nil {
+		spanError(span, err)
+		return checkResult{}, fmt.Errorf("failed to update local chart dependencies: %w", err)
+	}
+
+	if err := printOutput(os.Stdout, result); err != nil {
+		spanError(span, err)
+		return checkResult{}, err
+	}
+	if showDiff && humanReport() {
+		if err := printDiff(os.Stdout); err != nil {
+			spanError(span, err)
+			return checkResult{}, err
+		}
+	}
+	printSummary(os.Stdout, result)
+	printSuggestedCommand(updates)
+	if err := reportPlanfile(os.Stdout, file, &source, result); err != nil {
+		spanError(span, err)
+		return checkResult{}, err
+	}
+	if reportJSON != "" {
+		if err := writeJSONReport(reportJSON, result); err != nil {
+			spanError(span, err)
+			return checkResult{}, err
+		}
+	}
+
+	if envFile != "" {
+		if err := writeEnvFile(envFile, len(hw.Releases), updates); err != nil {
+			spanError(span, err)
+			return checkResult{}, fmt.Errorf("failed to write env file %s: %w", envFile, err)
+		}
+	}
+
+	if outputFormat == outputPatch {
+		// nothing is applied until the patch is: no audit log, changelog or state entries
+		return result, nil
+	}
+	if err := appendChangelog(changelogFile, file, time.Now(), updates); err != nil {
+		logWarnf("⚠️ failed to append changelog %s: %v", changelogFile, err)
+	}
+	if !inplace {
+		// file is unchanged until apply merges the .updated copy; apply records the edits
+		return result, nil
 	}
-	if err := writeOutput(outFile, out); err != nil {
-		log.Fatalf("failed to write %s: %v", outFile, err)
+	auditEdits(file, updates)
+	if err := saveState(statePath(), bumps, time.Now(), updates); err != nil {
+		logWarnf("⚠️ failed to write state file %s: %v", statePath(), err)
 	}
+	return result, nil
 }
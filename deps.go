@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/cli"
+)
+
+// runDeps implements `deps FILE`: it updates dependencies[].version of a standalone
+// Chart.yaml, such as an umbrella chart, with the same resolution as helmwave files.
+func runDeps(args []string) {
+	fs := flag.NewFlagSet("deps", flag.ContinueOnError)
+	fs.BoolVar(&inplace, "inplace", false, "modify Chart.yaml instead of creating a .updated copy")
+	fs.BoolVar(&noRepoUpdate, "no-repo-update", false, "skip helm repo update before checking versions")
+	fs.StringVar(&indexDir, "index-dir", "", "load repo indexes from this directory instead of the helm cache")
+	fs.BoolVar(&offline, "offline", false, "forbid network access: no repo update, no OCI lookups")
+	fs.StringVar(&reportJSON, "report-json", "", "write the updates and skipped/failed dependencies as JSON to this file, - for stdout")
+	addLoggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: helmwave-updater deps [flags] Chart.yaml")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if err := applyLoggingFlags(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	if err := setupColor(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitFatal)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	result, err := checkDependencies(ctx, fs.Arg(0))
+	if err != nil {
+		logErrorf("deps: %v", err)
+		stop()
+		os.Exit(exitFatal)
+	}
+	stop()
+	os.Exit(exitCode(result))
+}
+
+// checkDependencies checks the dependencies of the chart at path (its Chart.yaml or
+// directory) and writes the updated Chart.yaml. The chart is handled as the local chart of
+// a release named after it, so -local-deps does the work.
+func checkDependencies(ctx context.Context, path string) (checkResult, error) {
+	resetRunCounters()
+	if filepath.Base(path) == "Chart.yaml" {
+		path = filepath.Dir(path)
+	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return checkResult{}, err
+	}
+	chartFile := localChartFile("", dir)
+	meta, err := readLocalChart("", dir)
+	if err != nil {
+		return checkResult{}, fmt.Errorf("failed to read chart: %w", err)
+	}
+	if len(meta.Dependencies) == 0 {
+		logInfof("%s has no dependencies", chartFile)
+		return checkResult{}, nil
+	}
+
+	prevLocalDeps, prevIgnore := localDeps, ignoreLocalCharts
+	localDeps, ignoreLocalCharts = true, false
+	defer func() { localDeps, ignoreLocalCharts = prevLocalDeps, prevIgnore }()
+
+	hw := Helmwave{Releases: []Release{{Name: meta.Name, Chart: updater.Chart{Name: dir, Version: meta.Version}}}}
+	wanted := make(chartSet)
+	addDependencyCharts(wanted, dir, hw.Releases)
+
+	settings := cli.New()
+	if offline || indexDir != "" {
+		logDebugf("skipping helm repo update (offline=%v index-dir=%q)", offline, indexDir)
+	} else if !noRepoUpdate {
+		logInfof("running helm repo update...")
+		updateRepos(ctx, settings, wanted)
+	}
+	indexes, err := loadIndexes(ctx, settings, wanted)
+	if err != nil {
+		return checkResult{}, fmt.Errorf("failed to load repo file: %w", err)
+	}
+
+	result, err := processReleases(ctx, chartFile, &hw, indexes)
+	if err != nil {
+		return checkResult{}, fmt.Errorf("check aborted, no files written: %w", err)
+	}
+	// the chart itself is reported as a local chart; only its dependencies count
+	result.Releases = slices.DeleteFunc(result.Releases, func(r releaseResult) bool { return r.Chart == dir })
+
+	if _, err := writeLocalDependencyUpdates(result.Updates(), inplace); err != nil {
+		return checkResult{}, fmt.Errorf("failed to update dependencies: %w", err)
+	}
+	printSummary(os.Stdout, result)
+	if reportJSON != "" {
+		if err := writeJSONReport(reportJSON, result); err != nil {
+			return checkResult{}, err
+		}
+	}
+	return result, nil
+}
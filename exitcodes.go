@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes of a check run. Callers that only understand success/failure can pass
+// -legacy-exit-codes to collapse everything except exitFatal to 0.
+const (
+	// exitUpToDate: every release is up-to-date (or intentionally skipped)
+	exitUpToDate = 0
+	// exitFatal: the run could not complete (bad flags, unreadable file, write failure)
+	exitFatal = 1
+	// exitUpdatesAvailable: updates were found and written to the output file
+	exitUpdatesAvailable = 2
+	// exitAppliedWithWarnings: updates were written but warnings were logged along the way
+	exitAppliedWithWarnings = 3
+	// exitPartialFailure: some repositories or releases could not be resolved
+	exitPartialFailure = 4
+)
+
+var legacyExitCodes bool
+
+// parseFlags parses args into fs, which must use flag.ContinueOnError. A bad flag exits with
+// exitFatal, not the flag package's 2, which means "updates available"; -h exits with
+// exitUpToDate.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitUpToDate)
+		}
+		os.Exit(exitFatal)
+	}
+}
+
+// strict turns unresolved releases (missing index or chart, malformed chart.name) and
+// repository failures into a fatal error before anything is written (-strict).
+var strict bool
+
+// repoFailures counts repositories whose index could not be updated or loaded.
+var repoFailures int
+
+// resetRunCounters clears the per-run counters exitCode reads (repoFailures and
+// warningCount), so that a process running several checks scores each one on its own.
+func resetRunCounters() {
+	repoFailures, warningCount = 0, 0
+}
+
+// strictError fails a strict run that left releases unresolved or repositories unloaded.
+func strictError(result checkResult) error {
+	if !strict {
+		return nil
+	}
+	var unresolved []string
+	for _, r := range result.Releases {
+		if r.Status == statusFailed {
+			unresolved = append(unresolved, fmt.Sprintf("%s (%s)", r.Release, r.Reason))
+		}
+	}
+	switch {
+	case len(unresolved) > 0:
+		return fmt.Errorf("strict: %d releases unresolved: %s", len(unresolved), strings.Join(unresolved, "; "))
+	case repoFailures > 0:
+		return fmt.Errorf("strict: %d repositories could not be updated or loaded", repoFailures)
+	}
+	return nil
+}
+
+// exitCode maps the outcome of a successful run to the documented exit code contract.
+func exitCode(result checkResult) int {
+	if legacyExitCodes {
+		return exitUpToDate
+	}
+	switch {
+	case repoFailures > 0 || result.Count(statusFailed) > 0:
+		return exitPartialFailure
+	case result.Count(statusUpdated) > 0 && warningCount > 0:
+		return exitAppliedWithWarnings
+	case result.Count(statusUpdated) > 0:
+		return exitUpdatesAvailable
+	}
+	return exitUpToDate
+}
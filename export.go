@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// defaultTagsTemplate reproduces the historical export line
+const defaultTagsTemplate = `export HELMWAVE_TAGS='{{ join .Tags "," }}'`
+
+var tagsExport = "last"
+var tagsTemplate = defaultTagsTemplate
+var noTagsExport bool
+var envFile string
+
+// tagsTemplateData is passed to -tags-template.
+type tagsTemplateData struct {
+	Tags     []string
+	Releases []string
+}
+
+// collectTags returns the deduplicated tags of updated releases according to mode (last, first or all).
+func collectTags(updates []releaseUpdate, mode string) ([]string, error) {
+	var tags []string
+	for _, u := range updates {
+		u.Tags = deployTags(u.Tags)
+		switch mode {
+		case "", "last":
+			tags = append(tags, lastTag(u.Tags))
+		case "first":
+			if len(u.Tags) > 0 {
+				tags = append(tags, strings.TrimSpace(u.Tags[0]))
+			}
+		case "all":
+			for _, t := range u.Tags {
+				tags = append(tags, strings.TrimSpace(t))
+			}
+		default:
+			return nil, fmt.Errorf("unknown -tags-export mode %q (expected last, first or all)", mode)
+		}
+	}
+	// remove duplicates while preserving order
+	unique := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		if t == "" {
+			continue
+		}
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique, nil
+}
+
+// deployTags drops channel:<name> tags, which select versions rather than releases to deploy.
+func deployTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if updater.ReleaseChannel([]string{t}) == "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// renderTagsExport renders the export line for updated releases using tmpl.
+func renderTagsExport(updates []releaseUpdate, mode, tmpl string) (string, error) {
+	tags, err := collectTags(updates, mode)
+	if err != nil {
+		return "", err
+	}
+	t, err := template.New("tags").Funcs(template.FuncMap{"join": strings.Join}).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid -tags-template: %w", err)
+	}
+	data := tagsTemplateData{Tags: tags}
+	for _, u := range updates {
+		data.Releases = append(data.Releases, u.Release)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render -tags-template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// printTagsExport prints the export line to stdout unless -no-tags-export or another -output is set.
+func printTagsExport(updates []releaseUpdate) error {
+	if noTagsExport || outputFormat != outputText {
+		return nil
+	}
+	line, err := renderTagsExport(updates, tagsExport, tagsTemplate)
+	if err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Println()
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// suggestedCommand returns the helmwave command that deploys only the updated releases.
+// If an updated release has no tags it cannot be selected by tag, so the unscoped command is returned.
+func suggestedCommand(updates []releaseUpdate) (string, error) {
+	if len(updates) == 0 {
+		return "", nil
+	}
+	for _, u := range updates {
+		if lastTag(u.Tags) == "" {
+			return "helmwave up --build", nil
+		}
+	}
+	tags, err := collectTags(updates, tagsExport)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("helmwave up --build --tags %s", strings.Join(tags, ",")), nil
+}
+
+// printSuggestedCommand prints the next step after updates were written.
+func printSuggestedCommand(updates []releaseUpdate) {
+	if !humanReport() {
+		return
+	}
+	command, err := suggestedCommand(updates)
+	if err != nil || command == "" {
+		return
+	}
+	fmt.Printf(tr("\nNext step: %s\n"), command)
+}
+
+// writeEnvFile writes run results as a dotenv file (KEY=value per line) for CI steps
+// that should not parse the mixed stdout.
+func writeEnvFile(path string, checked int, updates []releaseUpdate) error {
+	tags, err := collectTags(updates, tagsExport)
+	if err != nil {
+		return err
+	}
+	releases := make([]string, 0, len(updates))
+	for _, u := range updates {
+		releases = append(releases, u.Release)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "HELMWAVE_TAGS=%s\n", envValue(strings.Join(tags, ",")))
+	fmt.Fprintf(&sb, "UPDATED_RELEASES=%d\n", len(updates))
+	fmt.Fprintf(&sb, "UPDATED_RELEASE_NAMES=%s\n", envValue(strings.Join(releases, ",")))
+	fmt.Fprintf(&sb, "CHECKED_RELEASES=%d\n", checked)
+	command, err := suggestedCommand(updates)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&sb, "HELMWAVE_COMMAND=%s\n", envValue(command))
+	if err := writeFileAtomic(path, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	logDebugf("wrote env file %s", path)
+	return nil
+}
+
+// envValue double-quotes a dotenv value that contains spaces, quotes or a #, which dotenv
+// parsers would otherwise cut off.
+func envValue(v string) string {
+	if strings.ContainsAny(v, " \t\"'#\\") {
+		return strconv.Quote(v)
+	}
+	return v
+}
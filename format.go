@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// inputFormat is the format of -file (-format)
+var inputFormat = formatHelmwave
+
+const (
+	formatHelmwave = "helmwave"
+	// formatFlux is Flux HelmRelease and HelmRepository manifests
+	formatFlux     = "flux"
+	formatHelmfile = "helmfile"
+	// formatArgoCD is Argo CD Application manifests
+	formatArgoCD = "argocd"
+)
+
+// inputFormats are the accepted -format values.
+var inputFormats = []string{formatHelmwave, formatFlux, formatHelmfile, formatArgoCD}
+
+// helmwaveIncludes is the helmwave -file with the files it includes through readFile, as
+// read by readInput (or by set and apply); the data readInput returns is its inlined text
+var helmwaveIncludes *updater.Included
+
+// readInput reads path in inputFormat and sets helmwaveRepositories to the repositories the
+// file defines. A helmwave file is read with its includes inlined (see helmwaveIncludes).
+func readInput(path string) ([]byte, Helmwave, error) {
+	if inputFormat == formatHelmwave {
+		in, hw, err := updater.ReadIncluded(path)
+		if err != nil {
+			return nil, Helmwave{}, err
+		}
+		helmwaveIncludes = in
+		data := []byte(in.Text)
+		if helmwaveRepositories, err = updater.ParseRepositories(data); err != nil {
+			logWarnf("⚠️ failed to parse repositories section: %v", err)
+		}
+		return data, hw, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Helmwave{}, err
+	}
+	var hw Helmwave
+	switch inputFormat {
+	case formatFlux:
+		hw, helmwaveRepositories, err = updater.ParseFlux(data)
+	case formatHelmfile:
+		hw, helmwaveRepositories, err = updater.ParseHelmfile(data)
+	case formatArgoCD:
+		hw, helmwaveRepositories, err = updater.ParseArgoCD(data)
+	default:
+		err = fmt.Errorf("unknown -format %q", inputFormat)
+	}
+	if err != nil {
+		var perr *updater.ParseError
+		if errors.As(err, &perr) {
+			perr.File = path
+		}
+		return nil, Helmwave{}, err
+	}
+	return data, hw, nil
+}
+
+// updateInput edits the version lines of data in a non-helmwave inputFormat.
+func updateInput(data []byte, versions map[string]string) (string, error) {
+	switch inputFormat {
+	case formatFlux:
+		return updater.UpdateFluxVersions(data, versions)
+	case formatHelmfile:
+		return updater.UpdateHelmfileVersions(data, versions)
+	case formatArgoCD:
+		return updater.UpdateArgoCDVersions(data, versions)
+	}
+	return "", fmt.Errorf("unknown -format %q", inputFormat)
+}
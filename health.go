@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthAddr is the listen address of the controller's /healthz and /readyz endpoints (-health-addr)
+var healthAddr string
+
+// controllerHealth tracks the controller's checks for its probe endpoints.
+type controllerHealth struct {
+	mu       sync.Mutex
+	started  time.Time
+	interval time.Duration
+	// lastCheck is when the last check finished; lastSuccess when the last one without error did
+	lastCheck   time.Time
+	lastSuccess time.Time
+	lastError   string
+	// repoFailures counts the repositories the last check could not update or load
+	repoFailures int
+}
+
+// healthStatus is the JSON body of /healthz and /readyz.
+type healthStatus struct {
+	Status         string     `json:"status"`
+	LastCheck      *time.Time `json:"lastCheck,omitempty"`
+	LastSuccess    *time.Time `json:"lastSuccess,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	ReposReachable bool       `json:"reposReachable"`
+	RepoFailures   int        `json:"repoFailures"`
+}
+
+func newControllerHealth(interval time.Duration, now time.Time) *controllerHealth {
+	return &controllerHealth{started: now, interval: interval}
+}
+
+// record stores the outcome of a check that finished at now.
+func (h *controllerHealth) record(now time.Time, checkErr error, repoFailures int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCheck, h.repoFailures, h.lastError = now, repoFailures, ""
+	if checkErr != nil {
+		h.lastError = redactSecrets(checkErr.Error())
+		return
+	}
+	h.lastSuccess = now
+}
+
+// status reports liveness and readiness at now. The controller is live while checks keep
+// finishing (the last one within three intervals) and ready while the last check succeeded.
+func (h *controllerHealth) status(now time.Time) (live, ready bool, s healthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s = healthStatus{LastError: h.lastError, RepoFailures: h.repoFailures, ReposReachable: h.repoFailures == 0}
+	if !h.lastCheck.IsZero() {
+		lastCheck := h.lastCheck.UTC()
+		s.LastCheck = &lastCheck
+	}
+	if !h.lastSuccess.IsZero() {
+		lastSuccess := h.lastSuccess.UTC()
+		s.LastSuccess = &lastSuccess
+	}
+	since := h.started
+	if !h.lastCheck.IsZero() {
+		since = h.lastCheck
+	}
+	live = now.Sub(since) <= 3*h.interval
+	ready = !h.lastSuccess.IsZero() && h.lastError == ""
+	return live, ready, s
+}
+
+// handler serves /healthz (liveness) and /readyz (readiness) with the status as JSON;
+// a failing probe answers 503. With -pprof it also serves /debug/pprof/.
+func (h *controllerHealth) handler() http.Handler {
+	serve := func(w http.ResponseWriter, ok bool, s healthStatus) {
+		code := http.StatusOK
+		s.Status = "ok"
+		if !ok {
+			code, s.Status = http.StatusServiceUnavailable, "unavailable"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		live, _, s := h.status(time.Now())
+		serve(w, live, s)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		_, ready, s := h.status(time.Now())
+		serve(w, ready, s)
+	})
+	if pprofEndpoints {
+		registerPprof(mux)
+	}
+	return mux
+}
+
+// serveHealth serves the probe endpoints on healthAddr until ctx is cancelled.
+func serveHealth(ctx context.Context, h *controllerHealth) error {
+	ln, err := net.Listen("tcp", healthAddr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: h.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logInfof("controller: serving /healthz and /readyz on %s (pprof: %v)", ln.Addr(), pprofEndpoints)
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logErrorf("controller: health server: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,54 @@
+project: example
+version: 0.41.1
+
+repositories:
+  - name: bitnami
+    url: https://charts.bitnami.com/bitnami
+  - name: private
+    url: https://charts.example.com/stable
+    username: {{ env "PRIVATE_REPO_USER" }}
+    password: {{ env "PRIVATE_REPO_PASSWORD" }}
+
+registries:
+  - host: ghcr.io
+    username: {{ env "GHCR_USER" }}
+    password: {{ env "GHCR_TOKEN" }}
+
+.options: &options
+  namespace: apps
+  wait: true
+  timeout: 10m
+  chart:
+    name: bitnami/redis
+    version: 18.1.0
+
+releases:
+  - name: nginx
+    namespace: ingress
+    chart:
+      name: bitnami/nginx
+      version: "15.3.1" # pinned by platform team
+    tags:
+      - ingress
+      - nginx
+
+  - name: redis
+    <<: *options
+    tags:
+      - cache
+
+  - name: podinfo
+    namespace: apps
+    chart:
+      name: oci://ghcr.io/stefanprodan/charts/podinfo
+      version: 6.5.0
+    tags:
+      - podinfo
+
+  - name: legacy
+    namespace: apps
+    chart:
+      name: private/legacy
+      version: 1.0.0
+    tags:
+      - noupdate
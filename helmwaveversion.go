@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// helmwaveReleaseURL is the GitHub API endpoint for the latest helmwave release
+var helmwaveReleaseURL = "https://api.github.com/repos/helmwave/helmwave/releases/latest"
+
+// helmwaveVersionMode checks the file's top-level helmwave version (-helmwave-version):
+// "" disables the check, warn reports an outdated version, bump updates it.
+var helmwaveVersionMode string
+
+// latestHelmwaveVersion returns the tag of the latest helmwave release.
+func latestHelmwaveVersion(ctx context.Context) (string, error) {
+	var release githubRelease
+	err := withRetry(ctx, "fetch latest helmwave release", func() error {
+		body, err := providerGet(ctx, helmwaveReleaseURL, providerAuth{token: githubToken()})
+		if err != nil {
+			return err
+		}
+		return permanent(json.Unmarshal(body, &release))
+	})
+	if err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("empty tag_name in GitHub response")
+	}
+	return release.TagName, nil
+}
+
+// checkHelmwaveVersion compares the helmwave version required by the file with the latest
+// release. It returns the version to write in bump mode, keeping the file's "v" prefix
+// style, and "" when nothing should change.
+func checkHelmwaveVersion(ctx context.Context, hw *Helmwave) string {
+	if helmwaveVersionMode == "" || hw.Version == "" {
+		return ""
+	}
+	if offline {
+		logDebugf("skipping helmwave version check in offline mode")
+		return ""
+	}
+	current, err := semver.NewVersion(hw.Version)
+	if err != nil {
+		logDebugf("helmwave version %q is not a plain version; not checked", hw.Version)
+		return ""
+	}
+	latestTag, err := latestHelmwaveVersion(ctx)
+	if err != nil {
+		logWarnf("⚠️ failed to check the latest helmwave release: %v", err)
+		return ""
+	}
+	latest, err := semver.NewVersion(latestTag)
+	if err != nil || !latest.GreaterThan(current) {
+		logDebugf("helmwave version %s is current (latest %s)", hw.Version, latestTag)
+		return ""
+	}
+
+	next := strings.TrimPrefix(latestTag, "v")
+	if strings.HasPrefix(hw.Version, "v") {
+		next = "v" + next
+	}
+	if helmwaveVersionMode != "bump" {
+		logWarnf("⚠️ the helmwave file pins helmwave %s; %s is available", hw.Version, next)
+		return ""
+	}
+	logInfof("bumping the helmwave version: %s -> %s", hw.Version, next)
+	return next
+}
+
+// noFeatureCheck disables the helmwave feature-compatibility check (-no-feature-check)
+var noFeatureCheck bool
+
+// installedHelmwaveVersion returns the version reported by the helmwave binary in PATH,
+// or "" when it is not installed. It is a variable so tests can replace it.
+var installedHelmwaveVersion = func(ctx context.Context) string {
+	if _, err := exec.LookPath("helmwave"); err != nil {
+		return ""
+	}
+	out, err := exec.CommandContext(ctx, "helmwave", "version").Output()
+	if err != nil {
+		logDebugf("helmwave version failed: %v", err)
+		return ""
+	}
+	return versionInOutput.FindString(string(out))
+}
+
+// versionInOutput finds the first semantic version in command output
+var versionInOutput = regexp.MustCompile(`v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?`)
+
+// checkFeatureCompatibility warns about features of the helmwave file that the helmwave
+// version pinned in the file (after a bump) or installed locally does not support.
+// It returns the number of warnings.
+func checkFeatureCompatibility(ctx context.Context, data []byte, hw *Helmwave, pinned string) int {
+	if noFeatureCheck {
+		return 0
+	}
+	features := updater.DetectFeatures(data, hw)
+	if len(features) == 0 {
+		return 0
+	}
+	warnings := 0
+	for _, target := range []struct{ source, version string }{
+		{"pinned in the file", pinned},
+		{"installed", installedHelmwaveVersion(ctx)},
+	} {
+		if target.version == "" {
+			continue
+		}
+		v, err := semver.NewVersion(target.version)
+		if err != nil {
+			logDebugf("helmwave version %q (%s) is not a plain version; features not checked", target.version, target.source)
+			continue
+		}
+		for _, f := range features {
+			if v.LessThan(semver.MustParse(f.MinVersion)) {
+				logWarnf("⚠️ %s need helmwave %s or newer, but the helmwave %s is %s", f.Name, f.MinVersion, target.source, target.version)
+				warnings++
+			}
+		}
+	}
+	return warnings
+}
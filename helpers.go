@@ -1,24 +1,35 @@
 package main
 
 import (
-	"log"
+	"sort"
 	"strings"
 )
 
-// verbose logger helper to avoid scattering `if verbose { ... }` blocks
-func vlog(format string, args ...interface{}) {
-	if verbose {
-		log.Printf(format, args...)
+// lastTag returns the trimmed last tag of a release (empty if there are no tags)
+func lastTag(tags []string) string {
+	if len(tags) == 0 {
+		return ""
 	}
+	return strings.TrimSpace(tags[len(tags)-1])
 }
 
-// helper to check tags (case-insensitive)
-func hasTag(tags []string, want string) bool {
-	want = strings.TrimSpace(want)
-	for _, t := range tags {
-		if strings.EqualFold(strings.TrimSpace(t), want) {
-			return true
+// firstNonEmpty returns the first non-empty (after trimming) value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
 		}
 	}
-	return false
+	return ""
+}
+
+// sortedKeys returns the keys of m in sorted order, for output that must not depend on map
+// iteration order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
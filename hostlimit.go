@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// hostRate caps the requests per second sent to each host (-host-rate); 0 is unlimited
+var hostRate float64
+
+// hostConcurrency caps the requests in flight to each host (-host-concurrency); 0 is unlimited
+var hostConcurrency int
+
+// hostLimitConfig is the config form of the limits of one host; unset fields take the flags.
+type hostLimitConfig struct {
+	Rate        float64 `yaml:"rate,omitempty"`
+	Concurrency int     `yaml:"concurrency,omitempty"`
+}
+
+// validate rejects negative limits.
+func (c hostLimitConfig) validate() error {
+	if c.Rate < 0 || c.Concurrency < 0 {
+		return fmt.Errorf("rate and concurrency must not be negative")
+	}
+	return nil
+}
+
+// hostLimit paces and caps the requests to one host. Requests are spaced evenly rather than
+// sent in bursts, which is what WAFs and registry throttles count.
+type hostLimit struct {
+	interval time.Duration
+	// slots holds a token per request in flight; nil when concurrency is unlimited
+	slots chan struct{}
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newHostLimit(rate float64, concurrency int) *hostLimit {
+	l := &hostLimit{}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	return l
+}
+
+// acquire waits for a free slot and the request's turn. The returned release frees the slot.
+func (l *hostLimit) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			release = sync.OnceFunc(func() { <-l.slots })
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.interval > 0 {
+		l.mu.Lock()
+		at := time.Now()
+		if l.next.After(at) {
+			at = l.next
+		}
+		l.next = at.Add(l.interval)
+		l.mu.Unlock()
+		if wait := time.Until(at); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return release, nil
+}
+
+// hostLimits holds the limit of every host contacted by the process, so a daemon keeps
+// pacing across runs.
+var hostLimits = struct {
+	sync.Mutex
+	m map[string]*hostLimit
+}{m: make(map[string]*hostLimit)}
+
+// limitFor returns the limit of host, nil when it is unlimited.
+func limitFor(host string) *hostLimit {
+	rate, concurrency := hostRate, hostConcurrency
+	if c, ok := config.Hosts[host]; ok {
+		if c.Rate > 0 {
+			rate = c.Rate
+		}
+		if c.Concurrency > 0 {
+			concurrency = c.Concurrency
+		}
+	}
+	if rate <= 0 && concurrency <= 0 {
+		return nil
+	}
+	hostLimits.Lock()
+	defer hostLimits.Unlock()
+	l, ok := hostLimits.m[host]
+	if !ok {
+		l = newHostLimit(rate, concurrency)
+		hostLimits.m[host] = l
+	}
+	return l
+}
+
+// resetHostLimits drops the limits built so far, after the settings changed.
+func resetHostLimits() {
+	hostLimits.Lock()
+	defer hostLimits.Unlock()
+	hostLimits.m = make(map[string]*hostLimit)
+}
+
+// limitedTransport applies the per-host limits to the requests of base (http.DefaultTransport
+// when nil). A request holds its concurrency slot until its response body is closed.
+type limitedTransport struct {
+	base http.RoundTripper
+}
+
+// limitedClient is http.DefaultClient with the per-host limits.
+var limitedClient = &http.Client{Transport: limitedTransport{}}
+
+// outboundClient returns the client for every request the tool sends on its own: registry
+// and API lookups, pull requests, metrics pushes and self-update downloads.
+func outboundClient() *http.Client { return limitedClient }
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	l := limitFor(req.URL.Hostname())
+	if l == nil {
+		return base.RoundTrip(req)
+	}
+	start := time.Now()
+	release, err := l.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		logDebugf("waited %s for the rate limit of %s", waited.Round(time.Millisecond), req.URL.Host)
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees a concurrency slot when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// cacheTTL enables the tool's own index cache (-cache-ttl); 0 disables it
+var cacheTTL time.Duration
+
+// indexCacheDir overrides the cache location (default <user cache dir>/helmwave-updater/indexes)
+var indexCacheDir string
+
+// parsedIndexes memoizes parsed index files by path and modification time, so repeated
+// runs in one process (daemon / watch mode) don't re-parse unchanged multi-megabyte indexes.
+var parsedIndexes = struct {
+	sync.Mutex
+	m map[string]parsedIndex
+}{m: make(map[string]parsedIndex)}
+
+type parsedIndex struct {
+	modTime time.Time
+	index   *repo.IndexFile
+}
+
+func toolIndexCacheDir() (string, error) {
+	if indexCacheDir != "" {
+		return indexCacheDir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "helmwave-updater", "indexes"), nil
+}
+
+// cachedIndexPath returns the tool cache path for a repository URL.
+func cachedIndexPath(repoURL string) (string, error) {
+	dir, err := toolIndexCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(repoURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:12])+"-index.yaml"), nil
+}
+
+// freshCachedIndex reports whether the tool cache holds an index for repoURL younger than cacheTTL.
+func freshCachedIndex(repoURL string) (string, time.Duration, bool) {
+	if cacheTTL <= 0 {
+		return "", 0, false
+	}
+	path, err := cachedIndexPath(repoURL)
+	if err != nil {
+		return "", 0, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, false
+	}
+	age := time.Since(info.ModTime())
+	return path, age, age < cacheTTL
+}
+
+// storeCachedIndex copies a freshly downloaded (or revalidated) index into the tool cache.
+func storeCachedIndex(repoURL, indexPath string) {
+	if cacheTTL <= 0 {
+		return
+	}
+	path, err := cachedIndexPath(repoURL)
+	if err != nil {
+		logDebugf("index cache unavailable: %v", err)
+		return
+	}
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		logDebugf("failed to read %s for index cache: %v", indexPath, err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logDebugf("failed to create index cache dir: %v", err)
+		return
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		logDebugf("failed to write index cache for %s: %v", repoURL, err)
+	}
+}
+
+// loadIndexFileCached loads an index file, reusing the parsed copy when the file hasn't changed.
+// With a non-nil charts set only those entries are parsed (see loadIndexSelective).
+func loadIndexFileCached(path string, charts map[string]bool) (*repo.IndexFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := path
+	if charts != nil {
+		key += "|" + selectionKey(charts)
+	}
+	parsedIndexes.Lock()
+	cached, ok := parsedIndexes.m[key]
+	parsedIndexes.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.index, nil
+	}
+	var idx *repo.IndexFile
+	if charts != nil {
+		idx, err = loadIndexSelective(path, charts)
+	} else {
+		idx, err = repo.LoadIndexFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	parsedIndexes.Lock()
+	parsedIndexes.m[key] = parsedIndex{modTime: info.ModTime(), index: idx}
+	parsedIndexes.Unlock()
+	return idx, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// errIndexNotModified is returned by fetchIndex when the server answered 304.
+var errIndexNotModified = errors.New("index not modified")
+
+// indexMeta is stored next to a cached index and carries the validators of the last download.
+type indexMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func indexCachePath(cacheDir, name string) string {
+	return filepath.Join(cacheDir, name+"-index.yaml")
+}
+
+func indexMetaPath(cacheDir, name string) string {
+	return indexCachePath(cacheDir, name) + ".meta.json"
+}
+
+// readIndexMeta returns the validators for a cached index, or nil when they can't be used
+// (no cache, unreadable metadata, or the repository URL changed since).
+func readIndexMeta(cacheDir string, entry *repo.Entry) *indexMeta {
+	if _, err := os.Stat(indexCachePath(cacheDir, entry.Name)); err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(indexMetaPath(cacheDir, entry.Name))
+	if err != nil {
+		return nil
+	}
+	var meta indexMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != entry.URL {
+		return nil
+	}
+	return &meta
+}
+
+// fetchIndex downloads <repo url>/index.yaml into the helm repository cache, sending
+// If-None-Match / If-Modified-Since when a previous download left validators behind.
+// It returns errIndexNotModified, after touching the cached index, when it is still current.
+func fetchIndex(ctx context.Context, cacheDir string, entry *repo.Entry) error {
+	indexURL, err := repo.ResolveReferenceURL(entry.URL, "index.yaml")
+	if err != nil {
+		return err
+	}
+	client, err := tlsOptions{
+		CAFile:   entry.CAFile,
+		CertFile: entry.CertFile,
+		KeyFile:  entry.KeyFile,
+		Insecure: entry.InsecureSkipTLSVerify,
+	}.httpClient()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "helmwave-updater/"+version)
+	if entry.Username != "" || entry.Password != "" {
+		req.SetBasicAuth(entry.Username, entry.Password)
+	}
+	if meta := readIndexMeta(cacheDir, entry); meta != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		// the cached index was just revalidated; its age drives -stale-after and
+		// -auto-refresh-older-than
+		now := time.Now()
+		if err := os.Chtimes(indexCachePath(cacheDir, entry.Name), now, now); err != nil {
+			logDebugf("failed to touch revalidated index of %s: %v", entry.Name, err)
+		}
+		return errIndexNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("failed to fetch %s: %s", indexURL, resp.Status)
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	// validate before replacing a good cached index
+	names, err := validateIndex(cacheDir, data)
+	if err != nil {
+		return permanent(fmt.Errorf("invalid index from %s: %w", indexURL, err))
+	}
+	if err := writeFileAtomic(indexCachePath(cacheDir, entry.Name), data, 0644); err != nil {
+		return err
+	}
+	writeChartsFile(cacheDir, entry.Name, names)
+
+	meta := indexMeta{URL: entry.URL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if meta.ETag == "" && meta.LastModified == "" {
+		os.Remove(indexMetaPath(cacheDir, entry.Name))
+		return nil
+	}
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(indexMetaPath(cacheDir, entry.Name), metaData, 0644)
+}
+
+// validateIndex checks a downloaded index and returns its chart names. YAML indexes are
+// only scanned for their structure (see scanIndexCharts); other layouts are fully loaded
+// from a temporary file in dir.
+func validateIndex(dir string, data []byte) ([]string, error) {
+	names, ok, err := scanIndexCharts(bytes.NewReader(data))
+	if ok || err != nil {
+		return names, err
+	}
+	tmp, err := os.CreateTemp(dir, ".index-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.LoadIndexFile(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	names = make([]string, 0, len(idx.Entries))
+	for chartName := range idx.Entries {
+		names = append(names, chartName)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeChartsFile keeps helm's <repo>-charts.txt in sync, as `helm repo update` would.
+func writeChartsFile(cacheDir, name string, names []string) {
+	content := strings.Join(names, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := writeFileAtomic(filepath.Join(cacheDir, name+"-charts.txt"), []byte(content), 0644); err != nil {
+		logDebugf("failed to write charts list for %s: %v", name, err)
+	}
+}
+
+// isHTTPRepo reports whether entry can be fetched by fetchIndex; other schemes go through helm getters.
+func isHTTPRepo(entry *repo.Entry) bool {
+	u := strings.ToLower(entry.URL)
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
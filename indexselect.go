@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// chartSet lists the charts referenced by the helmwave file, per repository name.
+type chartSet map[string]map[string]bool
+
+// referencedCharts collects the repo/chart pairs looked up in repository indexes, routing
+// releases like the provider set does: a configured source's chart option overrides
+// chart.name, and releases resolved by other providers need no index.
+func referencedCharts(releases []Release) chartSet {
+	providers := newProviderSet(nil, nil)
+	set := make(chartSet)
+	for _, r := range releases {
+		_, kind, lookup, err := providers.forRelease(r)
+		if err != nil || kind != providerHelm {
+			continue
+		}
+		repoName, chartName, ok := updater.SplitRepoChart(lookup.Chart.Name)
+		if !ok {
+			continue
+		}
+		if set[repoName] == nil {
+			set[repoName] = make(map[string]bool)
+		}
+		set[repoName][chartName] = true
+	}
+	return set
+}
+
+// updatableReleases drops releases tagged noupdate or outside -context; their repositories
+// need no index.
+func updatableReleases(hw *Helmwave) []Release {
+	var out []Release
+	for _, r := range hw.Releases {
+		if !updater.HasTag(r.Tags, NoupdateTag) && inKubeContext(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// inKubeContext reports whether the release is selected by -context (every release is without it).
+func inKubeContext(r Release) bool {
+	return kubeContext == "" || r.Context == kubeContext
+}
+
+// has reports whether repoName is referenced; a nil set references every repository.
+func (s chartSet) has(repoName string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[repoName]
+	return ok
+}
+
+// wantedCharts returns the charts to parse for repoName; nil (parse everything) when wanted is nil.
+func wantedCharts(wanted chartSet, repoName string) map[string]bool {
+	if wanted == nil {
+		return nil
+	}
+	if charts := wanted[repoName]; charts != nil {
+		return charts
+	}
+	return map[string]bool{}
+}
+
+// selectionKey identifies the selection for memoization.
+func selectionKey(charts map[string]bool) string {
+	names := make([]string, 0, len(charts))
+	for c := range charts {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// scanIndexCharts checks that a YAML index has an apiVersion and an entries map and
+// returns the chart names, without parsing any chart metadata. ok is false for JSON
+// indexes and layouts the scanner doesn't recognize; those need a full load.
+func scanIndexCharts(r io.Reader) (names []string, ok bool, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	inEntries, apiVersion, entries := false, false, false
+	keyIndent := -1
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "{") && !apiVersion && !entries {
+			return nil, false, nil
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			inEntries = false
+			switch {
+			case strings.HasPrefix(line, "apiVersion:"):
+				apiVersion = strings.TrimSpace(strings.TrimPrefix(line, "apiVersion:")) != ""
+			case strings.HasPrefix(line, "entries:"):
+				rest := strings.TrimSpace(strings.TrimPrefix(line, "entries:"))
+				if rest != "" && rest != "{}" {
+					return nil, false, nil
+				}
+				inEntries, entries = true, true
+			}
+			continue
+		}
+		if !inEntries {
+			continue
+		}
+		if keyIndent < 0 {
+			keyIndent = indent
+		}
+		if indent == keyIndent && !strings.HasPrefix(trimmed, "-") {
+			name, isKey := strings.CutSuffix(trimmed, ":")
+			if !isKey {
+				return nil, false, nil
+			}
+			names = append(names, strings.Trim(name, `"'`))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	if !apiVersion {
+		return nil, false, repo.ErrNoAPIVersion
+	}
+	sort.Strings(names)
+	return names, true, nil
+}
+
+// loadIndexSelective streams a YAML index file and parses only the entries for charts,
+// so resolving two charts from a multi-megabyte index doesn't materialize all of it.
+// JSON indexes and layouts the scanner doesn't recognize fall back to a full load.
+func loadIndexSelective(path string, charts map[string]bool) (*repo.IndexFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out bytes.Buffer
+	out.WriteString("apiVersion: v1\nentries:\n")
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	inEntries, keep := false, false
+	keyIndent := -1
+	apiVersionSeen := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if !apiVersionSeen && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if strings.HasPrefix(trimmed, "{") {
+				return repo.LoadIndexFile(path)
+			}
+			apiVersionSeen = true
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			if keep {
+				out.WriteString(line + "\n")
+			}
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			inEntries = strings.HasPrefix(line, "entries:")
+			keep = false
+			continue
+		}
+		if !inEntries {
+			continue
+		}
+		if keyIndent < 0 {
+			keyIndent = indent
+		}
+		if indent == keyIndent && !strings.HasPrefix(trimmed, "-") {
+			name, ok := strings.CutSuffix(trimmed, ":")
+			if !ok {
+				return repo.LoadIndexFile(path)
+			}
+			keep = charts[strings.Trim(name, `"'`)]
+		}
+		if keep {
+			out.WriteString(line + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if keyIndent < 0 {
+		return repo.LoadIndexFile(path)
+	}
+
+	idx := repo.NewIndexFile()
+	if err := yaml.Unmarshal(out.Bytes(), idx); err != nil {
+		return nil, fmt.Errorf("failed to parse selected entries of %s: %w", path, err)
+	}
+	if idx.Entries == nil {
+		idx.Entries = make(map[string]repo.ChartVersions)
+	}
+	for name, versions := range idx.Entries {
+		valid := versions[:0]
+		for _, v := range versions {
+			if v != nil && v.Metadata != nil {
+				valid = append(valid, v)
+			}
+		}
+		idx.Entries[name] = valid
+	}
+	idx.SortEntries()
+	return idx, nil
+}
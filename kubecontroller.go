@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v4/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// controllerMode runs the check on an interval inside a cluster instead of once (-controller)
+var controllerMode bool
+
+// controllerInterval is the time between controller checks (-interval)
+var controllerInterval = time.Hour
+
+// gitRepo is the repository the controller checks out; -file is a path inside it (-git-repo)
+var gitRepo string
+
+// gitBranch is the branch of gitRepo that is checked and that pull requests target (-git-branch)
+var gitBranch = "main"
+
+// sourceConfigMap is a [namespace/]name ConfigMap whose -file key holds the helmwave file (-configmap)
+var sourceConfigMap string
+
+// statusConfigMap is the [namespace/]name ConfigMap the controller writes its findings to (-status-configmap)
+var statusConfigMap = "helmwave-updater-status"
+
+// openPR pushes updated files to a branch of gitRepo and opens a GitHub pull request (-open-pr)
+var openPR bool
+
+// githubAPIURL is the GitHub API used for pull requests; GITHUB_API_URL overrides it (GitHub Enterprise).
+var githubAPIURL = "https://api.github.com"
+
+// Event reasons recorded on the status ConfigMap.
+const (
+	eventUpdatesAvailable = "UpdatesAvailable"
+	eventUpToDate         = "UpToDate"
+	eventCheckFailed      = "CheckFailed"
+)
+
+// kubeController checks a helmwave file from git or a ConfigMap and publishes the findings
+// as a status ConfigMap and Events on it.
+type kubeController struct {
+	client    kubernetes.Interface
+	namespace string
+	workDir   string
+	// health receives the outcome of every check for the probe endpoints
+	health *controllerHealth
+}
+
+// runController runs the check every controllerInterval until ctx is cancelled. Failed
+// checks are published and retried on the next interval.
+func runController(ctx context.Context) error {
+	if (gitRepo == "") == (sourceConfigMap == "") {
+		return errors.New("-controller needs exactly one of -git-repo or -configmap")
+	}
+	if openPR && gitRepo == "" {
+		return errors.New("-open-pr needs -git-repo")
+	}
+	if controllerInterval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+
+	settings := cli.New()
+	config, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	workDir, err := os.MkdirTemp("", "helmwave-updater-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	c := &kubeController{client: client, namespace: settings.Namespace(), workDir: workDir,
+		health: newControllerHealth(controllerInterval, time.Now())}
+	if healthAddr != "" {
+		if err := serveHealth(ctx, c.health); err != nil {
+			return fmt.Errorf("failed to serve health endpoints: %w", err)
+		}
+	}
+	source := filename
+	logInfof("controller: checking %s every %s", c.describeSource(source), controllerInterval)
+	for {
+		if err := c.check(ctx, source); err != nil {
+			logErrorf("controller: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(controllerInterval):
+		}
+	}
+}
+
+// check runs one check of source (-file within the git checkout or ConfigMap key) and
+// publishes the outcome.
+func (c *kubeController) check(ctx context.Context, source string) error {
+	resetCloudCredentials()
+	path, err := c.fetch(ctx, source)
+	var result checkResult
+	failedRepos := 0
+	if err == nil {
+		result, err = runCheck(ctx, path)
+		failedRepos = repoFailures
+	}
+	if c.health != nil {
+		c.health.record(time.Now(), err, failedRepos)
+	}
+	if pubErr := c.publish(ctx, source, result, err, time.Now()); pubErr != nil {
+		logWarnf("⚠️ failed to publish status: %v", pubErr)
+	}
+	if err != nil {
+		return err
+	}
+	if updates := result.Updates(); openPR && len(updates) > 0 {
+		return c.openPullRequest(ctx, source, updates)
+	}
+	return nil
+}
+
+// runCheck runs one check of the fetched file at path, bounded by -timeout. With -open-pr the
+// checkout is edited and committed; otherwise a .updated copy is left in workDir.
+func runCheck(ctx context.Context, path string) (checkResult, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return run(ctx, path, openPR)
+}
+
+// describeSource names where the checked file comes from, for logs and the status ConfigMap.
+func (c *kubeController) describeSource(source string) string {
+	if gitRepo != "" {
+		return fmt.Sprintf("%s@%s:%s", gitRepo, gitBranch, source)
+	}
+	ns, name := c.objectRef(sourceConfigMap)
+	return fmt.Sprintf("configmap %s/%s:%s", ns, name, source)
+}
+
+// fetch refreshes the git checkout, or writes the ConfigMap key to workDir, and returns
+// the path of the helmwave file.
+func (c *kubeController) fetch(ctx context.Context, source string) (string, error) {
+	if gitRepo != "" {
+		if _, err := os.Stat(filepath.Join(c.workDir, ".git")); err != nil {
+			if err := gitRun(ctx, "", "clone", "--depth", "1", "--branch", gitBranch, gitRepo, c.workDir); err != nil {
+				return "", err
+			}
+		} else {
+			if err := gitRun(ctx, c.workDir, "fetch", "--depth", "1", "origin", gitBranch); err != nil {
+				return "", err
+			}
+			// drop the previous run's edits and pull request branch
+			if err := gitRun(ctx, c.workDir, "checkout", "--force", "-B", gitBranch, "FETCH_HEAD"); err != nil {
+				return "", err
+			}
+			if err := gitRun(ctx, c.workDir, "clean", "-fdx"); err != nil {
+				return "", err
+			}
+		}
+		return filepath.Join(c.workDir, source), nil
+	}
+
+	ns, name := c.objectRef(sourceConfigMap)
+	cm, err := c.client.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to read configmap %s/%s: %w", ns, name, err)
+	}
+	data, ok := cm.Data[source]
+	if !ok {
+		return "", fmt.Errorf("configmap %s/%s has no key %q", ns, name, source)
+	}
+	path := filepath.Join(c.workDir, filepath.Base(source))
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// publish writes the report of result (or runErr) to the status ConfigMap and records an
+// Event on it.
+func (c *kubeController) publish(ctx context.Context, source string, result checkResult, runErr error, now time.Time) error {
+	ns, name := c.objectRef(statusConfigMap)
+	data := map[string]string{
+		"source":    c.describeSource(source),
+		"lastCheck": now.UTC().Format(time.RFC3339),
+	}
+	eventType, reason, message := corev1.EventTypeNormal, eventUpToDate, fmt.Sprintf("%d releases in %s are up to date", len(result.Releases), source)
+	if runErr != nil {
+		data["error"] = redactSecrets(runErr.Error())
+		eventType, reason, message = corev1.EventTypeWarning, eventCheckFailed, data["error"]
+	} else {
+		report, err := json.MarshalIndent(newJSONReport(result), "", "  ")
+		if err != nil {
+			return err
+		}
+		data["report.json"] = string(report)
+		if updates := result.Updates(); len(updates) > 0 {
+			reason = eventUpdatesAvailable
+			message = fmt.Sprintf("%d of %d releases in %s have updates: %s", len(updates), len(result.Releases), source, updateList(updates, ", "))
+		}
+	}
+
+	cms := c.client.CoreV1().ConfigMaps(ns)
+	cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		cm, err = cms.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{"app.kubernetes.io/managed-by": "helmwave-updater"}},
+			Data:       data,
+		}, metav1.CreateOptions{})
+	case err == nil:
+		cm.Data = data
+		cm, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write configmap %s/%s: %w", ns, name, err)
+	}
+
+	ts := metav1.NewTime(now)
+	_, err = c.client.CoreV1().Events(ns).Create(ctx, &corev1.Event{
+		// named like client-go's event recorder does
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s.%x", name, now.UnixNano()), Namespace: ns},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1", Kind: "ConfigMap", Namespace: ns, Name: name, UID: cm.UID, ResourceVersion: cm.ResourceVersion,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: "helmwave-updater"},
+		FirstTimestamp: ts,
+		LastTimestamp:  ts,
+		Count:          1,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	return nil
+}
+
+// objectRef splits a [namespace/]name reference; the namespace defaults to the controller's.
+func (c *kubeController) objectRef(ref string) (namespace, name string) {
+	if ns, name, ok := strings.Cut(ref, "/"); ok {
+		return ns, name
+	}
+	return c.namespace, ref
+}
+
+// updateList renders updates as "release from → to" items joined by sep.
+func updateList(updates []releaseUpdate, sep string) string {
+	items := make([]string, len(updates))
+	for i, u := range updates {
+		items[i] = fmt.Sprintf("%s %s → %s", u.ID(), u.FromVersion, u.ToVersion)
+	}
+	return strings.Join(items, sep)
+}
+
+// openPullRequest commits the edited checkout to the helmwave-updater/<branch> branch,
+// force-pushes it and opens a pull request against gitBranch. An open pull request for the
+// branch is updated by the push.
+func (c *kubeController) openPullRequest(ctx context.Context, source string, updates []releaseUpdate) error {
+	branch := "helmwave-updater/" + gitBranch
+	title := fmt.Sprintf("Update %d chart versions in %s", len(updates), source)
+	var body strings.Builder
+	for _, u := range updates {
+		fmt.Fprintf(&body, "- %s: %s → %s (%s)\n", u.ID(), u.FromVersion, u.ToVersion, u.Importance)
+	}
+
+	steps := [][]string{
+		{"checkout", "-B", branch},
+		{"-c", "user.name=helmwave-updater", "-c", "user.email=helmwave-updater@users.noreply.github.com", "commit", "-a", "-m", title, "-m", body.String()},
+		{"push", "--force", "origin", branch},
+	}
+	for _, args := range steps {
+		if err := gitRun(ctx, c.workDir, args...); err != nil {
+			return err
+		}
+	}
+
+	owner, repo, ok := githubRepo(gitRepo)
+	if !ok {
+		logWarnf("⚠️ pushed %s, but %s is not a GitHub repository; open the pull request by hand", branch, gitRepo)
+		return nil
+	}
+	url, err := createPullRequest(ctx, owner, repo, branch, gitBranch, title, body.String())
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		logInfof("updated the open pull request for %s", branch)
+	} else {
+		logInfof("opened pull request %s", url)
+	}
+	return nil
+}
+
+// githubRepoPattern matches https and ssh GitHub remotes.
+var githubRepoPattern = regexp.MustCompile(`^(?:https://(?:[^@/]+@)?github\.com/|git@github\.com:|ssh://git@github\.com/)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// githubRepo returns the owner and name of a GitHub remote URL.
+func githubRepo(url string) (owner, repo string, ok bool) {
+	m := githubRepoPattern.FindStringSubmatch(url)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// createPullRequest opens a pull request from head to base with GITHUB_TOKEN and returns
+// its URL, or "" when one is already open for head.
+func createPullRequest(ctx context.Context, owner, repo, head, base, title, body string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"title": title, "head": head, "base": base, "body": body})
+	if err != nil {
+		return "", err
+	}
+	apiURL := strings.TrimSuffix(firstNonEmpty(os.Getenv("GITHUB_API_URL"), githubAPIURL), "/")
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", apiURL, owner, repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "helmwave-updater/"+version)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	providerAuth{token: githubToken()}.apply(req)
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusCreated:
+		var pr struct {
+			HTMLURL string `json:"html_url"`
+		}
+		if err := json.Unmarshal(respBody, &pr); err != nil {
+			return "", fmt.Errorf("POST %s: %w", url, err)
+		}
+		return pr.HTMLURL, nil
+	case resp.StatusCode == http.StatusUnprocessableEntity && bytes.Contains(respBody, []byte("already exists")):
+		return "", nil
+	}
+	return "", fmt.Errorf("POST %s: %s: %s", url, resp.Status, strings.TrimSpace(string(respBody)))
+}
+
+// gitRun runs git in dir ("" for the current directory) without prompting for credentials.
+func gitRun(ctx context.Context, dir string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// limit caps the number of updates applied per run (-limit); 0 applies all
+var limit int
+
+// limitOrder picks the updates -limit keeps: importance (major first) or oldest
+// (longest since the last bump recorded in the state file)
+var limitOrder = "importance"
+
+// importanceRank orders update importances for -limit; unknown labels come last.
+var importanceRank = map[string]int{"major": 0, "minor": 1, "patch": 2}
+
+// applyLimit keeps the first limit updates in limitOrder and turns the others into skipped
+// results, restoring their releases in hw so the file is not edited for them.
+func applyLimit(hw *Helmwave, result checkResult) (checkResult, error) {
+	if limit <= 0 {
+		return result, nil
+	}
+	var updated []int
+	for i, r := range result.Releases {
+		if r.Status == statusUpdated {
+			updated = append(updated, i)
+		}
+	}
+	if len(updated) <= limit {
+		return result, nil
+	}
+
+	var key func(u releaseUpdate) int64
+	switch limitOrder {
+	case "importance":
+		key = func(u releaseUpdate) int64 {
+			if rank, ok := importanceRank[u.Importance]; ok {
+				return int64(rank)
+			}
+			return int64(len(importanceRank))
+		}
+	case "oldest":
+		// releases never bumped sort first (zero time)
+		key = func(u releaseUpdate) int64 { return bumps.Releases[u.ID()].LastBumped.Unix() }
+	default:
+		return result, fmt.Errorf("unknown -limit-order %q (expected importance or oldest)", limitOrder)
+	}
+	slices.SortStableFunc(updated, func(a, b int) int {
+		ka, kb := key(result.Releases[a].Update), key(result.Releases[b].Update)
+		switch {
+		case ka < kb:
+			return -1
+		case ka > kb:
+			return 1
+		}
+		return 0
+	})
+
+	for _, i := range updated[limit:] {
+		u := result.Releases[i].Update
+		restoreRelease(hw, u)
+		logDebugf("release %s: update to %s held back by -limit %d", u.Release, u.ToVersion, limit)
+		held := Release{Name: u.Release, Namespace: u.Namespace, Context: u.Context, Chart: Chart{Name: u.Chart, Version: u.FromVersion}, Tags: u.Tags}
+		result.Releases[i] = skippedResult(held, updater.ReasonLimit, fmt.Sprintf("%s available, held back by -limit %d", u.ToVersion, limit))
+	}
+	return result, nil
+}
+
+// restoreRelease undoes the in-memory update u of a release in hw. Dependency updates
+// only live in the result.
+func restoreRelease(hw *Helmwave, u releaseUpdate) {
+	if u.File != "" {
+		return
+	}
+	for id := range hw.Releases {
+		if hw.Releases[id].ID() != u.ID() {
+			continue
+		}
+		if hw.Releases[id].Chart.Name != u.Chart {
+			// git charts carry the version as the ref in chart.name
+			hw.Releases[id].Chart.Name = u.Chart
+		} else {
+			hw.Releases[id].Chart.Version = u.FromVersion
+		}
+		return
+	}
+}
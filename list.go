@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// listMode prints a table of every release instead of writing the updated file (-list)
+var listMode bool
+
+// outdatedMode prints only outdated releases, most important first, instead of writing the updated file (-outdated)
+var outdatedMode bool
+
+// tableMode reports whether -list or -outdated replace the update report and file output.
+func tableMode() bool {
+	return listMode || outdatedMode
+}
+
+// listSort orders the -list table: file, name or staleness (furthest behind first)
+var listSort = "file"
+
+// printList writes one row per release: chart, current and latest version, appVersion,
+// status, how far it is behind and its tags.
+func printList(out io.Writer, c checkResult) error {
+	rows := slices.Clone(c.Releases)
+	switch listSort {
+	case "", "file":
+	case "name":
+		slices.SortStableFunc(rows, func(a, b releaseResult) int { return cmp.Compare(a.Release, b.Release) })
+	case "staleness":
+		slices.SortStableFunc(rows, func(a, b releaseResult) int {
+			return cmp.Or(cmp.Compare(b.DaysBehind, a.DaysBehind), cmp.Compare(b.Behind, a.Behind))
+		})
+	default:
+		return fmt.Errorf("unknown -list-sort %q (expected file, name or staleness)", listSort)
+	}
+
+	pauseProgress()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, tr("RELEASE\tCHART\tVERSION\tLATEST\tAPPVERSION\tSTATUS\tBEHIND\tTAGS"))
+	for _, r := range rows {
+		status := string(r.Status)
+		if r.Status == statusUpdated {
+			// nothing is written in list mode
+			status = "outdated"
+		}
+		if r.Code != "" {
+			status += " (" + r.Code + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Release, r.Chart, orDash(r.Version), orDash(r.Latest),
+			appVersionCell(r.CurrentAppVersion, r.LatestAppVersion), status,
+			behindCell(r), orDash(strings.Join(r.Tags, ",")))
+	}
+	return w.Flush()
+}
+
+// printOutdated writes a compact table of the outdated releases ordered by importance
+// (major first), then by days and versions behind.
+func printOutdated(out io.Writer, c checkResult) error {
+	var rows []releaseResult
+	for _, r := range c.Releases {
+		if r.Status == statusUpdated {
+			rows = append(rows, r)
+		}
+	}
+	rank := func(r releaseResult) int {
+		if n, ok := importanceRank[r.Update.Importance]; ok {
+			return n
+		}
+		return len(importanceRank)
+	}
+	slices.SortStableFunc(rows, func(a, b releaseResult) int {
+		return cmp.Or(cmp.Compare(rank(a), rank(b)), cmp.Compare(b.DaysBehind, a.DaysBehind), cmp.Compare(b.Behind, a.Behind))
+	})
+
+	pauseProgress()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, r := range rows {
+		// the colored column comes last so escape codes do not break the alignment
+		fmt.Fprintf(w, "%s\t%s %s %s\t%s\t%s\n", r.Release, r.Version, icons("→"), r.Update.ToVersion,
+			behindCell(r), colorize(importanceColors[r.Update.Importance], r.Update.Importance))
+	}
+	return w.Flush()
+}
+
+// importanceColors highlight update importances like the update report does.
+var importanceColors = map[string]string{"major": colorRed, "minor": colorYellow, "patch": colorGreen}
+
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+func appVersionCell(current, latest string) string {
+	if current == latest || latest == "" {
+		return orDash(current)
+	}
+	return orDash(current) + " " + icons("→") + " " + latest
+}
+
+func behindCell(r releaseResult) string {
+	switch {
+	case r.Behind == 0:
+		return "-"
+	case r.DaysBehind > 0:
+		return fmt.Sprintf("%d (%dd)", r.Behind, r.DaysBehind)
+	}
+	return fmt.Sprint(r.Behind)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/cli"
+	"helm.sh/helm/v4/pkg/registry"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// providerLocal marks releases whose chart is a directory next to the helmwave file;
+// they have no upstream to check.
+const providerLocal = "local"
+
+// localDeps also checks the dependencies of local charts and updates their Chart.yaml (-local-deps)
+var localDeps bool
+
+// ignoreLocalCharts leaves releases with local charts out of the report (-ignore-local-charts)
+var ignoreLocalCharts bool
+
+// localChartDir resolves a local chart name against dir, the helmwave file's directory.
+func localChartDir(dir, name string) string {
+	name = strings.TrimSpace(name)
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// readLocalChart reads the Chart.yaml of a local chart.
+func readLocalChart(dir, name string) (*chart.Metadata, error) {
+	data, err := os.ReadFile(localChartFile(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var meta chart.Metadata
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// localChartReason describes a local chart for the skip report, with its Chart.yaml version when readable.
+func localChartReason(dir, name string) string {
+	meta, err := readLocalChart(dir, name)
+	if err != nil {
+		logDebugf("local chart %s: %v", name, err)
+		return "local chart"
+	}
+	if meta.AppVersion != "" {
+		return "local chart " + meta.Name + " " + meta.Version + " (app " + meta.AppVersion + ")"
+	}
+	return "local chart " + meta.Name + " " + meta.Version
+}
+
+// localChartFile returns the Chart.yaml path of a local chart.
+func localChartFile(dir, name string) string {
+	return filepath.Join(localChartDir(dir, name), "Chart.yaml")
+}
+
+// dependencyChartName maps a Chart.yaml dependency to the chart name it is looked up by:
+// repo/chart for aliases and repository URLs configured in helm (entries), and
+// oci://registry/path/chart for OCI repositories.
+func dependencyChartName(dep *chart.Dependency, entries []*repo.Entry) (string, error) {
+	repository := strings.TrimSpace(dep.Repository)
+	switch {
+	case repository == "" || strings.HasPrefix(repository, "file://"):
+		return "", fmt.Errorf("dependency %s is a local chart", dep.Name)
+	case strings.HasPrefix(repository, "@") || strings.HasPrefix(repository, "alias:"):
+		return strings.TrimPrefix(strings.TrimPrefix(repository, "@"), "alias:") + "/" + dep.Name, nil
+	case strings.HasPrefix(repository, registry.OCIScheme+"://"):
+		return strings.TrimSuffix(repository, "/") + "/" + dep.Name, nil
+	}
+	for _, e := range entries {
+		if strings.TrimSuffix(e.URL, "/") == strings.TrimSuffix(repository, "/") {
+			return e.Name + "/" + dep.Name, nil
+		}
+	}
+	return "", fmt.Errorf("repository %s of dependency %s is not configured (helm repo add)", repository, dep.Name)
+}
+
+// localDependency is one Chart.yaml dependency of a release's local chart.
+type localDependency struct {
+	release Release
+	file    string
+	dep     *chart.Dependency
+	// chartName is the lookup name (see dependencyChartName); empty with err set when unresolvable
+	chartName string
+	err       error
+}
+
+// collectLocalDependencies lists the dependencies of every updatable release's local chart;
+// dir is the helmwave file's directory.
+func collectLocalDependencies(dir string, releases []Release, entries []*repo.Entry) []localDependency {
+	var deps []localDependency
+	for _, r := range releases {
+		if !updater.IsLocalChart(r.Chart.Name) {
+			continue
+		}
+		meta, err := readLocalChart(dir, r.Chart.Name)
+		if err != nil {
+			logWarnf("release %s: cannot read local chart: %v", r.Name, err)
+			continue
+		}
+		for _, dep := range meta.Dependencies {
+			if dep == nil {
+				continue
+			}
+			chartName, err := dependencyChartName(dep, entries)
+			deps = append(deps, localDependency{release: r, file: localChartFile(dir, r.Chart.Name), dep: dep, chartName: chartName, err: err})
+		}
+	}
+	return deps
+}
+
+// localDependencyEntries returns the configured repositories used to map dependency URLs;
+// with -index-dir there are none and only aliases resolve.
+func localDependencyEntries() []*repo.Entry {
+	if indexDir != "" {
+		return nil
+	}
+	entries, err := repoEntries(cli.New())
+	if err != nil {
+		logDebugf("no repositories for local chart dependencies: %v", err)
+	}
+	return entries
+}
+
+// addDependencyCharts adds the repository charts local chart dependencies need to wanted.
+func addDependencyCharts(wanted chartSet, dir string, releases []Release) {
+	for _, d := range collectLocalDependencies(dir, releases, localDependencyEntries()) {
+		repoName, chartName, ok := updater.SplitRepoChart(d.chartName)
+		if !ok {
+			continue
+		}
+		if wanted[repoName] == nil {
+			wanted[repoName] = make(map[string]bool)
+		}
+		wanted[repoName][chartName] = true
+	}
+}
+
+// processLocalDependency checks a dependency of a local chart; an update's File is the
+// Chart.yaml it applies to. Its report lines go to w.
+func processLocalDependency(ctx context.Context, d localDependency, providers *providerSet, w io.Writer) releaseResult {
+	lookup := d.release
+	lookup.Chart = updater.Chart{Name: d.chartName, Version: d.dep.Version}
+	label := "dependency " + d.dep.Name
+	if d.err != nil {
+		lookup.Chart.Name = d.dep.Name
+		return skippedResult(lookup, updater.ReasonChartName, label+": "+d.err.Error())
+	}
+	if _, err := semver.StrictNewVersion(strings.TrimPrefix(d.dep.Version, "v")); err != nil {
+		logDebugf("release %s: %s has version constraint %q; not updated", d.release.Name, label, d.dep.Version)
+		return skippedResult(lookup, updater.ReasonConstraint, label+": version constraint "+d.dep.Version)
+	}
+
+	var provider updater.VersionProvider = providers.index
+	if strings.HasPrefix(d.chartName, registry.OCIScheme+"://") {
+		if offline {
+			return skippedResult(lookup, updater.ReasonOffline, label+": OCI lookup disabled in offline mode")
+		}
+		provider = providers.oci
+	}
+	resolved, err := provider.Resolve(ctx, lookup)
+	if err != nil {
+		logWarnf("release %s: %s (%s): %v", d.release.Name, label, d.chartName, err)
+		return failedResult(lookup, updater.FailureReason(err), label+": "+redactSecrets(err.Error()))
+	}
+	if strings.TrimPrefix(d.dep.Version, "v") == resolved.LatestVersion {
+		return upToDateResult(lookup)
+	}
+	if humanReport() {
+		fmt.Fprintf(w, tr("\nRelease: %s, Dependency: %s (%s), Version: %s\n"), d.release.Name, d.dep.Name, d.chartName, d.dep.Version)
+		fmt.Fprintf(w, tr("   Update available: %s -> %s \n"), d.dep.Version, resolved.LatestVersion)
+	}
+	update := updater.NewReleaseUpdate(lookup, resolved.LatestVersion, resolved.CurrentAppVersion, resolved.LatestAppVersion)
+	update.File = d.file
+	return updatedResult(update)
+}
+
+// writeLocalDependencyUpdates applies dependency updates to each Chart.yaml (or a .updated
+// copy unless inplace) and returns the files written; with -output patch the edits go to
+// the patch instead.
+func writeLocalDependencyUpdates(updates []releaseUpdate, inplace bool) ([]string, error) {
+	byFile := make(map[string]map[string]string)
+	for _, u := range updates {
+		if u.File == "" {
+			continue
+		}
+		if byFile[u.File] == nil {
+			byFile[u.File] = make(map[string]string)
+		}
+		byFile[u.File][path.Base(u.Chart)] = u.ToVersion
+	}
+	var written []string
+	for _, file := range sortedKeys(byFile) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return written, err
+		}
+		outFile, err := writeEdited(file, updater.UpdateDependencyVersions(data, byFile[file]), inplace)
+		if err != nil {
+			return written, err
+		}
+		if outFile != "" {
+			written = append(written, outFile)
+		}
+	}
+	if len(written) > 0 && inplace {
+		logInfof("run `helm dependency update` in the updated charts to refresh Chart.lock")
+	}
+	return written, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var logLevel = "info"
+var logFormat = "text"
+
+var logFile string
+
+// logLevelSet reports whether -log-level was passed explicitly (so -quiet does not override it)
+var logLevelSet bool
+
+// addLoggingFlags registers the logging flags shared by the main command and its subcommands.
+func addLoggingFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&verbose, "verbose", false, "enable verbose logging (same as -log-level debug)")
+	fs.StringVar(&logLevel, "log-level", logLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", logFormat, "log format: text or json")
+	fs.StringVar(&logFile, "log-file", "", "also append all log output (including debug diagnostics) to this file")
+	fs.StringVar(&lang, "lang", "", "language of human-readable output: en or ru (default from LC_ALL, LC_MESSAGES or LANG)")
+	fs.BoolVar(&noEmoji, "no-emoji", false, "write plain text instead of icons (⚠️, →, spinner) in logs and output")
+}
+
+// applyLoggingFlags reconfigures logging once fs has been parsed.
+func applyLoggingFlags(fs *flag.FlagSet) error {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "log-level" {
+			logLevelSet = true
+		}
+	})
+	if err := setupLanguage(); err != nil {
+		return err
+	}
+	return setupLogging()
+}
+
+// setupLogging installs the default slog logger according to -log-level / -log-format.
+// Diagnostics always go to stderr so that stdout carries only human/machine output.
+// -verbose is kept as a shortcut for -log-level debug; -quiet lowers the default level to error.
+// With -log-file, every record (including debug diagnostics) is additionally appended to that file.
+// Credentials are masked in every record (see redactSecrets).
+func setupLogging() error {
+	registerEnvSecrets()
+	if logFile == "" {
+		return setupLoggingTo(os.Stderr, nil)
+	}
+	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	return setupLoggingTo(os.Stderr, f)
+}
+
+func setupLoggingTo(w io.Writer, file io.Writer) error {
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet && !logLevelSet {
+		level = slog.LevelError
+	}
+
+	handler, err := newLogHandler(w, level)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		fileHandler, err := newLogHandler(file, slog.LevelDebug)
+		if err != nil {
+			return err
+		}
+		handler = multiHandler{handler, fileHandler}
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func newLogHandler(w io.Writer, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(strings.TrimSpace(logFormat)) {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (expected text or json)", logFormat)
+}
+
+// multiHandler fans records out to several handlers, each applying its own level.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", s)
+}
+
+// warningCount counts warning-level records; it feeds the exit code contract. warningMu
+// guards it while releases are resolved concurrently.
+var (
+	warningCount int
+	warningMu    sync.Mutex
+)
+
+func logf(level slog.Level, format string, args ...interface{}) {
+	if level == slog.LevelWarn {
+		warningMu.Lock()
+		warningCount++
+		warningMu.Unlock()
+	}
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	withProgressCleared(func() {
+		logger.Log(context.Background(), level, redactSecrets(icons(fmt.Sprintf(format, args...))))
+	})
+}
+
+// logDebugf logs verbose diagnostics (shown with -log-level debug or -verbose)
+func logDebugf(format string, args ...interface{}) { logf(slog.LevelDebug, format, args...) }
+
+func logInfof(format string, args ...interface{}) { logf(slog.LevelInfo, format, args...) }
+
+func logWarnf(format string, args ...interface{}) { logf(slog.LevelWarn, format, args...) }
+
+func logErrorf(format string, args ...interface{}) { logf(slog.LevelError, format, args...) }
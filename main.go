@@ -1,30 +1,68 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"helm.sh/helm/v4/pkg/cli"
 	"helm.sh/helm/v4/pkg/getter"
 	"helm.sh/helm/v4/pkg/registry"
 	repo "helm.sh/helm/v4/pkg/repo/v1"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
 
-	semver "github.com/Masterminds/semver/v3"
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var filename string
 var inplace bool
 var verbose bool
 var noRepoUpdate bool
+var quiet bool
+
+// indexDir points at pre-downloaded index files (<repo>-index.yaml, <repo>.yaml or <repo>/index.yaml)
+var indexDir string
+
+// offline forbids any network access: no repo update, no OCI lookups, no update check
+var offline bool
+
+// pinDigest pins every updated OCI chart by digest (-pin-digest), not only those already pinned
+var pinDigest bool
+
+// stepMode proposes one step instead of the latest version (-step): next or minor
+var stepMode string
+
+// annotate appends "# was <old> (updated <date>)" to every edited line (-annotate)
+var annotate bool
+
+// appVersionComments writes "# appVersion: <appVersion>" on the version line of every release (-app-version-comment)
+var appVersionComments bool
+
+// allowLicenseChange applies updates to a version declaring another license instead of
+// holding them back (-allow-license-change)
+var allowLicenseChange bool
+
+// workers bounds how many releases are resolved concurrently (-workers)
+var workers = 4
+
+// kubeContext limits the run to releases targeting this kube context (-context)
+var kubeContext string
 
 // version is populated at build time via -ldflags "-X main.version=..."
 var version = "dev"
 
 // tag that disables updating for a release (case-insensitive)
-const NoupdateTag = "noupdate"
+const NoupdateTag = updater.NoupdateTag
 
 // ANSI color codes for terminal output
 const (
@@ -32,182 +70,703 @@ const (
 	colorRed    = "\033[31m"
 	colorYellow = "\033[33m"
 	colorGreen  = "\033[32m"
+	colorCyan   = "\033[36m"
+	// colorReverse is combined with a color to highlight words within a colored line
+	colorReverse = "\033[7m"
 )
 
-// vlog and hasTag are provided by helpers.go
+// logDebugf and friends are provided by logging.go
 
-// updateRepos runs the equivalent of `helm repo update` for all configured repositories.
-func updateRepos(settings *cli.EnvSettings) {
-	f, err := repo.LoadFile(settings.RepositoryConfig)
+// updateRepos runs the equivalent of `helm repo update` for the repositories in wanted
+// (all configured repositories when wanted is nil).
+func updateRepos(ctx context.Context, settings *cli.EnvSettings, wanted chartSet) {
+	ctx, span := startSpan(ctx, "updateRepos")
+	defer span.End()
+
+	if wanted != nil && len(wanted) == 0 {
+		logDebugf("no release uses a helm repository; nothing to update")
+		return
+	}
+
+	entries, err := repoEntries(settings)
 	if err != nil {
-		log.Printf("⚠️ failed to load repo file for update: %v", err)
+		spanError(span, err)
+		repoFailures++
+		logWarnf("⚠️ failed to load repo file for update: %v", err)
 		return
 	}
 	providers := getter.All(settings)
-	for _, entry := range f.Repositories {
-		vlog("updating repo %s (%s)", entry.Name, entry.URL)
-		r, err := repo.NewChartRepository(entry, providers)
+	var selected []*repo.Entry
+	for _, entry := range entries {
+		if !wanted.has(entry.Name) {
+			logDebugf("skipping update of repo %s: not referenced by any release", entry.Name)
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	p := startProgress("updating repositories", len(selected))
+	defer p.finish()
+	for _, entry := range selected {
+		if ctx.Err() != nil {
+			return
+		}
+		p.step(entry.Name)
+		updateRepo(ctx, settings, providers, entry)
+	}
+}
+
+// updateRepo downloads a fresh index file for a single repository entry.
+func updateRepo(ctx context.Context, settings *cli.EnvSettings, providers getter.Providers, entry *repo.Entry) {
+	ctx, span := startSpan(ctx, "updateRepo", attribute.String("repo.name", entry.Name), attribute.String("repo.url", entry.URL))
+	defer span.End()
+
+	if _, age, fresh := freshCachedIndex(entry.URL); fresh {
+		span.SetAttributes(attribute.Bool("repo.cached", true))
+		logInfof("repo %s: using cached index (age %s)", entry.Name, age.Round(time.Second))
+		return
+	}
+
+	logDebugf("updating repo %s (%s)", entry.Name, entry.URL)
+	if isHTTPRepo(entry) {
+		notModified := false
+		err := withRetry(ctx, "update repo "+entry.Name, func() error {
+			err := fetchIndex(ctx, settings.RepositoryCache, entry)
+			if errors.Is(err, errIndexNotModified) {
+				notModified = true
+				return nil
+			}
+			return err
+		})
 		if err != nil {
-			log.Printf("⚠️ failed to init repo %s: %v", entry.Name, err)
+			spanError(span, err)
+			repoFailures++
+			logWarnf("⚠️ failed to update repo %s: %v", entry.Name, err)
+			return
+		}
+		span.SetAttributes(attribute.Bool("repo.not_modified", notModified))
+		storeCachedIndex(entry.URL, indexCachePath(settings.RepositoryCache, entry.Name))
+		if notModified {
+			logInfof("repo %s is up to date (not modified)", entry.Name)
+			return
+		}
+		logInfof("updated repo %s", entry.Name)
+		return
+	}
+
+	r, err := repo.NewChartRepository(entry, providers)
+	if err != nil {
+		spanError(span, err)
+		repoFailures++
+		logWarnf("⚠️ failed to init repo %s: %v", entry.Name, err)
+		return
+	}
+	r.CachePath = settings.RepositoryCache
+	var indexPath string
+	err = withRetry(ctx, "update repo "+entry.Name, func() error {
+		var err error
+		indexPath, err = r.DownloadIndexFile()
+		return err
+	})
+	if err != nil {
+		spanError(span, err)
+		repoFailures++
+		logWarnf("⚠️ failed to update repo %s: %v", entry.Name, err)
+		return
+	}
+	storeCachedIndex(entry.URL, indexPath)
+	logInfof("updated repo %s", entry.Name)
+}
+
+// refreshStaleRepos re-fetches referenced repositories whose cached index is older than
+// autoRefreshOlderThan (or missing), even when a full repo update was skipped.
+func refreshStaleRepos(ctx context.Context, settings *cli.EnvSettings, wanted chartSet) {
+	entries, err := repoEntries(settings)
+	if err != nil {
+		logWarnf("⚠️ failed to load repo file for refresh: %v", err)
+		return
+	}
+	providers := getter.All(settings)
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if !wanted.has(entry.Name) {
 			continue
 		}
-		r.CachePath = settings.RepositoryCache
-		if _, err := r.DownloadIndexFile(); err != nil {
-			log.Printf("⚠️ failed to update repo %s: %v", entry.Name, err)
+		age, ok := indexAge(indexPathFor(settings, entry))
+		if ok && age <= autoRefreshOlderThan {
 			continue
 		}
-		log.Printf("updated repo %s", entry.Name)
+		logInfof("index of repo %s is stale (age %s), refreshing", entry.Name, age.Round(time.Second))
+		updateRepo(ctx, settings, providers, entry)
 	}
 }
 
+// indexPathFor returns the index file used for entry: the -cache-ttl cache when it has one,
+// helm's repository cache otherwise.
+func indexPathFor(settings *cli.EnvSettings, entry *repo.Entry) string {
+	if cacheTTL > 0 {
+		if cached, err := cachedIndexPath(entry.URL); err == nil {
+			if _, err := os.Stat(cached); err == nil {
+				return cached
+			}
+		}
+	}
+	return indexCachePath(settings.RepositoryCache, entry.Name)
+}
+
 // loadIndexes loads helm repo index files from settings repository cache.
-func loadIndexes(settings *cli.EnvSettings) (map[string]*repo.IndexFile, error) {
+// When wanted is non-nil only the referenced chart entries of each index are parsed.
+func loadIndexes(ctx context.Context, settings *cli.EnvSettings, wanted chartSet) (map[string]*repo.IndexFile, error) {
+	_, span := startSpan(ctx, "loadIndexes")
+	defer span.End()
+
+	if indexDir != "" {
+		return loadIndexDir(indexDir, wanted)
+	}
+	if wanted != nil && len(wanted) == 0 {
+		logDebugf("no release uses a helm repository; no indexes to load")
+		return map[string]*repo.IndexFile{}, nil
+	}
+
 	indexes := make(map[string]*repo.IndexFile)
-	repoFile := filepath.Join(settings.RepositoryConfig)
-	vlog("loading repository config from %s", repoFile)
-	f, err := repo.LoadFile(repoFile)
+	logDebugf("loading repository config from %s", settings.RepositoryConfig)
+	entries, err := repoEntries(settings)
 	if err != nil {
+		spanError(span, err)
 		return nil, err
 	}
-	vlog("found %d repositories in repo file", len(f.Repositories))
-	for _, entry := range f.Repositories {
-		idxPath := filepath.Join(settings.RepositoryCache, fmt.Sprintf("%s-index.yaml", entry.Name))
-		vlog("loading index for repo %s from %s", entry.Name, idxPath)
-		idx, err := repo.LoadIndexFile(idxPath)
+	logDebugf("found %d repositories in repo file and helmwave file", len(entries))
+	p := startProgress("loading indexes", len(entries))
+	defer p.finish()
+	for _, entry := range entries {
+		p.step(entry.Name)
+		if !wanted.has(entry.Name) {
+			logDebugf("skipping index of repo %s: not referenced by any release", entry.Name)
+			continue
+		}
+		idxPath := indexPathFor(settings, entry)
+		logDebugf("loading index for repo %s from %s", entry.Name, idxPath)
+		warnIfStale(entry.Name, idxPath)
+		idx, err := loadIndexFileCached(idxPath, wantedCharts(wanted, entry.Name))
 		if err != nil {
-			log.Printf("⚠️ failed to load index %s: %v", entry.Name, err)
+			repoFailures++
+			logWarnf("⚠️ failed to load index %s: %v", entry.Name, err)
 			continue
 		}
 		indexes[entry.Name] = idx
 		if idx != nil {
-			vlog("loaded index for %s: %d entries", entry.Name, len(idx.Entries))
+			logDebugf("loaded index for %s: %d entries", entry.Name, len(idx.Entries))
 		}
 	}
+	span.SetAttributes(attribute.Int("indexes.loaded", len(indexes)))
 	return indexes, nil
 }
 
-// processReleases compares releases with repo indexes and updates in-memory versions.
-func processReleases(hw *Helmwave, indexes map[string]*repo.IndexFile) {
-	var helmwaveTags []string
-	var ociClient *registry.Client
-	var ociClientErr error
-	var ociClientInitialized bool
+// ociConn is a helm registry client together with the authorizer behind it; the authorizer
+// is also used directly for calls helm doesn't offer, such as listing raw tags.
+type ociConn struct {
+	client     *registry.Client
+	authorizer *auth.Client
+}
 
-	for id, release := range hw.Releases {
-		vlog("processing release[%d]: name=%q chart=%q version=%q", id, release.Name, release.Chart.Name, release.Chart.Version)
+// newOCIConn creates a registry connection honoring chart-level TLS options and
+// authenticating through newRegistryAuthorizer.
+func newOCIConn(opts tlsOptions) (*ociConn, error) {
+	httpClient := &http.Client{Transport: limitedTransport{base: registry.NewTransport(false)}}
+	if !opts.isZero() {
+		var err error
+		httpClient, err = opts.httpClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+	authorizer := newRegistryAuthorizer(httpClient)
+	client, err := registry.NewClient(
+		registry.ClientOptHTTPClient(httpClient),
+		registry.ClientOptAuthorizer(*authorizer),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ociConn{client: client, authorizer: authorizer}, nil
+}
 
-		if hasTag(release.Tags, NoupdateTag) {
-			vlog("skipping release %s because it has tag '%s'", release.Name, NoupdateTag)
+// loadIndexDir loads every index file found in dir, deriving the repo name from the file name.
+func loadIndexDir(dir string, wanted chartSet) (map[string]*repo.IndexFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string]*repo.IndexFile)
+	for _, e := range entries {
+		var name, idxPath string
+		switch {
+		case e.IsDir():
+			name, idxPath = e.Name(), filepath.Join(dir, e.Name(), "index.yaml")
+			if _, err := os.Stat(idxPath); err != nil {
+				continue
+			}
+		case strings.HasSuffix(e.Name(), "-index.yaml"):
+			name, idxPath = strings.TrimSuffix(e.Name(), "-index.yaml"), filepath.Join(dir, e.Name())
+		case strings.HasSuffix(e.Name(), ".yaml"):
+			name, idxPath = strings.TrimSuffix(e.Name(), ".yaml"), filepath.Join(dir, e.Name())
+		default:
 			continue
 		}
-
-		if release.Chart.Name == "" {
-			log.Printf("skipping release %q: empty chart.name", release.Name)
+		if _, exists := indexes[name]; exists || !wanted.has(name) {
 			continue
 		}
+		logDebugf("loading index for repo %s from %s", name, idxPath)
+		warnIfStale(name, idxPath)
+		idx, err := loadIndexFileCached(idxPath, wantedCharts(wanted, name))
+		if err != nil {
+			repoFailures++
+			logWarnf("⚠️ failed to load index %s: %v", name, err)
+			continue
+		}
+		indexes[name] = idx
+	}
+	logDebugf("loaded %d indexes from %s", len(indexes), dir)
+	return indexes, nil
+}
 
-		if strings.HasPrefix(release.Chart.Name, registry.OCIScheme+"://") {
-			if !ociClientInitialized {
-				ociClient, ociClientErr = registry.NewClient(registry.ClientOptEnableCache(true))
-				ociClientInitialized = true
+// processReleases compares releases with repo indexes, updates in-memory versions
+// and returns the per-release outcomes in release order. file is the helmwave file hw was
+// read from; local chart paths are relative to its directory.
+// Updates beyond -limit are held back by applyLimit.
+// It stops early with ctx's error when the run is cancelled.
+func processReleases(ctx context.Context, file string, hw *Helmwave, indexes map[string]*repo.IndexFile) (checkResult, error) {
+	ctx, span := startSpan(ctx, "processReleases", attribute.Int("releases.count", len(hw.Releases)))
+	defer span.End()
+
+	var result checkResult
+	// OCI connections are shared between releases with the same TLS settings
+	ociConns := make(map[string]*ociConn)
+	var ociConnsMu sync.Mutex
+	getOCIConn := func(release Release) (*ociConn, error) {
+		opts := chartTLSOptions(release.Chart)
+		ociConnsMu.Lock()
+		defer ociConnsMu.Unlock()
+		if c, ok := ociConns[opts.key()]; ok {
+			return c, nil
+		}
+		c, err := newOCIConn(opts)
+		if err != nil {
+			return nil, err
+		}
+		ociConns[opts.key()] = c
+		return c, nil
+	}
+
+	providers := newProviderSet(indexes, getOCIConn)
+	providers.localDir = filepath.Dir(file)
+	p := startProgress("checking releases", len(hw.Releases))
+	defer p.finish()
+
+	// up to -workers releases are resolved at once; each one's output is buffered and
+	// flushed in release order, so results and stdout read the same as a serial run
+	type releaseJob struct {
+		result  releaseResult
+		checked bool
+		out     bytes.Buffer
+		done    chan struct{}
+	}
+	jobs := make([]releaseJob, len(hw.Releases))
+	for id := range jobs {
+		jobs[id].done = make(chan struct{})
+	}
+	slots := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for id := range jobs {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots; close(jobs[id].done) }()
+				release := hw.Releases[id]
+				if ctx.Err() != nil || (ignoreLocalCharts && updater.IsLocalChart(release.Chart.Name)) {
+					return
+				}
+				if !inKubeContext(release) {
+					logDebugf("skipping release %s: context %q is not %q", release.Name, release.Context, kubeContext)
+					return
+				}
+				p.step(release.Name)
+				jobs[id].result, jobs[id].checked = processRelease(ctx, hw, id, providers, &jobs[id].out), true
+			}()
+		}
+	}()
+	// with -limit the output is held until applyLimit has run, so updates it holds back
+	// are not announced as available; outs[i] is the output of result.Releases[i]
+	var outs []*bytes.Buffer
+	flush := func(out *bytes.Buffer) {
+		if out.Len() > 0 {
+			pauseProgress()
+			_, _ = os.Stdout.Write(out.Bytes())
+		}
+	}
+	for id := range jobs {
+		<-jobs[id].done
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if limit <= 0 {
+			flush(&jobs[id].out)
+		}
+		if jobs[id].checked {
+			result.Releases = append(result.Releases, jobs[id].result)
+			outs = append(outs, &jobs[id].out)
+		}
+	}
+	if localDeps {
+		for _, d := range collectLocalDependencies(providers.localDir, updatableReleases(hw), localDependencyEntries()) {
+			if ctx.Err() != nil {
+				break
 			}
-			if ociClientErr != nil {
-				log.Printf("failed to initialize OCI registry client (release %s): %v", release.Name, ociClientErr)
-				continue
+			out := new(bytes.Buffer)
+			result.Releases = append(result.Releases, processLocalDependency(ctx, d, providers, out))
+			outs = append(outs, out)
+			if limit <= 0 {
+				flush(out)
 			}
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+	if limit <= 0 {
+		return result, nil
+	}
 
-			lastVersion, err := latestOCIVersion(ociClient, release.Chart.Name)
-			if err != nil {
-				log.Printf("failed to get OCI tags for %q (release %s): %v", release.Chart.Name, release.Name, err)
-				continue
-			}
+	result, err := applyLimit(hw, result)
+	if err != nil {
+		return result, err
+	}
+	for i, r := range result.Releases {
+		flush(outs[i])
+		if r.Code == updater.ReasonLimit && humanReport() {
+			fmt.Printf(tr("   Held back by -limit %d\n"), limit)
+		}
+	}
+	return result, nil
+}
 
-			if release.Chart.Version == "" {
-				log.Printf("release %s: chart version not specified, skipping comparison", release.Name)
-				continue
-			}
+// processRelease resolves the latest version for hw.Releases[id] and updates it in memory.
+// Its report lines go to w.
+func processRelease(ctx context.Context, hw *Helmwave, id int, providers *providerSet, w io.Writer) (result releaseResult) {
+	release := hw.Releases[id]
+	_, span := startSpan(ctx, "processRelease",
+		attribute.String("release.name", release.Name),
+		attribute.String("chart.name", release.Chart.Name),
+		attribute.String("chart.version", release.Chart.Version),
+	)
+	defer span.End()
+
+	logDebugf("processing release[%d]: name=%q chart=%q version=%q", id, release.Name, release.Chart.Name, release.Chart.Version)
+
+	if updater.HasTag(release.Tags, NoupdateTag) {
+		logDebugf("skipping release %s because it has tag '%s'", release.Name, NoupdateTag)
+		return skippedResult(release, updater.ReasonNoupdate, NoupdateTag+" tag")
+	}
 
-			if release.Chart.Version != lastVersion {
-				currentAppVersion, latestAppVersion, appVersionErr := ociAppVersions(ociClient, release.Chart.Name, release.Chart.Version, lastVersion)
-				if appVersionErr != nil {
-					log.Printf("failed to get OCI appVersion for %q (release %s): %v", release.Chart.Name, release.Name, appVersionErr)
-				}
+	if release.Chart.Name == "" {
+		if strict {
+			return failedResult(release, updater.ReasonChartName, "empty chart.name")
+		}
+		logDebugf("skipping release %q: empty chart.name", release.Name)
+		return skippedResult(release, updater.ReasonChartName, "empty chart.name")
+	}
 
-				printReleaseUpdate(release, release.Chart.Version, lastVersion, currentAppVersion, latestAppVersion)
-				vlog("updating in-memory OCI release %s: %s -> %s", release.Name, release.Chart.Version, lastVersion)
-				hw.Releases[id].Chart.Version = lastVersion
-				if len(release.Tags) > 0 {
-					helmwaveTags = append(helmwaveTags, strings.TrimSpace(release.Tags[len(release.Tags)-1]))
-				}
-			} else {
-				vlog("OCI release %s is up-to-date (%s)", release.Name, release.Chart.Version)
+	if updater.IsSecretRef(release.Chart.Name) || updater.IsSecretRef(release.Chart.Version) {
+		logDebugf("skipping release %s: chart is a vals secret reference", release.Name)
+		return skippedResult(release, updater.ReasonSecretRef, "vals secret reference")
+	}
+
+	// with -version-vars, versions read from a variable were resolved before the run
+	if updater.IsTemplated(release.Chart.Version) {
+		logDebugf("skipping release %s: version is a template expression (%s)", release.Name, release.Chart.Version)
+		return skippedResult(release, updater.ReasonTemplated, "version "+release.Chart.Version+" is a template expression")
+	}
+
+	provider, kind, lookup, err := providers.forRelease(release)
+	span.SetAttributes(attribute.String("provider", kind))
+	if err != nil {
+		spanError(span, err)
+		logWarnf("release %s: %v", release.Name, err)
+		return failedResult(release, updater.ReasonError, redactSecrets(err.Error()))
+	}
+	if kind == providerLocal {
+		logDebugf("skipping release %s: local chart %s", release.Name, release.Chart.Name)
+		return skippedResult(release, updater.ReasonLocalChart, localChartReason(providers.localDir, release.Chart.Name))
+	}
+	if offline && kind != providerHelm {
+		label := kind
+		if kind == providerOCI {
+			label = "OCI"
+		}
+		logDebugf("skipping %s release %s: registry lookups are disabled in offline mode", label, release.Name)
+		return skippedResult(release, updater.ReasonOffline, label+" lookup disabled in offline mode")
+	}
+	if _, _, ok := updater.SplitRepoChart(lookup.Chart.Name); kind == providerHelm && !ok {
+		if strict {
+			return failedResult(release, updater.ReasonChartName, fmt.Sprintf("unexpected chart.name format %q", lookup.Chart.Name))
+		}
+		logDebugf("skipping release %q: unexpected chart.name format=%q", release.Name, lookup.Chart.Name)
+		return skippedResult(release, updater.ReasonChartName, fmt.Sprintf("unexpected chart.name format %q", lookup.Chart.Name))
+	}
+
+	resolved, err := providers.resolve(ctx, provider, kind, lookup)
+	if err != nil {
+		spanError(span, err)
+		reason := redactSecrets(err.Error()) + missingChartHint(providers.index.Indexes, err)
+		logWarnf("release %s (%s): %s", release.Name, lookup.Chart.Name, reason)
+		return failedResult(release, updater.FailureReason(err), reason)
+	}
+	lastVersion := resolved.LatestVersion
+	// every published version, before channels and policies narrow resolved.Versions
+	published := resolved.Versions
+	span.SetAttributes(attribute.String("chart.latest_version", lastVersion))
+	// every outcome after resolution records the latest version for -list
+	defer annotateLatest(&result, resolved)
+	if resolved.Deprecated {
+		repoName, chartName, _ := updater.SplitRepoChart(lookup.Chart.Name)
+		logWarnf("⚠️ release %s: chart %s is deprecated%s", release.Name, lookup.Chart.Name,
+			relocationHint(providers.index.Indexes, repoName, chartName, resolved.MovedTo))
+	}
+
+	// git charts are versioned by the ref inside chart.name
+	current := release
+	gitChart, versionedByRef := updater.ParseGitChart(release.Chart.Name)
+	versionedByRef = versionedByRef && kind == providerGit
+	if versionedByRef {
+		current.Chart.Version = gitChart.Ref
+	}
+
+	if current.Chart.Version == "" {
+		logDebugf("release %s: chart version not specified, skipping comparison", release.Name)
+		return skippedResult(release, updater.ReasonNoVersion, "chart version not specified")
+	}
+
+	if current.Chart.Version == lastVersion {
+		logDebugf("release %s is up-to-date (%s)", release.Name, current.Chart.Version)
+		return upToDateResult(release)
+	}
+
+	// channel:<name> tags decide whether prereleases are eligible for this release
+	channel := updater.ReleaseChannel(release.Tags)
+	if channel != "" {
+		if len(resolved.Versions) > 0 {
+			resolved.Versions = updater.ChannelVersions(resolved.Versions, channel)
+			newest, ok := updater.Newest(resolved.Versions)
+			if !ok {
+				return skippedResult(release, updater.ReasonChannel, fmt.Sprintf("no version in channel %s", channel))
 			}
-			continue
+			if newest.Version != lastVersion {
+				logDebugf("release %s: channel %s selects %s instead of latest %s", release.Name, channel, newest.Version, lastVersion)
+				lastVersion = newest.Version
+				resolved.LatestAppVersion = newest.AppVersion
+			}
+			if current.Chart.Version == lastVersion {
+				logDebugf("release %s is up-to-date in channel %s (%s)", release.Name, channel, lastVersion)
+				return upToDateResult(release)
+			}
+		} else if !updater.InChannel(lastVersion, channel) {
+			logDebugf("release %s: latest %s is not in channel %s and the %s source does not list other versions", release.Name, lastVersion, channel, kind)
+			return skippedResult(release, updater.ReasonChannel, fmt.Sprintf("%s not in channel %s", lastVersion, channel))
 		}
+	}
+	prereleases := channel != "" && channel != updater.ChannelStable
 
-		parts := strings.SplitN(release.Chart.Name, "/", 2)
-		if len(parts) != 2 {
-			log.Printf("skipping release %q: unexpected chart.name format=%q", release.Name, release.Chart.Name)
-			continue
+	if policy, ok := policyFor(kind, lookup); ok && len(resolved.Versions) > 0 {
+		policy.Prereleases = prereleases
+		inUse := current.Chart.Version
+		if v, ok := updater.ResolveConstraint(inUse, resolved.Versions); ok && updater.IsConstraint(inUse) {
+			inUse = v.Version
+		}
+		resolved.Versions = policy.Eligible(inUse, resolved.Versions, time.Now())
+		newest, ok := updater.Newest(resolved.Versions)
+		if !ok || newest.Version == inUse {
+			logDebugf("release %s: no version newer than %s is eligible under the repo policy (%s)", release.Name, inUse, policy)
+			return skippedResult(release, updater.ReasonPolicy, fmt.Sprintf("%s available, not eligible under repo policy (%s)", lastVersion, policy))
 		}
-		repoName, chartName := parts[0], parts[1]
+		if newest.Version != lastVersion {
+			logDebugf("release %s: repo policy (%s) selects %s instead of latest %s", release.Name, policy, newest.Version, lastVersion)
+			lastVersion = newest.Version
+			resolved.LatestAppVersion = newest.AppVersion
+		}
+	}
 
-		idx, ok := indexes[repoName]
-		if !ok || idx == nil {
-			log.Printf("no index for repo %q (release %s)", repoName, release.Name)
-			continue
+	// constraints are compared by the newest version they match; single-operator ranges keep
+	// their operator and only a latest version outside the range bumps their base
+	target, fromVersion, shownVersion := lastVersion, current.Chart.Version, current.Chart.Version
+	var rng updater.VersionRange
+	var ranged bool
+	if updater.IsConstraint(current.Chart.Version) && !versionedByRef {
+		if inUse, ok := updater.ResolveConstraint(current.Chart.Version, resolved.Versions); ok {
+			fromVersion, shownVersion = inUse.Version, fmt.Sprintf("%s (%s)", current.Chart.Version, inUse.Version)
+			resolved.CurrentAppVersion = firstNonEmpty(inUse.AppVersion, resolved.CurrentAppVersion)
+			if inUse.Version == lastVersion {
+				logDebugf("release %s: constraint %s resolves to the latest version %s", release.Name, current.Chart.Version, lastVersion)
+				return upToDateResult(release)
+			}
+		}
+		if rng, ranged = updater.ParseVersionRange(current.Chart.Version); !ranged {
+			logDebugf("release %s: version constraint %q is not a single-operator range; not updated", release.Name, current.Chart.Version)
+			return skippedResult(release, updater.ReasonConstraint, fmt.Sprintf("version constraint %s, latest %s", shownVersion, lastVersion))
 		}
+		if rng.Allows(lastVersion) {
+			logDebugf("release %s: range %s allows the latest version %s", release.Name, rng, lastVersion)
+			return upToDateResult(release)
+		}
+		target = rng.Bump(lastVersion)
+		if fromVersion == current.Chart.Version {
+			fromVersion = rng.Base
+		}
+	}
 
-		entries, ok := idx.Entries[chartName]
-		if !ok || len(entries) == 0 {
-			log.Printf("no entries for chart %q in repo %q (release %s)", chartName, repoName, release.Name)
-			continue
+	if stepMode != "" {
+		if len(resolved.Versions) == 0 {
+			logDebugf("release %s: %s source does not list versions, cannot step", release.Name, kind)
+			return skippedResult(release, updater.ReasonStep, kind+" source does not list versions for -step")
 		}
-		vlog("found %d entries for %s/%s", len(entries), repoName, chartName)
+		// ranges step from the version they resolve to and keep their operator
+		if next, ok := updater.StepVersion(fromVersion, resolved.Versions, stepMode, prereleases); ok && next.Version != lastVersion {
+			logDebugf("release %s: stepping to %s instead of latest %s", release.Name, next.Version, lastVersion)
+			lastVersion, target = next.Version, next.Version
+			if ranged {
+				target = rng.Bump(next.Version)
+			}
+			resolved.LatestAppVersion = next.AppVersion
+			span.SetAttributes(attribute.String("chart.step_version", lastVersion))
+		}
+	}
 
-		lastVersion := entries[0].Version
-		lastVersion = strings.TrimPrefix(lastVersion, "v")
+	if until, ok := cooldownUntil(bumps, release.ID(), time.Now()); ok {
+		logDebugf("release %s: %s available, but in cooldown until %s", release.Name, lastVersion, until.Format(time.RFC3339))
+		return skippedResult(release, updater.ReasonCooldown, fmt.Sprintf("%s available, cooldown until %s", lastVersion, until.UTC().Format("2006-01-02 15:04 MST")))
+	}
 
-		if release.Chart.Version == "" {
-			log.Printf("release %s: chart version not specified, skipping comparison", release.Name)
-			continue
+	// a relicensed chart (e.g. Apache-2.0 -> BUSL) may no longer be usable, so it waits for review
+	fromLicense, toLicense := updater.LicenseOf(published, fromVersion), updater.LicenseOf(published, lastVersion)
+	licenseChanged := updater.LicenseChanged(fromLicense, toLicense)
+	if licenseChanged && !allowLicenseChange {
+		logWarnf("⚠️ release %s: %s %s changes the license from %s to %s; not updated without -allow-license-change", release.Name, lookup.Chart.Name, lastVersion, fromLicense, toLicense)
+		return skippedResult(release, updater.ReasonLicense, fmt.Sprintf("%s changes the license from %s to %s", lastVersion, fromLicense, toLicense))
+	}
+	if licenseChanged {
+		logWarnf("⚠️ release %s: %s %s changes the license from %s to %s", release.Name, lookup.Chart.Name, lastVersion, fromLicense, toLicense)
+	}
+
+	currentAppVersion, latestAppVersion := resolved.CurrentAppVersion, resolved.LatestAppVersion
+	if r, ok := provider.(updater.AppVersionResolver); ok {
+		var appVersionErr error
+		inUse := lookup
+		inUse.Chart.Version = fromVersion
+		currentAppVersion, latestAppVersion, appVersionErr = providers.resolveAppVersions(ctx, r, kind, inUse, lastVersion)
+		if appVersionErr != nil {
+			logWarnf("failed to get appVersion for %q (release %s): %v", lookup.Chart.Name, release.Name, appVersionErr)
 		}
+	}
 
-		if release.Chart.Version != lastVersion {
-			currentAppVersion, latestAppVersion := appVersionsFromRepoEntries(release.Chart.Version, entries)
-			printReleaseUpdate(release, release.Chart.Version, lastVersion, currentAppVersion, latestAppVersion)
-			vlog("updating in-memory release %s: %s -> %s", release.Name, release.Chart.Version, lastVersion)
-			hw.Releases[id].Chart.Version = lastVersion
-			// collect last tag for this release (trim spaces)
-			if len(release.Tags) > 0 {
-				helmwaveTags = append(helmwaveTags, strings.TrimSpace(release.Tags[len(release.Tags)-1]))
+	// charts pinned by digest (or all OCI charts with -pin-digest) get the new tag's digest
+	var digest string
+	if p, ok := provider.(ociProvider); ok {
+		if _, pinned := updater.SplitOCIDigest(release.Chart.Name); pinned != "" || pinDigest {
+			if digest, err = p.Digest(ctx, lookup, lastVersion); err != nil {
+				spanError(span, err)
+				logWarnf("release %s: failed to resolve digest of %s: %v", release.Name, lastVersion, err)
+				return failedResult(release, updater.ReasonError, fmt.Sprintf("digest of %s: %v", lastVersion, err))
 			}
-		} else {
-			vlog("release %s is up-to-date (%s)", release.Name, release.Chart.Version)
 		}
 	}
-	// remove duplicates while preserving order
-	unique := make([]string, 0, len(helmwaveTags))
-	seen := make(map[string]bool, len(helmwaveTags))
-	for _, t := range helmwaveTags {
-		if t == "" {
-			continue
+
+	// a chart handed over to new maintainers is a supply-chain heads-up
+	maintainers := updater.CompareMaintainers(updater.MaintainersOf(published, fromVersion), updater.MaintainersOf(published, lastVersion))
+	if maintainers.Significant() {
+		logWarnf("⚠️ release %s: maintainers of %s changed between %s and %s: %s left, %s joined", release.Name, lookup.Chart.Name,
+			fromVersion, lastVersion, strings.Join(maintainers.Removed, ", "), orDash(strings.Join(maintainers.Added, ", ")))
+	}
+
+	update := updater.NewReleaseUpdate(current, target, currentAppVersion, latestAppVersion)
+	update.Digest = digest
+	if maintainers.Significant() {
+		update.MaintainersRemoved, update.MaintainersAdded = maintainers.Removed, maintainers.Added
+	}
+	if licenseChanged {
+		update.FromLicense, update.ToLicense = fromLicense, toLicense
+	}
+	if fromVersion != current.Chart.Version {
+		update.Importance = updater.UpdateImportance(fromVersion, lastVersion, currentAppVersion, latestAppVersion)
+	}
+
+	if policyCommand != "" {
+		candidate, _ := updater.FindVersion(published, lastVersion)
+		decision, err := evaluatePolicy(ctx, policyCommand, newPolicyInput(update, candidate.Created, time.Now()))
+		if err != nil {
+			spanError(span, err)
+			logWarnf("release %s: %v", release.Name, err)
+			return failedResult(release, updater.ReasonError, redactSecrets(err.Error()))
+		}
+		if decision.Decision != policyAllow {
+			reason := decision.describe(lastVersion)
+			logDebugf("release %s: %s", release.Name, reason)
+			return skippedResult(release, policyReasons[decision.Decision], reason)
+		}
+	}
+
+	if releaseNotes && !offline {
+		notes, err := providers.appReleaseNotes(ctx, updater.SourcesOf(published, lastVersion), currentAppVersion, latestAppVersion)
+		if err != nil {
+			logWarnf("⚠️ release %s: %v", release.Name, err)
 		}
-		if !seen[t] {
-			seen[t] = true
-			unique = append(unique, t)
+		update.ReleaseNotes = notes
+	}
+
+	printReleaseUpdate(w, release, shownVersion, target, currentAppVersion, latestAppVersion)
+	if digest != "" && humanReport() {
+		fmt.Fprintf(w, tr("   Digest: %s\n"), digest)
+	}
+	if len(update.ReleaseNotes) > 0 && humanReport() {
+		fmt.Fprint(w, tr("   Release notes:\n"))
+		for _, n := range update.ReleaseNotes {
+			fmt.Fprintf(w, "     %s %s\n", n.Version, n.URL)
 		}
 	}
-	fmt.Printf("\nexport HELMWAVE_TAGS='%s'\n", strings.Join(unique, ","))
+	logDebugf("updating in-memory release %s: %s -> %s", release.Name, current.Chart.Version, target)
+	if versionedByRef {
+		hw.Releases[id].Chart.Name = gitChart.WithRef(target)
+	} else {
+		hw.Releases[id].Chart.Version = target
+	}
+	span.SetAttributes(attribute.Bool("release.updated", true))
+	return updatedResult(update)
 }
 
-func printReleaseUpdate(release Release, currentVersion, latestVersion, currentAppVersion, latestAppVersion string) {
-	fmt.Printf("\nRelease: %s, Chart: %s, Version: %s\n", release.Name, release.Chart.Name, currentVersion)
-	fmt.Printf("   Update available: %s -> %s \n", currentVersion, latestVersion)
-	printAppVersionUpdate(currentAppVersion, latestAppVersion)
+// annotateLatest records the latest version found by res, and how far the release is behind it.
+func annotateLatest(r *releaseResult, res updater.Resolution) {
+	r.Latest = res.LatestVersion
+	r.Behind, r.DaysBehind = updater.Staleness(r.Version, res.LatestVersion, res.Versions)
+	if r.Status != statusUpdated {
+		r.CurrentAppVersion = firstNonEmpty(r.CurrentAppVersion, res.CurrentAppVersion)
+		r.LatestAppVersion = firstNonEmpty(r.LatestAppVersion, res.LatestAppVersion)
+	}
 }
 
-func printAppVersionUpdate(currentAppVersion, latestAppVersion string) {
+func printReleaseUpdate(w io.Writer, release Release, currentVersion, latestVersion, currentAppVersion, latestAppVersion string) {
+	if !humanReport() {
+		return
+	}
+	fmt.Fprintf(w, tr("\nRelease: %s, Chart: %s, Version: %s\n"), release.Name, release.Chart.Name, currentVersion)
+	fmt.Fprintf(w, tr("   Update available: %s -> %s \n"), currentVersion, latestVersion)
+	printAppVersionUpdate(w, currentAppVersion, latestAppVersion)
+}
+
+func printAppVersionUpdate(w io.Writer, currentAppVersion, latestAppVersion string) {
 	currentAppVersion = strings.TrimSpace(currentAppVersion)
 	latestAppVersion = strings.TrimSpace(latestAppVersion)
 
@@ -216,68 +775,45 @@ func printAppVersionUpdate(currentAppVersion, latestAppVersion string) {
 	}
 
 	if currentAppVersion == "" {
-		fmt.Printf("   AppVersion: (unknown) -> %s\n", latestAppVersion)
+		fmt.Fprintf(w, tr("   AppVersion: (unknown) -> %s\n"), latestAppVersion)
 		return
 	}
 
 	if latestAppVersion == "" {
-		fmt.Printf("   AppVersion: %s -> (unknown)\n", currentAppVersion)
+		fmt.Fprintf(w, tr("   AppVersion: %s -> (unknown)\n"), currentAppVersion)
 		return
 	}
 
-	fmt.Printf("   AppVersion: %s -> %s\n", currentAppVersion, latestAppVersion)
+	fmt.Fprintf(w, tr("   AppVersion: %s -> %s\n"), currentAppVersion, latestAppVersion)
 	importanceColor, importanceLabel, currentNormalized, latestNormalized, ok := appUpdateImportance(currentAppVersion, latestAppVersion)
 	if !ok {
 		return
 	}
 
-	fmt.Printf("   Update importance: %s%s%s (%s -> %s)\n", importanceColor, strings.ToUpper(importanceLabel), colorReset, currentNormalized, latestNormalized)
+	fmt.Fprintf(w, tr("   Update importance: %s (%s -> %s)\n"), colorize(importanceColor, strings.ToUpper(importanceLabel)), currentNormalized, latestNormalized)
 }
 
 func appUpdateImportance(currentAppVersion, latestAppVersion string) (string, string, string, string, bool) {
-	cur, err1 := semver.NewVersion(normalizeSemVer(currentAppVersion))
-	lat, err2 := semver.NewVersion(normalizeSemVer(latestAppVersion))
-	if err1 != nil || err2 != nil {
+	label, cur, lat, ok := updater.Importance(currentAppVersion, latestAppVersion)
+	if !ok {
 		return "", "", "", "", false
 	}
-
-	switch {
-	case lat.Major() > cur.Major():
-		return colorRed, "major", cur.String(), lat.String(), true
-	case lat.Minor() > cur.Minor():
-		return colorYellow, "minor", cur.String(), lat.String(), true
-	case lat.Patch() > cur.Patch():
-		return colorGreen, "patch", cur.String(), lat.String(), true
-	default:
-		return colorGreen, "none", cur.String(), lat.String(), true
+	switch label {
+	case "major":
+		return colorRed, label, cur, lat, true
+	case "minor":
+		return colorYellow, label, cur, lat, true
 	}
+	return colorGreen, label, cur, lat, true
 }
 
-func appVersionsFromRepoEntries(currentChartVersion string, versions []*repo.ChartVersion) (string, string) {
-	var currentAppVersion string
-	var latestAppVersion string
-
-	for _, v := range versions {
-		if strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(currentChartVersion, "v") {
-			currentAppVersion = strings.TrimSpace(v.AppVersion)
-			break
-		}
-	}
-
-	if len(versions) > 0 {
-		latestAppVersion = strings.TrimSpace(versions[0].AppVersion)
-	}
-
-	return currentAppVersion, latestAppVersion
-}
-
-func ociAppVersions(client *registry.Client, chartRef, currentChartVersion, latestChartVersion string) (string, string, error) {
-	currentAppVersion, err := ociAppVersionByTag(client, chartRef, currentChartVersion)
+func ociAppVersions(ctx context.Context, client *registry.Client, chartRef, currentChartVersion, latestChartVersion string) (string, string, error) {
+	currentAppVersion, err := ociAppVersionByTag(ctx, client, chartRef, currentChartVersion)
 	if err != nil {
 		return "", "", fmt.Errorf("current chart version %s: %w", currentChartVersion, err)
 	}
 
-	latestAppVersion, err := ociAppVersionByTag(client, chartRef, latestChartVersion)
+	latestAppVersion, err := ociAppVersionByTag(ctx, client, chartRef, latestChartVersion)
 	if err != nil {
 		return "", "", fmt.Errorf("latest chart version %s: %w", latestChartVersion, err)
 	}
@@ -285,7 +821,7 @@ func ociAppVersions(client *registry.Client, chartRef, currentChartVersion, late
 	return currentAppVersion, latestAppVersion, nil
 }
 
-func ociAppVersionByTag(client *registry.Client, chartRef, chartVersion string) (string, error) {
+func ociAppVersionByTag(ctx context.Context, client *registry.Client, chartRef, chartVersion string) (string, error) {
 	tagCandidates := []string{strings.TrimSpace(chartVersion)}
 	trimmed := strings.TrimPrefix(strings.TrimSpace(chartVersion), "v")
 	if trimmed != "" {
@@ -308,8 +844,16 @@ func ociAppVersionByTag(client *registry.Client, chartRef, chartVersion string)
 				continue
 			}
 			pullRef := fmt.Sprintf("%s:%s", ref, tag)
-			pulled, err := client.Pull(pullRef, registry.PullOptWithChart(true))
+			var pulled *registry.PullResult
+			err := runWithContext(ctx, func() error {
+				var err error
+				pulled, err = client.Pull(pullRef, registry.PullOptWithChart(true))
+				return err
+			})
 			if err != nil {
+				if ctx.Err() != nil {
+					return "", err
+				}
 				lastErr = err
 				continue
 			}
@@ -327,95 +871,75 @@ func ociAppVersionByTag(client *registry.Client, chartRef, chartVersion string)
 	return "", lastErr
 }
 
-func latestOCIVersion(client *registry.Client, chartRef string) (string, error) {
-	tags, err := client.Tags(chartRef)
+// latestOCIVersion returns the highest semver tag of chartRef along with all its tags.
+func latestOCIVersion(ctx context.Context, client *registry.Client, chartRef string) (string, []string, error) {
+	tags, err := listOCITags(ctx, client, chartRef)
 	if err != nil {
-		trimmedRef := strings.TrimPrefix(chartRef, registry.OCIScheme+"://")
-		if trimmedRef == chartRef {
-			return "", err
-		}
-		tags, err = client.Tags(trimmedRef)
-		if err != nil {
-			return "", err
-		}
+		return "", nil, err
 	}
 
-	latest, ok := latestSemverTag(tags)
+	latest, ok := updater.LatestSemverTag(tags)
 	if !ok {
-		return "", errors.New("no semver-compatible OCI tags found")
+		return "", nil, errors.New("no semver-compatible OCI tags found")
 	}
 
-	return latest, nil
+	return latest, tags, nil
 }
 
-func latestSemverTag(tags []string) (string, bool) {
-	var selectedVersion *semver.Version
-	selectedRawTag := ""
-
-	for _, tag := range tags {
-		normalized := normalizeSemVer(tag)
-		parsed, err := semver.NewVersion(normalized)
-		if err != nil {
-			continue
-		}
-		if selectedVersion == nil || parsed.GreaterThan(selectedVersion) {
-			selectedVersion = parsed
-			selectedRawTag = tag
-		}
-	}
-
-	if selectedVersion == nil {
-		return "", false
+// listRawOCITags lists every tag of an OCI repository. Unlike listOCITags (helm's
+// Client.Tags) it keeps tags that aren't strict semver, such as build-1.2.0 or v1.2.0.
+func listRawOCITags(ctx context.Context, authorizer *auth.Client, chartRef string) ([]string, error) {
+	repository, err := remote.NewRepository(strings.TrimPrefix(chartRef, registry.OCIScheme+"://"))
+	if err != nil {
+		return nil, err
 	}
-
-	return strings.TrimPrefix(strings.TrimSpace(selectedRawTag), "v"), true
+	repository.Client = authorizer
+
+	var tags []string
+	err = withRetry(ctx, "list OCI tags for "+chartRef, func() error {
+		tags = nil
+		return repository.Tags(ctx, "", func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+	})
+	return tags, err
 }
 
-// normalizeSemVer attempts to coerce appVersion strings into a semver-compatible form
-func normalizeSemVer(v string) string {
-	// trim spaces and possible leading 'v'
-	vv := strings.TrimSpace(v)
-	vv = strings.TrimPrefix(vv, "v")
-	// if version looks like '1' or '1.2', pad to three segments
-	parts := strings.Split(vv, ".")
-	if len(parts) == 1 {
-		return vv + ".0.0"
-	}
-	if len(parts) == 2 {
-		return vv + ".0"
+// resolveOCIDigest returns the manifest digest of chartRef:tag.
+func resolveOCIDigest(ctx context.Context, authorizer *auth.Client, chartRef, tag string) (string, error) {
+	repository, err := remote.NewRepository(strings.TrimPrefix(chartRef, registry.OCIScheme+"://"))
+	if err != nil {
+		return "", err
 	}
-	return vv
-}
+	repository.Client = authorizer
 
-// buildVersionMap prepares mapping release name -> version for file editing, skipping noupdate releases.
-func buildVersionMap(hw *Helmwave) map[string]string {
-	versionMap := make(map[string]string, len(hw.Releases))
-	for _, r := range hw.Releases {
-		if r.Name == "" {
-			continue
-		}
-		if hasTag(r.Tags, NoupdateTag) {
-			vlog("not including release %s in file edits because of '%s' tag", r.Name, NoupdateTag)
-			continue
+	var digest string
+	err = withRetry(ctx, "resolve digest of "+chartRef+":"+tag, func() error {
+		desc, err := repository.Resolve(ctx, tag)
+		if err != nil {
+			return err
 		}
-		versionMap[r.Name] = r.Chart.Version
-	}
-	return versionMap
+		digest = desc.Digest.String()
+		return nil
+	})
+	return digest, err
 }
 
-// buildChartVersionMap prepares mapping chart full name (repo/chart) -> version
-// This is used to update top-level anchors like `.options: &options` that contain a `chart:` block.
-func buildChartVersionMap(hw *Helmwave) map[string]string {
-	chartMap := make(map[string]string, len(hw.Releases))
-	for _, r := range hw.Releases {
-		if r.Chart.Name == "" {
-			continue
-		}
-		if hasTag(r.Tags, NoupdateTag) {
-			// skip releases marked as noupdate
-			continue
+// listOCITags lists repository tags, retrying without the oci:// scheme if needed.
+func listOCITags(ctx context.Context, client *registry.Client, chartRef string) ([]string, error) {
+	var tags []string
+	err := withRetry(ctx, "list OCI tags for "+chartRef, func() error {
+		var err error
+		tags, err = client.Tags(chartRef)
+		if err != nil {
+			trimmedRef := strings.TrimPrefix(chartRef, registry.OCIScheme+"://")
+			if trimmedRef == chartRef {
+				return err
+			}
+			tags, err = client.Tags(trimmedRef)
 		}
-		chartMap[r.Chart.Name] = r.Chart.Version
-	}
-	return chartMap
+		return err
+	})
+	return tags, err
 }
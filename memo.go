@@ -0,0 +1,38 @@
+package main
+
+import "sync"
+
+// memo runs a function once per key and hands its result to every caller with that key,
+// including callers that arrive while the first call is still running. Errors are kept
+// like results: a chart that failed to resolve fails the same way for every release.
+type memo[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*memoCall[T]
+}
+
+type memoCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// do returns the result of fn for key, calling fn only for the first caller. shared is true
+// for the callers that got the result of an earlier call.
+func (m *memo[T]) do(key string, fn func() (T, error)) (val T, shared bool, err error) {
+	m.mu.Lock()
+	if c, ok := m.calls[key]; ok {
+		m.mu.Unlock()
+		<-c.done
+		return c.val, true, c.err
+	}
+	if m.calls == nil {
+		m.calls = make(map[string]*memoCall[T])
+	}
+	c := &memoCall[T]{done: make(chan struct{})}
+	m.calls[key] = c
+	m.mu.Unlock()
+
+	defer close(c.done)
+	c.val, c.err = fn()
+	return c.val, false, c.err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// lang selects the language of human-readable output (-lang; defaults to LC_ALL, LC_MESSAGES or LANG)
+var lang string
+
+// language is the resolved catalog language; empty means English.
+var language string
+
+// catalog translates human-readable output. Keys are the English messages (format strings
+// included), so a missing translation falls back to English. Logs, JSON reports, export
+// lines and other machine formats are never translated.
+var catalog = map[string]map[string]string{
+	"ru": {
+		"\nRelease: %s, Chart: %s, Version: %s\n":              "\nРелиз: %s, чарт: %s, версия: %s\n",
+		"\nRelease: %s, Dependency: %s (%s), Version: %s\n":    "\nРелиз: %s, зависимость: %s (%s), версия: %s\n",
+		"   Update available: %s -> %s \n":                     "   Доступно обновление: %s -> %s \n",
+		"   Held back by -limit %d\n":                          "   Отложено из-за -limit %d\n",
+		"   AppVersion: (unknown) -> %s\n":                     "   AppVersion: (неизвестно) -> %s\n",
+		"   AppVersion: %s -> (unknown)\n":                     "   AppVersion: %s -> (неизвестно)\n",
+		"   AppVersion: %s -> %s\n":                            "   AppVersion: %s -> %s\n",
+		"   Update importance: %s (%s -> %s)\n":                "   Важность обновления: %s (%s -> %s)\n",
+		"   Digest: %s\n":                                      "   Дайджест: %s\n",
+		"\nNext step: %s\n":                                    "\nСледующий шаг: %s\n",
+		"\nSummary: %d releases checked\n":                     "\nИтого: проверено релизов: %d\n",
+		"\nSummary: %d releases checked in %d kube contexts\n": "\nИтого: проверено релизов: %d в kube-контекстах: %d\n",
+		"\nContext %s: %d releases\n":                          "\nКонтекст %s: релизов: %d\n",
+		"(current)":                                            "(текущий)",
+		"   up-to-date: %d\n":                                  "   актуальны:  %d\n",
+		"   updates:    %d (%s major, %s minor, %s patch":      "   обновления: %d (%s major, %s minor, %s patch",
+		", %d other":                                           ", %d прочих",
+		"   skipped:    %d\n":                                  "   пропущены:  %d\n",
+		"   failed:     %d\n":                                  "   с ошибкой:  %d\n",
+		"\nSkipped releases:\n":                                "\nПропущенные релизы:\n",
+		"\nFailed releases:\n":                                 "\nРелизы с ошибкой:\n",
+		"   Release notes:\n":                                  "   Заметки о выпусках:\n",
+		"other":                                                "прочее",
+		"noupdate tag":                                         "тег noupdate",
+		"local chart":                                          "локальный чарт",
+		"offline mode":                                         "офлайн-режим",
+		"no chart version":                                     "нет версии чарта",
+		"version constraint":                                   "ограничение версии",
+		"cooldown":                                             "пауза после обновления",
+		"no version list for -step":                            "нет списка версий для -step",
+		"held back by -limit":                                  "отложено из-за -limit",
+		"denied by policy command":                             "запрещено командой политики",
+		"held by policy command":                               "отложено командой политики",
+		"RELEASE\tCHART\tVERSION\tLATEST\tAPPVERSION\tSTATUS\tBEHIND\tTAGS": "РЕЛИЗ\tЧАРТ\tВЕРСИЯ\tПОСЛЕДНЯЯ\tAPPVERSION\tСТАТУС\tОТСТАВАНИЕ\tТЕГИ",
+		"RELEASE":               "РЕЛИЗ",
+		"(missing)":             "(отсутствует)",
+		"repo policy":           "политика репозитория",
+		"release channel":       "канал релизов",
+		"chart name":            "имя чарта",
+		"missing index":         "нет индекса",
+		"chart not in index":    "чарта нет в индексе",
+		"error":                 "ошибка",
+		"vals secret reference": "ссылка на секрет vals",
+		"templated version":     "версия из шаблона",
+		"license change":        "смена лицензии",
+		"\nPlanfile %s:\n":      "\nPlanfile %s:\n",
+		"PLAN":                  "ПЛАН",
+		"SOURCE":                "ИСТОЧНИК",
+		"LATEST":                "ПОСЛЕДНЯЯ",
+	},
+}
+
+// languages lists the accepted -lang values.
+func languages() []string {
+	langs := []string{"en"}
+	for l := range catalog {
+		langs = append(langs, l)
+	}
+	slices.Sort(langs[1:])
+	return langs
+}
+
+// setupLanguage resolves -lang, falling back to the locale environment. An unknown -lang
+// is an error; an unknown locale silently selects English.
+func setupLanguage() error {
+	if lang != "" {
+		l := strings.ToLower(strings.TrimSpace(lang))
+		if !slices.Contains(languages(), l) {
+			return fmt.Errorf("unknown language %q (expected %s)", lang, strings.Join(languages(), ", "))
+		}
+		language = l
+		return nil
+	}
+	language = localeLanguage(firstNonEmpty(os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")))
+	return nil
+}
+
+// localeLanguage maps a POSIX locale such as ru_RU.UTF-8 to a catalog language.
+func localeLanguage(locale string) string {
+	l, _, _ := strings.Cut(strings.ToLower(locale), ".")
+	l, _, _ = strings.Cut(l, "_")
+	if _, ok := catalog[l]; ok {
+		return l
+	}
+	return ""
+}
+
+// tr returns the translation of an English message in the selected language.
+func tr(msg string) string {
+	if s, ok := catalog[language][msg]; ok {
+		return s
+	}
+	return msg
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metricsPush is a Prometheus Pushgateway URL the run's gauges are pushed to (-metrics-push)
+var metricsPush string
+
+// metricsTextfile is a file for node_exporter's textfile collector (-metrics-textfile)
+var metricsTextfile string
+
+// metricsJob is the Pushgateway job the gauges are grouped under when -metrics-push has no /metrics/job/ path.
+const metricsJob = "helmwave-updater"
+
+// renderMetrics renders the staleness gauges of a run over file in the Prometheus text
+// exposition format. Per-release gauges cover resolved releases only.
+func renderMetrics(file string, result checkResult, now time.Time) string {
+	var sb strings.Builder
+	fileLabel := promLabels("file", file)
+
+	writeHeader := func(name, kind, help string) {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	releaseLabels := func(r releaseResult, extra ...string) string {
+		return promLabels(append([]string{"file", file, "release", r.Release, "context", r.Context, "chart", r.Chart}, extra...)...)
+	}
+	var resolved []releaseResult
+	for _, r := range result.Releases {
+		if r.Latest != "" {
+			resolved = append(resolved, r)
+		}
+	}
+
+	writeHeader("helmwave_updater_release_info", "gauge", "Chart version in use and latest published version of a release.")
+	for _, r := range resolved {
+		fmt.Fprintf(&sb, "helmwave_updater_release_info%s 1\n", releaseLabels(r, "version", r.Version, "latest", r.Latest))
+	}
+	writeHeader("helmwave_updater_release_outdated", "gauge", "Whether a newer chart version than the one in use is published (1) or not (0).")
+	for _, r := range resolved {
+		outdated := 0
+		if r.Status == statusUpdated || r.Behind > 0 {
+			outdated = 1
+		}
+		fmt.Fprintf(&sb, "helmwave_updater_release_outdated%s %d\n", releaseLabels(r), outdated)
+	}
+	writeHeader("helmwave_updater_release_versions_behind", "gauge", "Published chart versions newer than the one in use.")
+	for _, r := range resolved {
+		fmt.Fprintf(&sb, "helmwave_updater_release_versions_behind%s %d\n", releaseLabels(r), r.Behind)
+	}
+	writeHeader("helmwave_updater_release_days_behind", "gauge", "Days between the publish dates of the chart version in use and the latest one, when the source records them.")
+	for _, r := range resolved {
+		fmt.Fprintf(&sb, "helmwave_updater_release_days_behind%s %d\n", releaseLabels(r), r.DaysBehind)
+	}
+
+	writeHeader("helmwave_updater_releases", "gauge", "Releases checked in the last run by status.")
+	for _, status := range []releaseStatus{statusUpdated, statusUpToDate, statusSkipped, statusFailed} {
+		fmt.Fprintf(&sb, "helmwave_updater_releases%s %d\n", promLabels("file", file, "status", string(status)), result.Count(status))
+	}
+	byImportance := make(map[string]int)
+	for _, u := range result.Updates() {
+		byImportance[u.Importance]++
+	}
+	writeHeader("helmwave_updater_updates", "gauge", "Updates found in the last run by importance.")
+	for _, importance := range sortedKeys(byImportance) {
+		fmt.Fprintf(&sb, "helmwave_updater_updates%s %d\n", promLabels("file", file, "importance", importance), byImportance[importance])
+	}
+	writeHeader("helmwave_updater_last_run_timestamp_seconds", "gauge", "Unix time of the last completed run.")
+	fmt.Fprintf(&sb, "helmwave_updater_last_run_timestamp_seconds%s %d\n", fileLabel, now.Unix())
+	return sb.String()
+}
+
+// promLabels renders name/value pairs as a Prometheus label set.
+func promLabels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// exportMetrics writes the run's gauges to -metrics-textfile and pushes them to -metrics-push.
+// Failures are returned for the caller to log; they never fail the run.
+func exportMetrics(ctx context.Context, file string, result checkResult) error {
+	if metricsTextfile == "" && metricsPush == "" {
+		return nil
+	}
+	metrics := renderMetrics(file, result, time.Now())
+	if metricsTextfile != "" {
+		// node_exporter may read the file at any moment, so it is replaced atomically
+		if err := writeFileAtomic(metricsTextfile, []byte(metrics), 0644); err != nil {
+			return fmt.Errorf("write metrics textfile: %w", err)
+		}
+		logDebugf("wrote metrics to %s", metricsTextfile)
+	}
+	if metricsPush != "" {
+		if offline {
+			logWarnf("⚠️ not pushing metrics to %s: network access is disabled in offline mode", metricsPush)
+			return nil
+		}
+		if err := pushMetrics(ctx, metricsPush, metrics); err != nil {
+			return fmt.Errorf("push metrics: %w", err)
+		}
+		logDebugf("pushed metrics to %s", metricsPush)
+	}
+	return nil
+}
+
+// pushMetrics replaces the metrics of the job group at a Pushgateway with metrics.
+func pushMetrics(ctx context.Context, gateway, metrics string) error {
+	url := strings.TrimSuffix(gateway, "/")
+	if !strings.Contains(url, "/metrics/job/") {
+		url += "/metrics/job/" + metricsJob
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader([]byte(metrics)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "helmwave-updater/"+version)
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values of -output.
+const (
+	outputText     = "text"
+	outputTemplate = "template"
+	outputYAML     = "yaml"
+	outputPatch    = "patch"
+)
+
+var outputFormats = []string{outputText, outputTemplate, outputYAML, outputPatch}
+
+// outputFormat selects what a run prints to stdout (-output): the text report, the result
+// rendered with -template, the report as YAML, or the edits as a unified diff
+var outputFormat = outputText
+
+// outputTemplateText is the Go template of -output template (-template); "@path" reads it from a file
+var outputTemplateText string
+
+// humanReport reports whether the text report (update lines, summary, next step) goes to
+// stdout: not with -quiet, -list/-outdated or another -output.
+func humanReport() bool {
+	return !quiet && !tableMode() && outputFormat == outputText
+}
+
+// validateOutput checks -output and -template before the run.
+func validateOutput() error {
+	switch {
+	case !slices.Contains(outputFormats, outputFormat):
+		return fmt.Errorf("unknown -output %q (expected %s)", outputFormat, strings.Join(outputFormats, ", "))
+	case outputFormat == outputTemplate && outputTemplateText == "":
+		return errors.New("-output template needs -template")
+	case outputFormat != outputTemplate && outputTemplateText != "":
+		return errors.New("-template needs -output template")
+	case outputFormat != outputText && tableMode():
+		return fmt.Errorf("-output %s cannot be combined with -list or -outdated", outputFormat)
+	case outputFormat == outputPatch && inplace:
+		return errors.New("-output patch writes no files; drop -inplace")
+	case outputFormat == outputTemplate:
+		_, err := parseOutputTemplate()
+		return err
+	}
+	return nil
+}
+
+// parseOutputTemplate parses -template. Templates get the functions join, upper and lower.
+func parseOutputTemplate() (*template.Template, error) {
+	text := outputTemplateText
+	if path, ok := strings.CutPrefix(text, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -template: %w", err)
+		}
+		text = string(data)
+	}
+	funcs := template.FuncMap{"join": strings.Join, "upper": strings.ToUpper, "lower": strings.ToLower}
+	t, err := template.New("output").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -template: %w", err)
+	}
+	return t, nil
+}
+
+// printOutput writes the result of a run to out in the -output format. The text report is
+// printed while the run goes, so there is nothing left to do for it here.
+func printOutput(out io.Writer, c checkResult) error {
+	switch outputFormat {
+	case outputYAML:
+		return writeYAMLReport(out, c)
+	case outputPatch:
+		return writePatch(out)
+	case outputTemplate:
+		t, err := parseOutputTemplate()
+		if err != nil {
+			return err
+		}
+		// the template sees the fields of the JSON report (.Summary, .Updates, .Skipped, .Failed)
+		if err := t.Execute(out, newJSONReport(c)); err != nil {
+			return fmt.Errorf("failed to render -template: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeYAMLReport writes the JSON report of c as YAML, which reads and diffs better when
+// committed next to the helmwave file.
+func writeYAMLReport(out io.Writer, c checkResult) error {
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(newJSONReport(c)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// showDiff prints the edits as a diff after the text report (-diff)
+var showDiff bool
+
+// patchContext is the number of unchanged lines around each hunk of -output patch.
+const patchContext = 3
+
+// fileEdit is an edited file collected for -output patch.
+type fileEdit struct {
+	path              string
+	original, content string
+}
+
+// patchEdits are the edits of the run, in the order they were made (-output patch, -diff)
+var patchEdits []fileEdit
+
+// writeEdited writes content, the edited version of file, over file when inplace and to
+// file.updated otherwise, and returns the path written. With -output patch nothing is
+// written: the edit is added to the patch printed after the run, and the path is empty.
+func writeEdited(file, content string, inplace bool) (string, error) {
+	if outputFormat == outputPatch || showDiff {
+		original, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		if string(original) != content {
+			patchEdits = append(patchEdits, fileEdit{path: file, original: string(original), content: content})
+			logDebugf("added %s to the patch", file)
+		}
+		if outputFormat == outputPatch {
+			return "", nil
+		}
+	}
+	target := file + ".updated"
+	if inplace {
+		target = file
+	}
+	return target, writeOutput(target, content)
+}
+
+// writePatch writes the collected edits as a unified diff that git apply (or patch -p1)
+// applies from the current directory.
+func writePatch(out io.Writer) error {
+	for _, e := range patchEdits {
+		if _, err := io.WriteString(out, unifiedDiff(patchPath(e.path), e.original, e.content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printDiff writes the edits of the run for -diff: the patch, with only the changed words of
+// each edited line highlighted when colors are enabled.
+func printDiff(out io.Writer) error {
+	var sb strings.Builder
+	if err := writePatch(&sb); err != nil {
+		return err
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(out, "\n"+colorDiff(sb.String()))
+	return err
+}
+
+// diffWord splits a line into words for colorDiff: versions, names and paths stay whole.
+var diffWord = regexp.MustCompile(`[A-Za-z0-9._+@/-]+|\s+|.`)
+
+// colorDiff colors a unified diff for the terminal. A run of removed lines followed by as
+// many added lines is an in-place edit: only the words that differ between the paired lines
+// are colored, so a version bump shows just the version. Other removed and added lines are
+// colored whole. Without colors diff is returned as is.
+func colorDiff(diff string) string {
+	if !colorEnabled {
+		return diff
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	var sb strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			sb.WriteString(colorize(colorCyan, strings.TrimSuffix(line, "\n")) + "\n")
+		case strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "--- a/"):
+			removed := diffRun(lines[i:], "-")
+			added := diffRun(lines[i+removed:], "+")
+			if removed != added {
+				for _, l := range lines[i : i+removed+added] {
+					sb.WriteString(colorize(diffColor(l), strings.TrimSuffix(l, "\n")) + "\n")
+				}
+				i += removed + added
+				continue
+			}
+			for k := i; k < i+removed; k++ {
+				sb.WriteString(colorWords(lines[k], lines[k+removed], colorRed))
+			}
+			for k := i + removed; k < i+2*removed; k++ {
+				sb.WriteString(colorWords(lines[k], lines[k-removed], colorGreen))
+			}
+			i += 2 * removed
+			continue
+		case strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++ b/"):
+			sb.WriteString(colorize(colorGreen, strings.TrimSuffix(line, "\n")) + "\n")
+		default:
+			sb.WriteString(line)
+		}
+		i++
+	}
+	return sb.String()
+}
+
+// diffRun counts the lines at the start of lines with the given prefix; a "no newline"
+// marker belongs to the line before it.
+func diffRun(lines []string, prefix string) int {
+	n := 0
+	for n < len(lines) && (strings.HasPrefix(lines[n], prefix) || (n > 0 && strings.HasPrefix(lines[n], "\\"))) {
+		if strings.HasPrefix(lines[n], "--- a/") || strings.HasPrefix(lines[n], "+++ b/") {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// diffColor is the color of a whole diff line.
+func diffColor(line string) string {
+	switch {
+	case strings.HasPrefix(line, "-"):
+		return colorRed
+	case strings.HasPrefix(line, "+"):
+		return colorGreen
+	}
+	return ""
+}
+
+// colorWords colors the +/- marker of line and the words that differ from other, the line
+// it is paired with; the words both lines start and end with are left plain.
+func colorWords(line, other, color string) string {
+	if strings.HasPrefix(line, "\\") {
+		return line
+	}
+	body, eol := strings.CutSuffix(line[1:], "\n")
+	words := diffWord.FindAllString(body, -1)
+	others := diffWord.FindAllString(strings.TrimSuffix(other[1:], "\n"), -1)
+	prefix := 0
+	for prefix < len(words) && prefix < len(others) && words[prefix] == others[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(words)-prefix && suffix < len(others)-prefix && words[len(words)-1-suffix] == others[len(others)-1-suffix] {
+		suffix++
+	}
+	changed := strings.Join(words[prefix:len(words)-suffix], "")
+	out := colorize(color, line[:1]) + strings.Join(words[:prefix], "")
+	if changed != "" {
+		out += colorize(color+colorReverse, changed)
+	}
+	out += strings.Join(words[len(words)-suffix:], "")
+	if eol {
+		out += "\n"
+	}
+	return out
+}
+
+// patchPath returns path relative to the current directory, with forward slashes.
+func patchPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// unifiedDiff returns the git-style diff of a file from original to content. The editors
+// change lines in place, so lines are compared by position; when the line counts differ
+// the whole file is one hunk.
+func unifiedDiff(path, original, content string) string {
+	a, aEOL := splitLines(original)
+	b, bEOL := splitLines(content)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+
+	line := func(prefix string, lines []string, i int, eol bool) {
+		sb.WriteString(prefix + lines[i] + "\n")
+		if i == len(lines)-1 && !eol {
+			sb.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	if len(a) != len(b) {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(0, len(a)), hunkRange(0, len(b)))
+		for i := range a {
+			line("-", a, i, aEOL)
+		}
+		for i := range b {
+			line("+", b, i, bEOL)
+		}
+		return sb.String()
+	}
+
+	var changed []int
+	for i := range a {
+		if a[i] != b[i] || (i == len(a)-1 && aEOL != bEOL) {
+			changed = append(changed, i)
+		}
+	}
+	for len(changed) > 0 {
+		// a hunk takes every change whose context touches the previous one
+		n := 1
+		for n < len(changed) && changed[n]-changed[n-1] <= 2*patchContext {
+			n++
+		}
+		start, end := max(changed[0]-patchContext, 0), min(changed[n-1]+patchContext+1, len(a))
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(start, end-start), hunkRange(start, end-start))
+		same := func(i int) bool { return a[i] == b[i] && (i < len(a)-1 || aEOL == bEOL) }
+		for i := start; i < end; {
+			if same(i) {
+				line(" ", a, i, aEOL)
+				i++
+				continue
+			}
+			// a run of changed lines prints its removed lines first, then the added ones
+			j := i
+			for j < end && !same(j) {
+				j++
+			}
+			for k := i; k < j; k++ {
+				line("-", a, k, aEOL)
+			}
+			for k := i; k < j; k++ {
+				line("+", b, k, bEOL)
+			}
+			i = j
+		}
+		changed = changed[n:]
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines and reports whether it ends with a newline.
+func splitLines(text string) ([]string, bool) {
+	if text == "" {
+		return nil, true
+	}
+	eol := strings.HasSuffix(text, "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), eol
+}
+
+// hunkRange renders the start,count of a hunk header; an empty range starts before its line.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// annotationRe matches the trailing comment written by Annotate.
+var annotationRe = regexp.MustCompile(` # was .+ \(updated \d{4}-\d{2}-\d{2}\)$`)
+
+// appVersionCommentRe matches the comment written by UpdateAppVersionComments.
+var appVersionCommentRe = regexp.MustCompile(` # appVersion: [^\s#]+`)
+
+// Annotate appends "# was <previous value> (updated <date>)" to every "key: value" line of
+// updated whose value differs from the same line of original, replacing an annotation left
+// by an earlier run. Other comments on the line are kept before it. The editors change lines
+// in place, so lines are compared by position; updated is returned as is when the line counts
+// differ.
+func Annotate(original, updated string, date time.Time) string {
+	before, after := strings.Split(original, "\n"), strings.Split(updated, "\n")
+	if len(before) != len(after) {
+		return updated
+	}
+	stamp := date.Format(time.DateOnly)
+	for i := range after {
+		if before[i] == after[i] {
+			continue
+		}
+		previous, ok := lineValue(StripAnnotation(before[i]))
+		if current, _ := lineValue(after[i]); !ok || previous == "" || previous == current {
+			continue
+		}
+		after[i] = StripAnnotation(after[i]) + " # was " + previous + " (updated " + stamp + ")"
+	}
+	return strings.Join(after, "\n")
+}
+
+// RemoveAnnotations drops the Annotate and UpdateAppVersionComments comments from every line
+// of updated that differs from the same line of original, for edits (such as a rollback)
+// after which they no longer hold.
+func RemoveAnnotations(original, updated string) string {
+	before, after := strings.Split(original, "\n"), strings.Split(updated, "\n")
+	if len(before) != len(after) {
+		return updated
+	}
+	for i := range after {
+		if before[i] != after[i] {
+			after[i] = StripComments(after[i])
+		}
+	}
+	return strings.Join(after, "\n")
+}
+
+// UpdateAppVersionComments writes "# appVersion: <appVersion>" on the chart version line of
+// every release block in appVersions, keyed by release ID (see Release.ID), replacing the
+// one an earlier run wrote. Other comments on the line are kept; an Annotate comment stays
+// last. Lines that already carry the appVersion are left untouched.
+func UpdateAppVersionComments(original []byte, appVersions map[string]string) string {
+	lines := strings.Split(string(original), "\n")
+	ids := sortedIDs(appVersions)
+	for _, b := range releaseBlocks(lines) {
+		id, ok := b.target(ids)
+		if !ok {
+			continue
+		}
+		i, ok := b.chartField(lines, "version")
+		if !ok {
+			continue
+		}
+		appVersion := appVersions[id]
+		if strings.ContainsAny(appVersion, " \t#") {
+			debugf("release %s: appVersion %q does not fit in a comment; not writing it", id, appVersion)
+			continue
+		}
+		line := StripAnnotation(lines[i])
+		annotation := lines[i][len(line):]
+		newLine := appVersionCommentRe.ReplaceAllString(line, "") + " # appVersion: " + appVersion + annotation
+		if newLine != lines[i] {
+			debugf("replacing line %d for release %s: %q -> %q", i+1, id, lines[i], newLine)
+			lines[i] = newLine
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StripAnnotation removes a trailing Annotate comment from line.
+func StripAnnotation(line string) string {
+	return annotationRe.ReplaceAllString(line, "")
+}
+
+// StripComments removes the comments written by Annotate and UpdateAppVersionComments from line.
+func StripComments(line string) string {
+	return appVersionCommentRe.ReplaceAllString(StripAnnotation(line), "")
+}
+
+// lineValue returns the scalar value of a "key: value" (or "- key: value") line.
+func lineValue(line string) (string, bool) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(line), "- ")
+	_, value, ok := strings.Cut(trimmed, ": ")
+	if !ok || strings.HasPrefix(trimmed, "#") {
+		return "", false
+	}
+	return yamlScalar(value), true
+}
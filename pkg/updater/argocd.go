@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// argoSource is a helm chart source of an Argo CD Application.
+type argoSource struct {
+	id      string
+	release Release
+	node    *yaml.Node
+}
+
+// argoSources returns the chart sources (spec.source and spec.sources[] with a chart key) of
+// the Applications in docs. An Application with several chart sources gets one release per
+// chart, named <application>/<chart>. The destination cluster name is the release context.
+func argoSources(docs []*yaml.Node) []argoSource {
+	var sources []argoSource
+	for _, doc := range docs {
+		if scalarAt(doc, "kind") != "Application" {
+			continue
+		}
+		var nodes []*yaml.Node
+		if n := nodeAt(doc, "spec", "source"); n != nil && scalarAt(n, "chart") != "" {
+			nodes = append(nodes, n)
+		}
+		if list := nodeAt(doc, "spec", "sources"); list != nil && list.Kind == yaml.SequenceNode {
+			for _, n := range list.Content {
+				if scalarAt(n, "chart") != "" {
+					nodes = append(nodes, n)
+				}
+			}
+		}
+		for _, n := range nodes {
+			r := Release{
+				Name:      scalarAt(doc, "metadata", "name"),
+				Namespace: scalarAt(doc, "metadata", "namespace"),
+				Context:   scalarAt(doc, "spec", "destination", "name"),
+				Chart:     Chart{Name: argoChartName(scalarAt(n, "repoURL"), scalarAt(n, "chart")), Version: scalarAt(n, "targetRevision")},
+				Tags:      splitTags(scalarAt(doc, "metadata", "annotations", TagsAnnotation)),
+			}
+			if len(nodes) > 1 {
+				r.Name += "/" + scalarAt(n, "chart")
+			}
+			sources = append(sources, argoSource{id: r.ID(), release: r, node: n})
+		}
+	}
+	return sources
+}
+
+// argoOCI reports whether an Application repoURL is an OCI registry: Argo CD takes helm
+// repositories as http(s) URLs and registries as oci:// or scheme-less references.
+func argoOCI(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "oci://") || !strings.Contains(repoURL, "://")
+}
+
+// argoChartName names a chart like a helmwave file does: <repository>/<chart> with the
+// repository named by ArgoRepoName, or oci://<registry>/<chart>.
+func argoChartName(repoURL, chart string) string {
+	if argoOCI(repoURL) {
+		return "oci://" + strings.TrimSuffix(strings.TrimPrefix(repoURL, "oci://"), "/") + "/" + chart
+	}
+	return ArgoRepoName(repoURL) + "/" + chart
+}
+
+var argoRepoNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ArgoRepoName is the repository name given to an Application repoURL, derived from the URL
+// (https://charts.bitnami.com/bitnami → charts-bitnami-com-bitnami) because Applications
+// reference repositories by URL only.
+func ArgoRepoName(repoURL string) string {
+	_, rest, ok := strings.Cut(repoURL, "://")
+	if !ok {
+		rest = repoURL
+	}
+	return strings.Trim(argoRepoNameChars.ReplaceAllString(strings.ToLower(rest), "-"), "-")
+}
+
+// ParseArgoCD maps the helm chart sources of Argo CD Applications onto the helmwave model
+// and returns their helm repositories as entries named by ArgoRepoName. Git and other
+// non-chart sources are left out. Errors are *ParseError.
+func ParseArgoCD(data []byte) (Helmwave, []*repo.Entry, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return Helmwave{}, nil, err
+	}
+	var hw Helmwave
+	var entries []*repo.Entry
+	seen := make(map[string]bool)
+	for _, s := range argoSources(docs) {
+		hw.Releases = append(hw.Releases, s.release)
+		url := scalarAt(s.node, "repoURL")
+		if name := ArgoRepoName(url); !argoOCI(url) && !seen[name] {
+			seen[name] = true
+			entries = append(entries, &repo.Entry{Name: name, URL: url})
+		}
+	}
+	return hw, entries, nil
+}
+
+// UpdateArgoCDVersions sets targetRevision of the chart sources in versions (release ID →
+// version), editing only those lines.
+func UpdateArgoCDVersions(data []byte, versions map[string]string) (string, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, s := range argoSources(docs) {
+		version, ok := versions[s.id]
+		if !ok || s.release.Chart.Version == "" || s.release.Chart.Version == version {
+			continue
+		}
+		if err := setKeyLine(lines, s.node, "targetRevision", version); err != nil {
+			return "", fmt.Errorf("Application %s: %w", s.id, err)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,51 @@
+package updater
+
+import (
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// ChannelTagPrefix starts release tags that select a release channel, e.g. channel:rc.
+const ChannelTagPrefix = "channel:"
+
+// ChannelStable admits only stable versions; any other channel name also admits
+// prereleases whose first identifier starts with it (channel:rc admits 1.2.0-rc.1).
+const ChannelStable = "stable"
+
+// ReleaseChannel returns the channel selected by a channel:<name> tag (lower-cased), or ""
+// when the release has none. The last such tag wins.
+func ReleaseChannel(tags []string) string {
+	var channel string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if len(t) > len(ChannelTagPrefix) && strings.EqualFold(t[:len(ChannelTagPrefix)], ChannelTagPrefix) {
+			channel = strings.ToLower(strings.TrimSpace(t[len(ChannelTagPrefix):]))
+		}
+	}
+	return channel
+}
+
+// InChannel reports whether version belongs to channel. Versions that are not semver
+// belong to no channel.
+func InChannel(version, channel string) bool {
+	v, err := semver.NewVersion(NormalizeSemVer(version))
+	if err != nil {
+		return false
+	}
+	if v.Prerelease() == "" {
+		return true
+	}
+	return channel != ChannelStable && strings.HasPrefix(strings.ToLower(v.Prerelease()), channel)
+}
+
+// ChannelVersions returns the versions that belong to channel.
+func ChannelVersions(versions []PublishedVersion, channel string) []PublishedVersion {
+	var out []PublishedVersion
+	for _, pv := range versions {
+		if InChannel(pv.Version, channel) {
+			out = append(out, pv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package updater
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// chartAlias is a chart mapping defined once under an anchor ("nginx: &nginx" or
+// "chart: &nginx" with name and version keys) and used by releases as "chart: *nginx".
+type chartAlias struct {
+	anchor      string
+	versionLine int
+	// users are the release blocks that alias the chart or define it inline
+	users []releaseBlock
+}
+
+// chartAliases finds anchored chart mappings used by at least one release.
+func chartAliases(lines []string) []chartAlias {
+	blocks := releaseBlocks(lines)
+	var aliases []chartAlias
+	for i, line := range lines {
+		m := anchorKey.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		a := chartAlias{anchor: m[1], versionLine: -1}
+		hasName := false
+		fieldIndent := -1
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			childIndent := len(lines[j]) - len(strings.TrimLeft(lines[j], " "))
+			if childIndent <= indent {
+				break
+			}
+			if fieldIndent < 0 {
+				fieldIndent = childIndent
+			}
+			if childIndent != fieldIndent {
+				continue
+			}
+			if _, ok := cutKey(trimmed, "name"); ok {
+				hasName = true
+			} else if _, ok := cutKey(trimmed, "version"); ok {
+				a.versionLine = j
+			}
+		}
+		if !hasName || a.versionLine < 0 {
+			continue
+		}
+		for _, b := range blocks {
+			defines := b.start < i && i < b.end && strings.HasPrefix(strings.TrimSpace(line), "chart:")
+			if b.chartAlias == a.anchor || defines {
+				a.users = append(a.users, b)
+			}
+		}
+		if len(a.users) > 0 {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// target returns the version the shared chart is moved to. It is empty, with the reason
+// in conflict, when some release using the chart would move while another is not updated
+// or would move to a different version; it is empty without a conflict when nothing moves.
+// ids are the sorted keys of versionMap (see sortedIDs).
+func (a chartAlias) target(lines []string, versionMap map[string]string, ids []string) (version, conflict string) {
+	current := yamlScalar(strings.TrimPrefix(strings.TrimSpace(lines[a.versionLine]), "version:"))
+	wanted := make(map[string][]string)
+	var pinned []string
+	moves := false
+	for _, b := range a.users {
+		id, ok := b.target(ids)
+		if !ok {
+			pinned = append(pinned, b.name)
+			continue
+		}
+		v := versionMap[id]
+		wanted[v] = append(wanted[v], id)
+		moves = moves || v != current
+	}
+	if !moves {
+		return "", ""
+	}
+	if len(pinned) > 0 {
+		return "", fmt.Sprintf("releases %s share it but are not updated", strings.Join(pinned, ", "))
+	}
+	if len(wanted) > 1 {
+		versions := make([]string, 0, len(wanted))
+		for v, ids := range wanted {
+			versions = append(versions, fmt.Sprintf("%s -> %s", strings.Join(ids, ", "), v))
+		}
+		slices.Sort(versions)
+		return "", "releases sharing it disagree on the version (" + strings.Join(versions, "; ") + ")"
+	}
+	for v := range wanted {
+		version = v
+	}
+	return version, ""
+}
+
+// ChartAliasConflicts describes shared chart anchors ("chart: *nginx") that UpdateText
+// leaves unchanged because the releases using them do not all move to the same version.
+func ChartAliasConflicts(original []byte, versionMap map[string]string) []string {
+	lines := strings.Split(string(original), "\n")
+	ids := sortedIDs(versionMap)
+	var conflicts []string
+	for _, a := range chartAliases(lines) {
+		if _, conflict := a.target(lines, versionMap, ids); conflict != "" {
+			conflicts = append(conflicts, fmt.Sprintf("chart anchor &%s (line %d): %s", a.anchor, a.versionLine+1, conflict))
+		}
+	}
+	return conflicts
+}
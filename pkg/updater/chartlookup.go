@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"slices"
+	"strings"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// ChartLookup maps "repo/chart" to the chart's published versions, built once from the
+// loaded indexes so that files with many releases don't re-walk index entries and re-trim
+// versions for every release.
+type ChartLookup map[string]*chartVersions
+
+// chartVersions is one chart of an index: its versions newest first (as helm sorts
+// entries), with the leading v trimmed.
+type chartVersions struct {
+	versions []PublishedVersion
+	// appVersions maps a version to its appVersion; the first entry of a version wins
+	appVersions map[string]string
+	deprecated  bool
+	movedTo     string
+}
+
+// NewChartLookup indexes every chart of indexes by "repo/chart".
+func NewChartLookup(indexes map[string]*repo.IndexFile) ChartLookup {
+	lookup := make(ChartLookup)
+	for repoName, idx := range indexes {
+		if idx == nil {
+			continue
+		}
+		for chartName, entries := range idx.Entries {
+			cv := &chartVersions{appVersions: make(map[string]string, len(entries))}
+			for _, e := range entries {
+				if e == nil || e.Metadata == nil {
+					continue
+				}
+				v := strings.TrimPrefix(e.Version, "v")
+				appVersion := strings.TrimSpace(e.AppVersion)
+				cv.versions = append(cv.versions, PublishedVersion{Version: v, AppVersion: appVersion, Created: e.Created, Maintainers: Maintainers(e), License: License(e), Sources: Sources(e)})
+				if _, ok := cv.appVersions[v]; !ok {
+					cv.appVersions[v] = appVersion
+				}
+			}
+			if len(cv.versions) == 0 {
+				continue
+			}
+			if entries[0].Metadata != nil && entries[0].Deprecated {
+				cv.deprecated, cv.movedTo = true, MovedTo(entries[0])
+			}
+			lookup[repoName+"/"+chartName] = cv
+		}
+	}
+	return lookup
+}
+
+// Resolve is Resolve for a chart of the lookup; ok is false when the lookup has no versions
+// of repoName/chartName.
+func (l ChartLookup) Resolve(repoName, chartName, currentVersion string) (res Resolution, ok bool) {
+	cv := l[repoName+"/"+chartName]
+	if cv == nil {
+		return Resolution{}, false
+	}
+	latest := cv.versions[0]
+	return Resolution{
+		LatestVersion:     latest.Version,
+		CurrentAppVersion: cv.appVersions[strings.TrimPrefix(currentVersion, "v")],
+		LatestAppVersion:  latest.AppVersion,
+		Deprecated:        cv.deprecated,
+		MovedTo:           cv.movedTo,
+		// callers filter Versions by channel and policy; the lookup's slice stays shared
+		Versions: slices.Clone(cv.versions),
+	}, true
+}
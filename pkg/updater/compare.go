@@ -0,0 +1,60 @@
+package updater
+
+// VersionDiff is a release whose chart is pinned differently in two files. A or B is nil
+// when the release is missing from that file.
+type VersionDiff struct {
+	Release string
+	A, B    *Chart
+}
+
+// CompareVersions lists the releases of a and b whose chart name or version differ, in the
+// order of a followed by releases only in b. Releases are matched by ID; releases left
+// over are matched by name when the name is unique among them, so files for environments
+// with different namespaces or contexts still line up.
+func CompareVersions(a, b *Helmwave) []VersionDiff {
+	matched := make(map[int]int, len(a.Releases))
+	usedB := make(map[int]bool, len(b.Releases))
+	for i, ra := range a.Releases {
+		for j, rb := range b.Releases {
+			if !usedB[j] && ra.ID() == rb.ID() {
+				matched[i], usedB[j] = j, true
+				break
+			}
+		}
+	}
+	for i, ra := range a.Releases {
+		if _, ok := matched[i]; ok {
+			continue
+		}
+		candidate, count := -1, 0
+		for j, rb := range b.Releases {
+			if !usedB[j] && rb.Name == ra.Name {
+				candidate, count = j, count+1
+			}
+		}
+		if count == 1 {
+			matched[i], usedB[candidate] = candidate, true
+		}
+	}
+
+	var diffs []VersionDiff
+	for i := range a.Releases {
+		ca := a.Releases[i].Chart
+		j, ok := matched[i]
+		if !ok {
+			diffs = append(diffs, VersionDiff{Release: a.Releases[i].ID(), A: &ca})
+			continue
+		}
+		cb := b.Releases[j].Chart
+		if ca.Name != cb.Name || ca.Version != cb.Version {
+			diffs = append(diffs, VersionDiff{Release: a.Releases[i].ID(), A: &ca, B: &cb})
+		}
+	}
+	for j := range b.Releases {
+		if !usedB[j] {
+			cb := b.Releases[j].Chart
+			diffs = append(diffs, VersionDiff{Release: b.Releases[j].ID(), B: &cb})
+		}
+	}
+	return diffs
+}
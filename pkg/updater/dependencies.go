@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"strings"
+)
+
+// UpdateDependencyVersions returns Chart.yaml content with the version of each entry in the
+// top-level dependencies list replaced according to versions (dependency name -> version).
+// Like UpdateText it edits lines in place, keeping quoting, comments and key order.
+func UpdateDependencyVersions(original []byte, versions map[string]string) string {
+	lines := strings.Split(string(original), "\n")
+	inDeps := false
+	itemIndent := -1
+	name, versionLine := "", -1
+
+	flush := func() {
+		newVer, ok := versions[name]
+		if ok && versionLine >= 0 {
+			if newLine, changed := replaceKeyLine(lines[versionLine], "version", newVer); changed {
+				debugf("replacing line %d for dependency %s: %q -> %q", versionLine+1, name, lines[versionLine], newLine)
+				lines[versionLine] = newLine
+			}
+		}
+		name, versionLine = "", -1
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent == 0 && !strings.HasPrefix(trimmed, "- ") {
+			if inDeps {
+				flush()
+			}
+			inDeps = trimmed == "dependencies:"
+			itemIndent = -1
+			continue
+		}
+		if !inDeps {
+			continue
+		}
+		field := trimmed
+		if rest, ok := strings.CutPrefix(trimmed, "- "); ok && (itemIndent < 0 || indent == itemIndent) {
+			flush()
+			itemIndent = indent
+			field = strings.TrimSpace(rest)
+		}
+		switch {
+		case strings.HasPrefix(field, "name:"):
+			name = yamlScalar(strings.TrimPrefix(field, "name:"))
+		case strings.HasPrefix(field, "version:"):
+			versionLine = i
+		}
+	}
+	if inDeps {
+		flush()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,27 @@
+// Package updater is the embeddable core of helmwave-updater: parsing helmwave files,
+// resolving the latest chart versions from repository indexes and editing the file text
+// in place while keeping comments, quoting and anchors intact.
+//
+//	data, hw, err := updater.ReadFile("helmwave.yml")
+//	...
+//	res, err := updater.Resolve(index, "nginx", hw.Releases[0].Chart.Version)
+//	hw.Releases[0].Chart.Version = res.LatestVersion
+//	out := updater.UpdateText(data, updater.VersionMap(&hw), updater.ChartVersionMap(&hw))
+//
+// Network access (helm repo update, OCI registries) and CLI concerns stay in the command;
+// the package only logs debug diagnostics through the default slog logger.
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+func debugf(format string, args ...interface{}) {
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	logger.Debug(fmt.Sprintf(format, args...))
+}
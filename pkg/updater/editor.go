@@ -0,0 +1,509 @@
+package updater
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NoupdateTag disables updating for a release (case-insensitive).
+const NoupdateTag = "noupdate"
+
+// UpdateText returns edited file content (string) with versions replaced according to
+// versionMap, keyed by release ID (see Release.ID).
+func UpdateText(original []byte, versionMap map[string]string, chartVersionMap map[string]string) string {
+	text := string(original)
+	lines := strings.Split(text, "\n")
+	ids := sortedIDs(versionMap)
+
+	for _, b := range releaseBlocks(lines) {
+		id, ok := b.target(ids)
+		if !ok {
+			continue
+		}
+		newVer := versionMap[id]
+		debugf("will update release %s -> %s in file text", id, newVer)
+		i, ok := b.chartField(lines, "version")
+		if !ok {
+			continue
+		}
+		i, newLine, changed := replaceKey(lines, i, "version", newVer)
+		if !changed {
+			debugf("existing version for release %s equals target %s; skipping file edit", id, newVer)
+			continue
+		}
+		debugf("replacing line %d for release %s: %q -> %q", i+1, id, lines[i], newLine)
+		lines[i] = newLine
+	}
+
+	// Chart mappings defined once and aliased by releases ("chart: *nginx") are updated
+	// at their definition, when every release using them agrees on the version.
+	for _, a := range chartAliases(lines) {
+		newVer, conflict := a.target(lines, versionMap, ids)
+		if newVer == "" {
+			if conflict != "" {
+				debugf("not updating chart anchor &%s: %s", a.anchor, conflict)
+			}
+			continue
+		}
+		if i, newLine, changed := replaceKey(lines, a.versionLine, "version", newVer); changed {
+			debugf("replacing line %d for chart anchor &%s: %q -> %q", i+1, a.anchor, lines[i], newLine)
+			lines[i] = newLine
+		}
+	}
+
+	// Second pass: update anchored mappings (for example ".options: &options" or
+	// "common: &common") that contain a chart: block, matching chart.name against chartVersionMap.
+	for _, b := range anchorBlocks(lines) {
+		nameLine, ok := chartField(lines, b.start, b.end, b.fieldIndent, "name")
+		if !ok {
+			continue
+		}
+		rest, _ := cutKey(strings.TrimSpace(lines[nameLine]), "name")
+		chartFullName := yamlScalar(rest)
+		newVer, ok := chartVersionMap[chartFullName]
+		if !ok {
+			continue
+		}
+		i, ok := chartField(lines, b.start, b.end, b.fieldIndent, "version")
+		if !ok {
+			continue
+		}
+		if i, newLine, changed := replaceKey(lines, i, "version", newVer); changed {
+			debugf("replacing anchor line %d for chart %s: %q -> %q", i+1, chartFullName, lines[i], newLine)
+			lines[i] = newLine
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// blockIndicator matches the header of a literal or folded block scalar ("|", ">-", "|2+").
+var blockIndicator = regexp.MustCompile(`^[|>][1-9+-]*$`)
+
+// replaceKey is replaceKeyLine for the "key:" line lines[i]. The value of a block scalar
+// ("key: |" or "key: >-") is on the line below, so that line is the one returned for
+// replacement; block scalars spanning several lines are not edited.
+func replaceKey(lines []string, i int, key, newVal string) (int, string, bool) {
+	start, end, _, ok := scalarSpan(lines[i], key)
+	if !ok || !blockIndicator.MatchString(lines[i][start:end]) {
+		newLine, changed := replaceKeyLine(lines[i], key, newVal)
+		return i, newLine, changed
+	}
+	keyIndent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+	j := i + 1
+	for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+		j++
+	}
+	if j == len(lines) || len(lines[j])-len(strings.TrimLeft(lines[j], " ")) <= keyIndent {
+		return i, lines[i], false
+	}
+	for k := j + 1; k < len(lines); k++ {
+		if strings.TrimSpace(lines[k]) != "" && len(lines[k])-len(strings.TrimLeft(lines[k], " ")) > keyIndent {
+			debugf("line %d: %s is a block scalar of several lines; not editing it", i+1, key)
+			return i, lines[i], false
+		} else if strings.TrimSpace(lines[k]) != "" {
+			break
+		}
+	}
+	content := strings.TrimRight(lines[j], " \t")
+	value := strings.TrimLeft(content, " ")
+	if value == newVal || IsSecretRef(value) || IsTemplated(value) {
+		return j, lines[j], false
+	}
+	return j, content[:len(content)-len(value)] + newVal + lines[j][len(content):], true
+}
+
+// replaceKeyLine sets the value of a "key: value" (or "- key: value") line; the key may be
+// quoted and the value may carry a tag or anchor ("!!str 1.2.3"). Only the value is
+// rewritten: indentation, spacing, a trailing comment and the quote style of the value
+// are kept byte for byte. changed is false when the value already matches, is a secret
+// reference or a template expression (see IsTemplated).
+func replaceKeyLine(line, key, newVal string) (string, bool) {
+	start, end, quote, ok := scalarSpan(line, key)
+	if !ok {
+		return line, false
+	}
+	if old := unquoteScalar(line[start:end], quote); old == newVal || IsSecretRef(old) || IsTemplated(old) {
+		return line, false
+	}
+	value := quoteScalar(newVal, quote)
+	if start == end {
+		// no value yet ("key:" or "key: # comment")
+		if line[start-1] == ':' {
+			value = " " + value
+		}
+		if start < len(line) {
+			value += " "
+		}
+	}
+	return line[:start] + value + line[end:], true
+}
+
+// scalarSpan returns the byte range of the value of a "key: value" line, quotes included,
+// and its quote character (0 for a plain scalar). A plain value ends before a comment; an
+// empty value gives an empty range where it would start. Tags and anchors before the value
+// are skipped; an alias ("*name") is not a value that can be edited.
+func scalarSpan(line, key string) (start, end int, quote byte, ok bool) {
+	rest := strings.TrimLeft(line, " \t")
+	if item, found := strings.CutPrefix(rest, "- "); found {
+		rest = strings.TrimLeft(item, " ")
+	}
+	after, found := cutKey(rest, key)
+	if !found {
+		return 0, 0, 0, false
+	}
+	return valueSpan(line, len(line)-len(after))
+}
+
+// valueSpan is scalarSpan for the value that starts at byte from of line, after optional
+// whitespace.
+func valueSpan(line string, from int) (start, end int, quote byte, ok bool) {
+	start = from + len(line[from:]) - len(strings.TrimLeft(line[from:], " \t"))
+	for start < len(line) && (line[start] == '!' || line[start] == '&') {
+		n := strings.IndexAny(line[start:], " \t")
+		if n < 0 {
+			return 0, 0, 0, false
+		}
+		start += n + len(line[start+n:]) - len(strings.TrimLeft(line[start+n:], " \t"))
+	}
+	if start < len(line) && line[start] == '*' {
+		return 0, 0, 0, false
+	}
+	if start == len(line) || line[start] == '#' {
+		return start, start, 0, true
+	}
+	if q := line[start]; q == '"' || q == '\'' {
+		for i := start + 1; i < len(line); i++ {
+			switch {
+			case q == '"' && line[i] == '\\':
+				i++
+			case q == '\'' && line[i] == q && i+1 < len(line) && line[i+1] == q:
+				i++
+			case line[i] == q:
+				return start, i + 1, q, true
+			}
+		}
+		return 0, 0, 0, false
+	}
+	// a comment starts at a '#' after whitespace; one inside a value (ref+vault://app#/key) does not
+	end = len(line)
+	if i := strings.Index(line[start:], " #"); i >= 0 {
+		end = start + i
+	}
+	if i := strings.Index(line[start:end], "\t#"); i >= 0 {
+		end = start + i
+	}
+	return start, start + len(strings.TrimRight(line[start:end], " \t")), 0, true
+}
+
+// cutKey returns what follows "key:" at the start of the trimmed line, for a plain or quoted
+// key.
+func cutKey(trimmed, key string) (string, bool) {
+	for _, k := range [...]string{key, `"` + key + `"`, "'" + key + "'"} {
+		if rest, ok := strings.CutPrefix(trimmed, k+":"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// unquoteScalar returns the value of a scalar as written by scalarSpan.
+func unquoteScalar(raw string, quote byte) string {
+	switch quote {
+	case '"':
+		if v, err := strconv.Unquote(raw); err == nil {
+			return v
+		}
+		return raw[1 : len(raw)-1]
+	case '\'':
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'")
+	}
+	return raw
+}
+
+// quoteScalar writes value in the quote style of quote.
+func quoteScalar(value string, quote byte) string {
+	switch quote {
+	case '"':
+		return strconv.Quote(value)
+	case '\'':
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
+
+// anchorKey matches a mapping key that defines an anchor, e.g. "common: &common".
+var anchorKey = regexp.MustCompile(`^[^\s#&*-][^:#]*:\s*&([^\s#]+)\s*(#.*)?$`)
+
+// anchorBlock is the line range of an anchored mapping; its keys are at fieldIndent.
+type anchorBlock struct {
+	start, end  int
+	fieldIndent int
+}
+
+// anchorBlocks finds anchored mappings ("key: &anchor" and, as before, any ".key:") together
+// with their nested lines. Anchors nested in one that is already open belong to it.
+func anchorBlocks(lines []string) []anchorBlock {
+	var blocks []anchorBlock
+	open := false
+	keyIndent := 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if open {
+			if indent > keyIndent {
+				if blocks[len(blocks)-1].fieldIndent < 0 {
+					blocks[len(blocks)-1].fieldIndent = indent
+				}
+				continue
+			}
+			blocks[len(blocks)-1].end = i
+			open = false
+		}
+		if anchorKey.MatchString(trimmed) || (strings.HasPrefix(trimmed, ".") && strings.Contains(trimmed, ":")) {
+			blocks = append(blocks, anchorBlock{start: i, end: len(lines), fieldIndent: -1})
+			open, keyIndent = true, indent
+		}
+	}
+	return blocks
+}
+
+// UpdateTopLevelScalar replaces the value of a top-level "key: value" line (such as the
+// helmwave version), keeping quoting and a trailing comment.
+func UpdateTopLevelScalar(original []byte, key, value string) string {
+	lines := strings.Split(string(original), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, key+":") {
+			continue
+		}
+		if newLine, changed := replaceKeyLine(line, key, value); changed {
+			debugf("replacing line %d: %q -> %q", i+1, line, newLine)
+			lines[i] = newLine
+		}
+		break
+	}
+	return strings.Join(lines, "\n")
+}
+
+// VersionMap prepares mapping release ID -> version for file editing, skipping noupdate releases.
+func VersionMap(hw *Helmwave) map[string]string {
+	versionMap := make(map[string]string, len(hw.Releases))
+	for _, r := range hw.Releases {
+		if r.Name == "" {
+			continue
+		}
+		if HasTag(r.Tags, NoupdateTag) {
+			debugf("not including release %s in file edits because of '%s' tag", r.Name, NoupdateTag)
+			continue
+		}
+		versionMap[r.ID()] = r.Chart.Version
+	}
+	return versionMap
+}
+
+// ChartVersionMap prepares mapping chart full name (repo/chart) -> version
+// This is used to update top-level anchors like `.options: &options` that contain a `chart:` block.
+func ChartVersionMap(hw *Helmwave) map[string]string {
+	chartMap := make(map[string]string, len(hw.Releases))
+	for _, r := range hw.Releases {
+		if r.Chart.Name == "" {
+			continue
+		}
+		if HasTag(r.Tags, NoupdateTag) {
+			// skip releases marked as noupdate
+			continue
+		}
+		chartMap[r.Chart.Name] = r.Chart.Version
+	}
+	return chartMap
+}
+
+// ChartNameMap prepares mapping release ID -> chart name for UpdateChartNames, skipping noupdate releases.
+func ChartNameMap(hw *Helmwave) map[string]string {
+	names := make(map[string]string, len(hw.Releases))
+	for _, r := range hw.Releases {
+		if r.Name == "" || r.Chart.Name == "" || HasTag(r.Tags, NoupdateTag) {
+			continue
+		}
+		names[r.ID()] = r.Chart.Name
+	}
+	return names
+}
+
+// UpdateChartNames rewrites chart.name lines inside release blocks (e.g. a new git ref),
+// preserving quoting and trailing comments. names is keyed by release ID (see Release.ID).
+// Lines that already match are left untouched.
+func UpdateChartNames(original []byte, names map[string]string) string {
+	lines := strings.Split(string(original), "\n")
+	ids := sortedIDs(names)
+	for _, b := range releaseBlocks(lines) {
+		id, ok := b.target(ids)
+		if !ok {
+			continue
+		}
+		i, ok := b.chartField(lines, "name")
+		if !ok {
+			continue
+		}
+		i, newLine, changed := replaceKey(lines, i, "name", names[id])
+		if !changed {
+			continue
+		}
+		debugf("replacing line %d for release %s: %q -> %q", i+1, id, lines[i], newLine)
+		lines[i] = newLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// releaseBlock is the line range of one "- name:" release item and the identifying fields
+// written in it. namespace and context are empty when the block does not set them itself
+// (for example when they come from a merged anchor).
+type releaseBlock struct {
+	start, end               int
+	fieldIndent              int
+	name, namespace, context string
+	// chartAlias is the anchor of a "chart: *anchor" alias
+	chartAlias string
+}
+
+// releaseBlocks finds "- name:" list items together with their nested lines. Lists nested
+// inside an item belong to that item.
+func releaseBlocks(lines []string) []releaseBlock {
+	var blocks []releaseBlock
+	open := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if open && indent < blocks[len(blocks)-1].fieldIndent {
+			blocks[len(blocks)-1].end = i
+			open = false
+		}
+		if !open {
+			item, ok := strings.CutPrefix(trimmed, "- ")
+			if !ok {
+				continue
+			}
+			if rest, ok := cutKey(strings.TrimLeft(item, " "), "name"); ok {
+				blocks = append(blocks, releaseBlock{start: i, end: len(lines), fieldIndent: indent + 2, name: yamlScalar(rest)})
+				open = true
+			}
+			continue
+		}
+		b := &blocks[len(blocks)-1]
+		if indent != b.fieldIndent {
+			continue
+		}
+		if v, ok := cutKey(trimmed, "namespace"); ok {
+			b.namespace = yamlScalar(v)
+		} else if v, ok := cutKey(trimmed, "context"); ok {
+			b.context = yamlScalar(v)
+		} else if v, ok := cutKey(trimmed, "chart"); ok && strings.HasPrefix(yamlScalar(v), "*") {
+			b.chartAlias = strings.TrimPrefix(yamlScalar(v), "*")
+		}
+	}
+	return blocks
+}
+
+// matches reports whether the block can be the release id: the name must be equal, and so
+// must namespace and context where the block sets them.
+func (b releaseBlock) matches(id string) bool {
+	name, namespace, context := ParseReleaseID(id)
+	return b.name == name &&
+		(b.namespace == "" || b.namespace == namespace) &&
+		(b.context == "" || b.context == context)
+}
+
+// sortedIDs returns the keys of a map keyed by release ID in sorted order, for
+// releaseBlock.target. It is built once per file rather than once per block.
+func sortedIDs(m map[string]string) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ReleaseLines returns the 1-based line each release of ids starts on ("- name:") in text.
+// Releases without a block UpdateText would edit are left out.
+func ReleaseLines(text []byte, ids []string) map[string]int {
+	ids = slices.Clone(ids)
+	sort.Strings(ids)
+	lines := make(map[string]int)
+	for _, b := range releaseBlocks(strings.Split(string(text), "\n")) {
+		if id, ok := b.target(ids); ok {
+			lines[id] = b.start + 1
+		}
+	}
+	return lines
+}
+
+// target returns the single release ID of ids the block matches. A block matching several
+// (duplicate names whose namespace is not written in the block) is left alone. ids are
+// sorted (see sortedIDs), so the same file always logs the same pair.
+func (b releaseBlock) target(ids []string) (string, bool) {
+	found := ""
+	for _, id := range ids {
+		if !b.matches(id) {
+			continue
+		}
+		if found != "" {
+			debugf("release block at line %d matches both %s and %s; not editing it", b.start+1, found, id)
+			return "", false
+		}
+		found = id
+	}
+	return found, found != ""
+}
+
+// chartField returns the index of the "key:" line in the block's chart: section.
+func (b releaseBlock) chartField(lines []string, key string) (int, bool) {
+	return chartField(lines, b.start, b.end, b.fieldIndent, key)
+}
+
+// chartField returns the index of the "key:" line in the chart: mapping that is a direct
+// child (at fieldIndent) of the block lines[start:end].
+func chartField(lines []string, start, end, fieldIndent int, key string) (int, bool) {
+	chartIndent := -1
+	for i := start + 1; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		if chartIndent < 0 {
+			if rest, ok := cutKey(trimmed, "chart"); ok && indent == fieldIndent && strings.TrimSpace(rest) == "" {
+				chartIndent = indent
+			}
+			continue
+		}
+		if indent <= chartIndent {
+			return 0, false
+		}
+		if _, ok := cutKey(trimmed, key); ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// yamlScalar returns a plain or quoted scalar without tag or anchor, quotes and trailing comment.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if prop, rest, ok := strings.Cut(s, " "); ok && (strings.HasPrefix(prop, "!") || strings.HasPrefix(prop, "&")) {
+		s = strings.TrimSpace(rest)
+	}
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+	return strings.Trim(s, "'\"")
+}
@@ -0,0 +1,56 @@
+package updater
+
+import "strings"
+
+// Feature is a helmwave file feature that needs a minimum helmwave version.
+type Feature struct {
+	Name       string
+	MinVersion string
+}
+
+// Helmwave features checked by DetectFeatures, with the first helmwave release supporting each.
+var (
+	FeatureRegistries = Feature{Name: "registries section", MinVersion: "0.12.0"}
+	FeatureOCICharts  = Feature{Name: "oci:// charts", MinVersion: "0.12.0"}
+	FeatureLifecycle  = Feature{Name: "release lifecycle hooks", MinVersion: "0.19.0"}
+	FeatureMonitors   = Feature{Name: "monitors", MinVersion: "0.29.0"}
+)
+
+// DetectFeatures returns the version-gated features used by a helmwave file, in a stable
+// order. data is the raw file: sections stripped by Parse (registries) are found there.
+func DetectFeatures(data []byte, hw *Helmwave) []Feature {
+	var lifecycle, monitors, oci bool
+	for _, rel := range hw.Releases {
+		_, hasLifecycle := rel.Inline["lifecycle"]
+		_, hasMonitors := rel.Inline["monitors"]
+		lifecycle = lifecycle || hasLifecycle
+		monitors = monitors || hasMonitors
+		oci = oci || strings.HasPrefix(strings.TrimSpace(rel.Chart.Name), "oci://")
+	}
+	monitors = monitors || hasTopLevelKey(data, "monitors")
+
+	var features []Feature
+	if hasTopLevelKey(data, "registries") {
+		features = append(features, FeatureRegistries)
+	}
+	if oci {
+		features = append(features, FeatureOCICharts)
+	}
+	if lifecycle {
+		features = append(features, FeatureLifecycle)
+	}
+	if monitors {
+		features = append(features, FeatureMonitors)
+	}
+	return features
+}
+
+// hasTopLevelKey reports whether data has an unindented "key:" line.
+func hasTopLevelKey(data []byte, key string) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, key+":") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// ParseFlux maps the HelmRelease objects of Flux manifests onto the helmwave model and
+// returns the HelmRepository objects as repository entries. A chart from a HelmRepository
+// is named <repository>/<chart>, or <url>/<chart> for OCI repositories, so versions
+// resolve as for helmwave files; charts from GitRepository or Bucket sources keep their
+// path. HelmReleases without spec.chart.spec (chartRef) are left out. Errors are *ParseError.
+func ParseFlux(data []byte) (Helmwave, []*repo.Entry, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return Helmwave{}, nil, err
+	}
+
+	type fluxRepo struct {
+		url string
+		oci bool
+	}
+	repos := make(map[string]fluxRepo)
+	var entries []*repo.Entry
+	for _, doc := range docs {
+		if scalarAt(doc, "kind") != "HelmRepository" {
+			continue
+		}
+		name, url := scalarAt(doc, "metadata", "name"), scalarAt(doc, "spec", "url")
+		if name == "" || url == "" {
+			continue
+		}
+		r := fluxRepo{url: url, oci: scalarAt(doc, "spec", "type") == "oci" || strings.HasPrefix(url, "oci://")}
+		repos[name] = r
+		if !r.oci {
+			entries = append(entries, &repo.Entry{Name: name, URL: url})
+		}
+	}
+
+	var hw Helmwave
+	for _, doc := range docs {
+		if scalarAt(doc, "kind") != "HelmRelease" {
+			continue
+		}
+		name := scalarAt(doc, "metadata", "name")
+		spec := nodeAt(doc, "spec", "chart", "spec")
+		if spec == nil {
+			debugf("HelmRelease %s has no spec.chart.spec; skipped", name)
+			continue
+		}
+		chart := scalarAt(spec, "chart")
+		if scalarAt(spec, "sourceRef", "kind") == "HelmRepository" {
+			if r, ok := repos[scalarAt(spec, "sourceRef", "name")]; ok && r.oci {
+				chart = strings.TrimSuffix(r.url, "/") + "/" + chart
+			} else {
+				chart = scalarAt(spec, "sourceRef", "name") + "/" + chart
+			}
+		}
+		hw.Releases = append(hw.Releases, Release{
+			Name:      name,
+			Namespace: scalarAt(doc, "metadata", "namespace"),
+			Chart:     Chart{Name: chart, Version: scalarAt(spec, "version")},
+			Tags:      splitTags(scalarAt(doc, "metadata", "annotations", TagsAnnotation)),
+		})
+	}
+	return hw, entries, nil
+}
+
+// UpdateFluxVersions sets spec.chart.spec.version of the HelmReleases in versions (release
+// ID → version), editing only those lines.
+func UpdateFluxVersions(data []byte, versions map[string]string) (string, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, doc := range docs {
+		if scalarAt(doc, "kind") != "HelmRelease" {
+			continue
+		}
+		id := ReleaseID(scalarAt(doc, "metadata", "name"), scalarAt(doc, "metadata", "namespace"), "")
+		spec := nodeAt(doc, "spec", "chart", "spec")
+		version, ok := versions[id]
+		if !ok || spec == nil || scalarAt(spec, "version") == version {
+			continue
+		}
+		if err := setKeyLine(lines, spec, "version", version); err != nil {
+			return "", fmt.Errorf("HelmRelease %s: %w", id, err)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
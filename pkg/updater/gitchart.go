@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"net/url"
+	"strings"
+)
+
+// GitChart is a chart referenced in helm-git plugin form:
+// git+https://github.com/org/repo@path/to/chart?ref=v1.2.3&sparse=0
+type GitChart struct {
+	// Repo is the clonable repository URL (without the git+ prefix)
+	Repo string
+	Path string
+	Ref  string
+	// query keeps the remaining parameters in their original order
+	query string
+}
+
+// ParseGitChart parses a helm-git chart name; ok is false for any other chart reference.
+func ParseGitChart(name string) (GitChart, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(name), "git+")
+	if !ok {
+		return GitChart{}, false
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+
+	// the path separator is the last '@' after the host (ssh URLs carry git@ before it)
+	hostStart := strings.Index(rest, "://")
+	if hostStart < 0 {
+		return GitChart{}, false
+	}
+	pathStart := strings.Index(rest[hostStart+3:], "/")
+	if pathStart < 0 {
+		return GitChart{}, false
+	}
+	pathStart += hostStart + 3
+	var g GitChart
+	if at := strings.LastIndex(rest[pathStart:], "@"); at >= 0 {
+		g.Repo, g.Path = rest[:pathStart+at], rest[pathStart+at+1:]
+	} else {
+		g.Repo = rest
+	}
+
+	var kept []string
+	for _, kv := range strings.Split(query, "&") {
+		if kv == "" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(kv, "ref="); ok {
+			g.Ref, _ = url.QueryUnescape(v)
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	g.query = strings.Join(kept, "&")
+	return g, true
+}
+
+// refEscaper escapes only what would break the query or not survive QueryUnescape,
+// so refs like release/1.2 stay as written.
+var refEscaper = strings.NewReplacer("%", "%25", "&", "%26", "#", "%23", " ", "%20", "+", "%2B")
+
+// WithRef renders the chart name pointing at ref, keeping the other parameters.
+func (g GitChart) WithRef(ref string) string {
+	var b strings.Builder
+	b.WriteString("git+" + g.Repo)
+	if g.Path != "" {
+		b.WriteString("@" + g.Path)
+	}
+	b.WriteString("?ref=" + refEscaper.Replace(ref))
+	if g.query != "" {
+		b.WriteString("&" + g.query)
+	}
+	return b.String()
+}
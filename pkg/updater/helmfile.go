@@ -0,0 +1,126 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// helmfileDirective matches a line holding only template actions ({{ if ... }}, {{ end }}).
+var helmfileDirective = regexp.MustCompile(`^\s*(\{\{.*?\}\}\s*)+$`)
+
+// helmfileTemplate stands in for an inline template expression while a helmfile is parsed.
+const helmfileTemplate = "__helmfile_template__"
+
+// maskTemplates blanks template-only lines and replaces inline template expressions with a
+// placeholder, keeping the line count, so a helmfile.yaml.gotmpl parses as YAML and node
+// lines still index the original text.
+func maskTemplates(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if helmfileDirective.MatchString(line) {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = anyTemplateRe.ReplaceAllString(line, helmfileTemplate)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// helmfileReleases returns the release mappings of every document.
+func helmfileReleases(docs []*yaml.Node) []*yaml.Node {
+	var releases []*yaml.Node
+	for _, doc := range docs {
+		if list := nodeAt(doc, "releases"); list != nil && list.Kind == yaml.SequenceNode {
+			for _, r := range list.Content {
+				if r.Kind == yaml.MappingNode {
+					releases = append(releases, r)
+				}
+			}
+		}
+	}
+	return releases
+}
+
+// helmfileReleaseID identifies a helmfile release like a helmwave one; kubeContext is the context.
+func helmfileReleaseID(r *yaml.Node) string {
+	return ReleaseID(scalarAt(r, "name"), scalarAt(r, "namespace"), scalarAt(r, "kubeContext"))
+}
+
+// ParseHelmfile maps the releases of a helmfile onto the helmwave model and returns its
+// repositories as entries. Charts of OCI repositories (oci: true) are named
+// oci://<url>/<chart>; other charts keep their repo/chart or path name. Template expressions
+// are not evaluated: a templated version, like one merged in with <<:, is treated as
+// missing. Tags come from the TagsAnnotation label. Errors are *ParseError.
+func ParseHelmfile(data []byte) (Helmwave, []*repo.Entry, error) {
+	docs, err := manifestDocuments(maskTemplates(data))
+	if err != nil {
+		return Helmwave{}, nil, err
+	}
+
+	ociRepos := make(map[string]string)
+	var entries []*repo.Entry
+	for _, doc := range docs {
+		list := nodeAt(doc, "repositories")
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, r := range list.Content {
+			name, url := scalarAt(r, "name"), scalarAt(r, "url")
+			if name == "" || url == "" || strings.Contains(url, helmfileTemplate) {
+				continue
+			}
+			if scalarAt(r, "oci") == "true" || strings.HasPrefix(url, "oci://") {
+				ociRepos[name] = "oci://" + strings.TrimSuffix(strings.TrimPrefix(url, "oci://"), "/")
+				continue
+			}
+			entries = append(entries, &repo.Entry{Name: name, URL: url})
+		}
+	}
+
+	var hw Helmwave
+	for _, r := range helmfileReleases(docs) {
+		chart := scalarAt(r, "chart")
+		if repoName, name, ok := strings.Cut(chart, "/"); ok && ociRepos[repoName] != "" {
+			chart = ociRepos[repoName] + "/" + name
+		}
+		version := scalarAt(r, "version")
+		if strings.Contains(version, helmfileTemplate) || IsSecretRef(version) {
+			debugf("helmfile release %s: templated version; skipped", scalarAt(r, "name"))
+			version = ""
+		}
+		hw.Releases = append(hw.Releases, Release{
+			Name:      scalarAt(r, "name"),
+			Namespace: scalarAt(r, "namespace"),
+			Context:   scalarAt(r, "kubeContext"),
+			Chart:     Chart{Name: chart, Version: version},
+			Tags:      splitTags(scalarAt(r, "labels", TagsAnnotation)),
+		})
+	}
+	return hw, entries, nil
+}
+
+// UpdateHelmfileVersions sets the version key of the releases in versions (release ID →
+// version), editing only those lines.
+func UpdateHelmfileVersions(data []byte, versions map[string]string) (string, error) {
+	docs, err := manifestDocuments(maskTemplates(data))
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, r := range helmfileReleases(docs) {
+		id := helmfileReleaseID(r)
+		version, ok := versions[id]
+		current := scalarAt(r, "version")
+		if !ok || current == "" || current == version || strings.Contains(current, helmfileTemplate) || IsSecretRef(current) {
+			continue
+		}
+		if err := setKeyLine(lines, r, "version", version); err != nil {
+			return "", fmt.Errorf("release %s: %w", id, err)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,202 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// includeLine matches a line holding only a template action that reads another file, such as
+// {{ readFile "releases/ingress.yml" | indent 2 }}; group 1 is the path.
+var includeLine = regexp.MustCompile(`^\s*\{\{-?\s*(?:tpl\s*\(\s*)?readFile\s+"([^"]+)"[^}]*?-?\}\}\s*$`)
+
+// includeIndent matches the indent or nindent filter of an include action.
+var includeIndent = regexp.MustCompile(`\bn?indent\s+(\d+)`)
+
+// Included is a helmwave file with the files it includes through readFile inlined, so the
+// releases defined in them are parsed and edited like the rest of the file. Editors change
+// lines in place, so Split can hand every edited line back to the file it came from.
+type Included struct {
+	// Text is the main file with every include line replaced by the included file
+	Text string
+	// Files are the main file followed by the included files in order of first inclusion
+	Files   []string
+	sources map[string][]string
+	// origins locates each line of Text in its file
+	origins []lineOrigin
+}
+
+// lineOrigin is the file and 0-based line a line of Included.Text comes from, and the
+// indentation an indent filter added to it.
+type lineOrigin struct {
+	file   string
+	line   int
+	indent int
+}
+
+// Inline reads filename and inlines the files it includes, recursively. Include paths are
+// relative to the directory of filename, like helmwave resolves them from where it runs.
+// Only lines consisting of a readFile action with a literal path are followed; an indent or
+// nindent filter indents the included lines.
+func Inline(filename string) (*Included, error) {
+	in := &Included{sources: make(map[string][]string)}
+	var text []string
+	if err := in.inline(filename, filepath.Dir(filename), 0, nil, &text); err != nil {
+		return nil, err
+	}
+	in.Text = strings.Join(text, "\n")
+	return in, nil
+}
+
+func (in *Included) inline(file, dir string, indent int, stack []string, text *[]string) error {
+	for _, f := range stack {
+		if f == file {
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), file)
+		}
+	}
+	stack = append(stack, file)
+	lines, ok := in.sources[file]
+	if !ok {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if len(stack) > 1 {
+				return fmt.Errorf("%s includes %s: %w", stack[len(stack)-2], file, err)
+			}
+			return err
+		}
+		lines = strings.Split(string(data), "\n")
+		in.sources[file] = lines
+		in.Files = append(in.Files, file)
+	}
+	// an included file's final newline ends the include line, it does not add a line
+	if len(stack) > 1 && len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	pad := strings.Repeat(" ", indent)
+	for i, line := range lines {
+		m := includeLine.FindStringSubmatch(line)
+		if m == nil {
+			if line != "" {
+				line = pad + line
+			}
+			*text = append(*text, line)
+			in.origins = append(in.origins, lineOrigin{file: file, line: i, indent: indent})
+			continue
+		}
+		path := m[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		extra := 0
+		if n := includeIndent.FindStringSubmatch(line); n != nil {
+			extra, _ = strconv.Atoi(n[1])
+		}
+		debugf("%s:%d: including %s", file, i+1, path)
+		if err := in.inline(path, dir, indent+extra, stack, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Locate returns the file and 1-based line that line (1-based) of Text comes from.
+func (in *Included) Locate(line int) (string, int) {
+	if line < 1 || line > len(in.origins) {
+		return in.Files[0], line
+	}
+	o := in.origins[line-1]
+	return o.file, o.line + 1
+}
+
+// Split maps updated, an edited Text, back onto the files it was inlined from. It returns
+// the content of the main file and of every included file with an edited line, keyed by
+// file name. A file included twice must be edited the same way at both places.
+func (in *Included) Split(updated string) (map[string]string, error) {
+	lines := strings.Split(updated, "\n")
+	if len(lines) != len(in.origins) {
+		return nil, errors.New("edited text does not match the included files line by line")
+	}
+	edited := make(map[string][]string)
+	original := strings.Split(in.Text, "\n")
+	for i, line := range lines {
+		if line == original[i] {
+			continue
+		}
+		o := in.origins[i]
+		unpadded, ok := strings.CutPrefix(line, strings.Repeat(" ", o.indent))
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: edited line lost the indentation of its include", o.file, o.line+1)
+		}
+		if edited[o.file] == nil {
+			edited[o.file] = append([]string(nil), in.sources[o.file]...)
+		}
+		if prev := edited[o.file][o.line]; prev != in.sources[o.file][o.line] && prev != unpadded {
+			return nil, fmt.Errorf("%s:%d: included more than once and edited differently (%q, %q)", o.file, o.line+1, prev, unpadded)
+		}
+		edited[o.file][o.line] = unpadded
+	}
+	files := map[string]string{in.Files[0]: strings.Join(in.sources[in.Files[0]], "\n")}
+	for file, lines := range edited {
+		files[file] = strings.Join(lines, "\n")
+	}
+	return files, nil
+}
+
+// Join is the reverse of Split: it returns Text with the files in contents, keyed by file
+// name, in place of the ones inlined. The includes are not followed again, so every file
+// must keep its lines and its include lines.
+func (in *Included) Join(contents map[string]string) (string, error) {
+	sources := make(map[string][]string, len(contents))
+	for file, content := range contents {
+		original, ok := in.sources[file]
+		if !ok {
+			return "", fmt.Errorf("%s is not included", file)
+		}
+		lines := strings.Split(content, "\n")
+		if len(lines) != len(original) {
+			return "", fmt.Errorf("%s has %d lines, the included file %d", file, len(lines), len(original))
+		}
+		for i, line := range original {
+			if includeLine.MatchString(line) && lines[i] != line {
+				return "", fmt.Errorf("%s:%d: include changed to %q", file, i+1, lines[i])
+			}
+		}
+		sources[file] = lines
+	}
+	text := make([]string, len(in.origins))
+	for i, o := range in.origins {
+		lines, ok := sources[o.file]
+		if !ok {
+			lines = in.sources[o.file]
+		}
+		if line := lines[o.line]; line != "" {
+			text[i] = strings.Repeat(" ", o.indent) + line
+		}
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// ReadIncluded is ReadFile for a helmwave file with includes: it inlines them (see Inline)
+// and parses the result. Parse errors are located in the file that holds the line.
+func ReadIncluded(filename string) (*Included, Helmwave, error) {
+	in, err := Inline(filename)
+	if err != nil {
+		return nil, Helmwave{}, err
+	}
+	if len(in.Files) > 1 {
+		debugf("%s includes %s", filename, strings.Join(in.Files[1:], ", "))
+	}
+	hw, err := Parse([]byte(in.Text))
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.File, perr.Line = in.Locate(perr.Line)
+		}
+		return nil, Helmwave{}, err
+	}
+	return in, hw, nil
+}
@@ -0,0 +1,36 @@
+package updater
+
+import (
+	"strings"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// licenseAnnotations are the chart annotations naming its license (an SPDX expression), in
+// order of preference: Artifact Hub's and the one Bitnami charts set.
+var licenseAnnotations = []string{"artifacthub.io/license", "licenses"}
+
+// License returns the license an index entry's annotations declare, "" when none does.
+func License(cv *repo.ChartVersion) string {
+	if cv == nil || cv.Metadata == nil {
+		return ""
+	}
+	for _, key := range licenseAnnotations {
+		if l := strings.TrimSpace(cv.Annotations[key]); l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+// LicenseChanged reports whether the candidate version declares another license than the
+// version in use. Case is ignored; an undeclared license on either side is not a change.
+func LicenseChanged(current, candidate string) bool {
+	return current != "" && candidate != "" && !strings.EqualFold(current, candidate)
+}
+
+// LicenseOf returns the license of version in versions, "" when it is not listed.
+func LicenseOf(versions []PublishedVersion, version string) string {
+	v, _ := FindVersion(versions, version)
+	return v.License
+}
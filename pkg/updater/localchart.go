@@ -0,0 +1,12 @@
+package updater
+
+import "strings"
+
+// IsLocalChart reports whether a chart name is a filesystem path (./charts/foo, ../foo,
+// /abs/path or ~/charts/foo) rather than a repository or registry reference.
+func IsLocalChart(name string) bool {
+	name = strings.TrimSpace(name)
+	return name == "." || name == ".." ||
+		strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") ||
+		strings.HasPrefix(name, "/") || strings.HasPrefix(name, "~/")
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"slices"
+	"strings"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// Maintainers returns the maintainers of an index entry as "Name <email>" (or the name or
+// email alone). The result is nil when the entry lists none, so "not recorded" and "no
+// maintainers" read the same.
+func Maintainers(cv *repo.ChartVersion) []string {
+	if cv == nil || cv.Metadata == nil {
+		return nil
+	}
+	var out []string
+	for _, m := range cv.Maintainers {
+		if m == nil {
+			continue
+		}
+		name, email := strings.TrimSpace(m.Name), strings.TrimSpace(m.Email)
+		switch {
+		case name != "" && email != "":
+			out = append(out, name+" <"+email+">")
+		case name != "" || email != "":
+			out = append(out, name+email)
+		}
+	}
+	return out
+}
+
+// MaintainerChange is how the maintainers of a chart differ between two versions.
+type MaintainerChange struct {
+	Added   []string
+	Removed []string
+	// Kept counts the maintainers listed by both versions
+	Kept int
+}
+
+// CompareMaintainers compares the maintainers of the version in use with those of the
+// candidate. Maintainers are matched by email, case-insensitively, and by name when they
+// have no email, so a renamed maintainer with the same address is not a change. Nothing
+// changed when either list is empty: the index did not record them.
+func CompareMaintainers(current, candidate []string) MaintainerChange {
+	var c MaintainerChange
+	if len(current) == 0 || len(candidate) == 0 {
+		return c
+	}
+	keys := make([]string, 0, len(candidate))
+	for _, m := range candidate {
+		keys = append(keys, maintainerKey(m))
+	}
+	for _, m := range current {
+		if slices.Contains(keys, maintainerKey(m)) {
+			c.Kept++
+		} else {
+			c.Removed = append(c.Removed, m)
+		}
+	}
+	keys = keys[:0]
+	for _, m := range current {
+		keys = append(keys, maintainerKey(m))
+	}
+	for _, m := range candidate {
+		if !slices.Contains(keys, maintainerKey(m)) {
+			c.Added = append(c.Added, m)
+		}
+	}
+	return c
+}
+
+// Significant reports whether at least half of the current maintainers are gone, the kind
+// of handover worth a look before trusting the new version.
+func (c MaintainerChange) Significant() bool {
+	return len(c.Removed) > 0 && 2*len(c.Removed) >= len(c.Removed)+c.Kept
+}
+
+// maintainerKey identifies a maintainer formatted by Maintainers.
+func maintainerKey(m string) string {
+	if _, email, ok := strings.Cut(m, "<"); ok {
+		m = strings.TrimSuffix(email, ">")
+	}
+	return strings.ToLower(strings.TrimSpace(m))
+}
+
+// MaintainersOf returns the maintainers of version in versions, nil when it is not listed.
+func MaintainersOf(versions []PublishedVersion, version string) []string {
+	v, _ := FindVersion(versions, version)
+	return v.Maintainers
+}
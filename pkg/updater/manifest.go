@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TagsAnnotation holds comma-separated tags (noupdate, channel:rc, ...) of a release in a
+// format without tags: a Flux HelmRelease annotation or a helmfile release label.
+const TagsAnnotation = "helmwave-updater/tags"
+
+// splitTags splits a TagsAnnotation value.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// manifestDocuments parses multi-document YAML such as Kubernetes manifests and returns the
+// root mapping of every non-empty document. Node lines count from the start of data, so
+// they index strings.Split(data, "\n") directly. Errors are *ParseError.
+func manifestDocuments(data []byte) ([]*yaml.Node, error) {
+	lines := strings.Split(string(data), "\n")
+	origin := make([]int, len(lines))
+	for i := range origin {
+		origin[i] = i
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, newParseError(err, lines, origin)
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+// nodeAt follows keys through nested mappings and returns the value, or nil.
+func nodeAt(n *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		n = mappingValue(n, key)
+	}
+	return n
+}
+
+// scalarAt is nodeAt for scalar values; it returns "" when the value is missing or not a scalar.
+func scalarAt(n *yaml.Node, keys ...string) string {
+	if n = nodeAt(n, keys...); n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// setKeyLine sets the scalar value of key in mapping m on its line of lines, keeping the
+// line's indentation, quoting and comment. Only block-style "key: value" lines are edited.
+func setKeyLine(lines []string, m *yaml.Node, key, value string) error {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		if k.Value != key {
+			continue
+		}
+		idx := k.Line - 1
+		if v.Kind != yaml.ScalarNode || v.Line != k.Line || idx >= len(lines) || !strings.HasPrefix(strings.TrimSpace(lines[idx]), key+":") {
+			return fmt.Errorf("line %d: %s is not a plain %q line", k.Line, key, key+": value")
+		}
+		lines[idx], _ = replaceKeyLine(lines[idx], key, value)
+		return nil
+	}
+	return fmt.Errorf("line %d: no %s key", m.Line, key)
+}
@@ -0,0 +1,23 @@
+package updater
+
+import "strings"
+
+// SplitOCIDigest splits an OCI chart reference pinned by digest
+// (oci://ghcr.io/org/chart@sha256:...) into the repository reference and the digest.
+// The digest is empty for other chart names.
+func SplitOCIDigest(name string) (ref, digest string) {
+	if !strings.HasPrefix(name, "oci://") {
+		return name, ""
+	}
+	at := strings.LastIndex(name, "@")
+	if at < 0 || !strings.Contains(name[at+1:], ":") {
+		return name, ""
+	}
+	return name[:at], name[at+1:]
+}
+
+// WithOCIDigest renders an OCI chart reference pinned to digest, replacing any previous digest.
+func WithOCIDigest(name, digest string) string {
+	ref, _ := SplitOCIDigest(name)
+	return ref + "@" + digest
+}
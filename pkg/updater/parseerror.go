@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is a YAML error of a helmwave file, located in the original text (before the
+// repositories and registries sections were stripped).
+type ParseError struct {
+	// File is set by ReadFile
+	File string
+	// Line and Column are 1-based; 0 when the YAML error has no location
+	Line   int
+	Column int
+	Msg    string
+	// Snippet is the offending line with its neighbours, numbered
+	Snippet string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	loc := e.File
+	if loc == "" {
+		loc = "<input>"
+	}
+	if e.Line > 0 {
+		loc += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			loc += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	msg := loc + ": " + e.Msg
+	if e.Snippet != "" {
+		msg += "\n" + e.Snippet
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// yamlLocation matches the position in yaml.v3 messages ("line 12: ..." or
+// "line 12, column 5: ...")
+var yamlLocation = regexp.MustCompile(`line (\d+)(?:, column (\d+))?: `)
+
+// newParseError maps the first located message of a yaml.v3 error back to lines, the
+// original file; origin gives the original index of each parsed line.
+func newParseError(err error, lines []string, origin []int) *ParseError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		msg = typeErr.Errors[0]
+		if n := len(typeErr.Errors) - 1; n > 0 {
+			msg += fmt.Sprintf(" (and %d more)", n)
+		}
+	}
+	perr := &ParseError{Msg: msg, Err: err}
+	m := yamlLocation.FindStringSubmatchIndex(msg)
+	if m == nil {
+		return perr
+	}
+	line, _ := strconv.Atoi(msg[m[2]:m[3]])
+	if m[4] >= 0 {
+		perr.Column, _ = strconv.Atoi(msg[m[4]:m[5]])
+	}
+	perr.Msg = msg[:m[0]] + msg[m[1]:]
+	if line < 1 || line > len(origin) {
+		return perr
+	}
+	perr.Line = origin[line-1] + 1
+	perr.Snippet = snippet(lines, perr.Line)
+	return perr
+}
+
+// snippet numbers the line (1-based) and up to two lines around it, marking it with ">".
+func snippet(lines []string, line int) string {
+	var b strings.Builder
+	width := len(strconv.Itoa(min(line+2, len(lines))))
+	for n := max(line-2, 1); n <= min(line+2, len(lines)); n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, n, lines[n-1])
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,373 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// Структуры для десериализации helmwave yaml (helmwave.yml.tpl)
+// Поля снабжены тегами `yaml` для корректного распарсивания.
+
+// Helmwave представляет корневой объект файла.
+type Helmwave struct {
+	// Version is the helmwave version the file is written for
+	Version  string    `yaml:"version,omitempty"`
+	Releases []Release `yaml:"releases,omitempty"`
+}
+
+type Release struct {
+	Name      string        `yaml:"name"`
+	Chart     Chart         `yaml:"chart"`
+	Namespace string        `yaml:"namespace,omitempty"`
+	Context   string        `yaml:"context,omitempty"`
+	Tags      []string      `yaml:"tags,omitempty"`
+	Values    []interface{} `yaml:"values,omitempty"`
+
+	// Inline captures any additional merged keys (for example from <<: *options)
+	Inline map[string]interface{} `yaml:",inline"`
+}
+
+// ID identifies the release within a file like helmwave does: name@namespace, plus
+// @context when the release targets a kube context. A release without either is its name.
+func (r Release) ID() string {
+	return ReleaseID(r.Name, r.Namespace, r.Context)
+}
+
+// ReleaseID renders a release identifier; see Release.ID.
+func ReleaseID(name, namespace, context string) string {
+	switch {
+	case context != "":
+		return name + "@" + namespace + "@" + context
+	case namespace != "":
+		return name + "@" + namespace
+	}
+	return name
+}
+
+// ParseReleaseID splits an identifier rendered by ReleaseID.
+func ParseReleaseID(id string) (name, namespace, context string) {
+	name, rest, _ := strings.Cut(id, "@")
+	namespace, context, _ = strings.Cut(rest, "@")
+	return name, namespace, context
+}
+
+// Chart описывает информацию о чарте для релиза.
+type Chart struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+	// capture additional arbitrary chart keys (e.g. insecureskiptlsverify)
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// Repository описывает запись из секции repositories (значения уже с подставленными env).
+type Repository struct {
+	Name               string `yaml:"name"`
+	URL                string `yaml:"url"`
+	Username           string `yaml:"username,omitempty"`
+	Password           string `yaml:"password,omitempty"`
+	CertFile           string `yaml:"certFile,omitempty"`
+	KeyFile            string `yaml:"keyFile,omitempty"`
+	CAFile             string `yaml:"caFile,omitempty"`
+	Insecure           bool   `yaml:"insecure_skip_tls_verify,omitempty"`
+	PassCredentialsAll bool   `yaml:"pass_credentials_all,omitempty"`
+
+	// lowercase variants seen in helmwave files
+	CertFileLower string `yaml:"certfile,omitempty"`
+	KeyFileLower  string `yaml:"keyfile,omitempty"`
+	CAFileLower   string `yaml:"cafile,omitempty"`
+	InsecureLower bool   `yaml:"insecureskiptlsverify,omitempty"`
+}
+
+// Entry converts r to a helm repository entry.
+func (r Repository) Entry() *repo.Entry {
+	e := &repo.Entry{
+		Name:                  r.Name,
+		URL:                   r.URL,
+		Username:              r.Username,
+		Password:              r.Password,
+		CertFile:              firstNonEmpty(r.CertFile, r.CertFileLower),
+		KeyFile:               firstNonEmpty(r.KeyFile, r.KeyFileLower),
+		CAFile:                firstNonEmpty(r.CAFile, r.CAFileLower),
+		InsecureSkipTLSVerify: r.Insecure || r.InsecureLower,
+		PassCredentialsAll:    r.PassCredentialsAll,
+	}
+	return e
+}
+
+// ReadFile reads and unmarshals a helmwave YAML file. The raw bytes are returned as well:
+// edits are applied to the original text (see UpdateText), never to re-marshalled YAML.
+func ReadFile(filename string) ([]byte, Helmwave, error) {
+	debugf("reading input file: %s", filename)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, Helmwave{}, err
+	}
+	debugf("read %d bytes from %s", len(data), filename)
+	hw, err := Parse(data)
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.File = filename
+		}
+		return nil, Helmwave{}, err
+	}
+	return data, hw, nil
+}
+
+// Parse unmarshals helmwave YAML. The repositories and registries sections are stripped from
+// the in-memory text first: they may contain templating expressions (e.g. {{ env "..." }})
+// which break strict YAML parsing. Template expressions elsewhere in a line (such as
+// "version: {{ env "X" }}") are kept as written in the parsed values, unevaluated.
+// Errors are *ParseError, located in data.
+func Parse(data []byte) (Helmwave, error) {
+	lines := strings.Split(string(data), "\n")
+	origin := make([]int, len(lines))
+	for i := range origin {
+		origin[i] = i
+	}
+	processed, origin := removeTopLevelSection(lines, origin, "repositories")
+	processed, origin = removeTopLevelSection(processed, origin, "registries")
+
+	// "{{" would start a flow mapping; lines holding only template actions are left alone
+	var templates []string
+	for i, line := range processed {
+		if helmfileDirective.MatchString(line) {
+			continue
+		}
+		processed[i] = anyTemplateRe.ReplaceAllStringFunc(line, func(m string) string {
+			templates = append(templates, m)
+			return fmt.Sprintf(templatePlaceholder, len(templates)-1)
+		})
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(processed, "\n")), &doc); err != nil {
+		return Helmwave{}, newParseError(err, lines, origin)
+	}
+	var hw Helmwave
+	if doc.Kind == 0 {
+		return hw, nil
+	}
+	restoreTemplates(&doc, templates)
+	if err := doc.Decode(&hw); err != nil {
+		return Helmwave{}, newParseError(err, lines, origin)
+	}
+	// a version written as a block scalar ("version: |") keeps its line break
+	for i := range hw.Releases {
+		hw.Releases[i].Chart.Version = strings.TrimSpace(hw.Releases[i].Chart.Version)
+	}
+	return hw, nil
+}
+
+// RemoveTopLevelSection removes a top-level YAML section (including its indented block)
+// by name from the provided byte slice and returns the processed bytes.
+// It is a conservative line-based stripper: it finds the line that starts with the
+// section key followed by ':' and removes that line and all following lines that are
+// indented (have greater indent) until a line with indent <= sectionIndent is found.
+func RemoveTopLevelSection(input []byte, section string) []byte {
+	lines, _ := removeTopLevelSection(strings.Split(string(input), "\n"), nil, section)
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// removeTopLevelSection implements RemoveTopLevelSection on lines. origin holds the
+// original line index of each line (nil when not tracked) and is filtered alongside.
+func removeTopLevelSection(lines []string, origin []int, section string) ([]string, []int) {
+	out := make([]string, 0, len(lines))
+	var outOrigin []int
+	keep := func(i int) {
+		out = append(out, lines[i])
+		if origin != nil {
+			outOrigin = append(outOrigin, origin[i])
+		}
+	}
+
+	skip := false
+	sectionIndent := 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if !skip {
+			// detect top-level section line like "repositories:" possibly with leading/trailing spaces
+			if strings.HasPrefix(strings.TrimSpace(line), section+":") {
+				skip = true
+				sectionIndent = indent
+				// skip this line (do not append)
+				continue
+			}
+			keep(i)
+		} else {
+			// currently skipping: continue skipping while indent > sectionIndent
+			if strings.TrimSpace(line) == "" {
+				// preserve empty lines inside skipped block (still skip them)
+				continue
+			}
+			if indent > sectionIndent {
+				// still inside the section block -> skip
+				continue
+			}
+			// reached a line that is at same or less indent -> stop skipping and include this line
+			skip = false
+			keep(i)
+		}
+	}
+
+	return out, outOrigin
+}
+
+var envTemplateRe = regexp.MustCompile(`\{\{-?\s*(?:env|requiredEnv)\s+"([^"]+)"\s*-?\}\}`)
+var anyTemplateRe = regexp.MustCompile(`\{\{.*?\}\}`)
+
+// ExpandTemplateRefs resolves {{ env "X" }} / {{ requiredEnv "X" }} and $X / ${X} references.
+// Any other template expression cannot be evaluated here and is replaced by an empty string.
+// Values taken from the environment are inserted as-is and never expanded again.
+func ExpandTemplateRefs(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range anyTemplateRe.FindAllStringIndex(s, -1) {
+		b.WriteString(os.ExpandEnv(s[last:loc[0]]))
+		m := s[loc[0]:loc[1]]
+		if sub := envTemplateRe.FindStringSubmatch(m); sub != nil && sub[0] == m {
+			b.WriteString(os.Getenv(sub[1]))
+		} else {
+			debugf("ignoring unsupported template expression %s in repositories block", m)
+		}
+		last = loc[1]
+	}
+	b.WriteString(os.ExpandEnv(s[last:]))
+	return b.String()
+}
+
+// templatePlaceholder stands in for a template expression while the raw block is
+// parsed as YAML ("{{" would otherwise start a flow mapping).
+const templatePlaceholder = "__helmwave_template_%d__"
+
+// expandScalars expands template references in every scalar of n, after the YAML
+// structure is parsed, so expanded values cannot change it (e.g. a " #" in a password).
+// vals secret references are left as written.
+func expandScalars(n *yaml.Node, templates []string) {
+	if n.Kind == yaml.ScalarNode && !IsSecretRef(n.Value) {
+		v := n.Value
+		for i, t := range templates {
+			v = strings.ReplaceAll(v, fmt.Sprintf(templatePlaceholder, i), t)
+		}
+		if v = ExpandTemplateRefs(v); v != n.Value {
+			n.Value = v
+			// re-resolve the tag (a placeholder is always a string), but keep an
+			// expanded "null" or "~" a string
+			n.Tag = ""
+			if n.ShortTag() == "!!null" {
+				n.Tag = "!!str"
+			}
+		}
+	}
+	for _, c := range n.Content {
+		expandScalars(c, templates)
+	}
+}
+
+// restoreTemplates puts the template expressions that Parse replaced by placeholders back
+// into the scalars of n.
+func restoreTemplates(n *yaml.Node, templates []string) {
+	if n.Kind == yaml.ScalarNode && len(templates) > 0 {
+		v := n.Value
+		for i, t := range templates {
+			v = strings.ReplaceAll(v, fmt.Sprintf(templatePlaceholder, i), t)
+		}
+		n.Value = v
+	}
+	for _, c := range n.Content {
+		restoreTemplates(c, templates)
+	}
+}
+
+// TopLevelSection returns the lines of a top-level YAML section (without the key line itself).
+func TopLevelSection(input []byte, section string) string {
+	lines := strings.Split(string(input), "\n")
+	var out []string
+	inSection := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if !inSection {
+			if indent == 0 && strings.HasPrefix(trimmed, section+":") {
+				inSection = true
+			}
+			continue
+		}
+		if trimmed != "" && indent == 0 && !strings.HasPrefix(trimmed, "-") && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// ParseRepositories extracts repository entries (url and credentials) from the raw
+// helmwave file. Keys follow helm's repositories.yaml, with lowercase variants accepted.
+func ParseRepositories(data []byte) ([]*repo.Entry, error) {
+	block := TopLevelSection(data, "repositories")
+	if strings.TrimSpace(block) == "" {
+		return nil, nil
+	}
+
+	var templates []string
+	block = anyTemplateRe.ReplaceAllStringFunc(block, func(m string) string {
+		templates = append(templates, m)
+		return fmt.Sprintf(templatePlaceholder, len(templates)-1)
+	})
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(block), &doc); err != nil {
+		return nil, err
+	}
+	expandScalars(&doc, templates)
+	var raw []Repository
+	if err := doc.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*repo.Entry, 0, len(raw))
+	for _, r := range raw {
+		if r.Name == "" || r.URL == "" {
+			continue
+		}
+		if IsSecretRef(r.URL) {
+			debugf("repository %s: url is a vals secret reference; skipped", r.Name)
+			continue
+		}
+		// vals resolves these at deploy time; sending the reference itself would only fail auth
+		if IsSecretRef(r.Username) || IsSecretRef(r.Password) {
+			debugf("repository %s: credentials are vals secret references; querying without them", r.Name)
+			r.Username, r.Password = "", ""
+		}
+		entries = append(entries, r.Entry())
+	}
+	return entries, nil
+}
+
+// HasTag reports whether tags contain want (case-insensitive).
+func HasTag(tags []string, want string) bool {
+	want = strings.TrimSpace(want)
+	for _, t := range tags {
+		if strings.EqualFold(strings.TrimSpace(t), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstNonEmpty returns the first non-empty (after trimming) value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
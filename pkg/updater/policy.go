@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// Update strategies limit how far an update may move from the current version.
+const (
+	StrategyLatest    = "latest"
+	StrategySameMajor = "same-major"
+	StrategySameMinor = "same-minor"
+)
+
+// Strategies lists the known update strategies.
+var Strategies = []string{StrategyLatest, StrategySameMajor, StrategySameMinor}
+
+// Policy restricts the versions eligible for an update.
+type Policy struct {
+	// Strategy is one of Strategies; empty means latest
+	Strategy string
+	// MinAge skips versions published less than this long ago; versions without a
+	// publish date are eligible
+	MinAge time.Duration
+	// Prereleases keeps prereleases eligible even when the current version is stable
+	Prereleases bool
+}
+
+// Validate checks the strategy name.
+func (p Policy) Validate() error {
+	switch p.Strategy {
+	case "", StrategyLatest, StrategySameMajor, StrategySameMinor:
+		return nil
+	}
+	return fmt.Errorf("unknown strategy %q (expected %s)", p.Strategy, strings.Join(Strategies, ", "))
+}
+
+func (p Policy) String() string {
+	var parts []string
+	if p.Strategy != "" {
+		parts = append(parts, "strategy "+p.Strategy)
+	}
+	if p.MinAge > 0 {
+		parts = append(parts, "minAge "+p.MinAge.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Eligible returns the versions not older than current that p allows at time now.
+// Prereleases are skipped unless current is one or p.Prereleases is set. When current is not a semver version,
+// only MinAge applies.
+func (p Policy) Eligible(current string, versions []PublishedVersion, now time.Time) []PublishedVersion {
+	cur, curErr := semver.NewVersion(NormalizeSemVer(current))
+	var out []PublishedVersion
+	for _, pv := range versions {
+		if p.MinAge > 0 && !pv.Created.IsZero() && now.Sub(pv.Created) < p.MinAge {
+			continue
+		}
+		if curErr == nil {
+			v, err := semver.NewVersion(NormalizeSemVer(pv.Version))
+			if err != nil || v.LessThan(cur) || (v.Prerelease() != "" && cur.Prerelease() == "" && !p.Prereleases) {
+				continue
+			}
+			if p.Strategy == StrategySameMajor && v.Major() != cur.Major() {
+				continue
+			}
+			if p.Strategy == StrategySameMinor && (v.Major() != cur.Major() || v.Minor() != cur.Minor()) {
+				continue
+			}
+		}
+		out = append(out, pv)
+	}
+	return out
+}
+
+// Newest returns the highest semver version of versions.
+func Newest(versions []PublishedVersion) (PublishedVersion, bool) {
+	var best PublishedVersion
+	var bestVersion *semver.Version
+	for _, pv := range versions {
+		v, err := semver.NewVersion(NormalizeSemVer(pv.Version))
+		if err != nil {
+			continue
+		}
+		if bestVersion == nil || v.GreaterThan(bestVersion) {
+			best, bestVersion = pv, v
+		}
+	}
+	return best, bestVersion != nil
+}
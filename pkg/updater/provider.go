@@ -0,0 +1,155 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// VersionProvider finds the latest published chart version for a release.
+type VersionProvider interface {
+	// Resolve returns the latest version; appVersions are filled in when they are cheap to get.
+	Resolve(ctx context.Context, release Release) (Resolution, error)
+}
+
+// AppVersionResolver is implemented by providers whose appVersion lookup is expensive
+// (e.g. pulling OCI charts); it is only called when an update was found.
+type AppVersionResolver interface {
+	AppVersions(ctx context.Context, release Release, latestVersion string) (current, latest string, err error)
+}
+
+// ProviderConfig selects and configures a provider for one release.
+// Type names a registered provider; all other keys are provider options.
+type ProviderConfig struct {
+	Type    string            `yaml:"type"`
+	Options map[string]string `yaml:",inline"`
+}
+
+// Option returns a trimmed option value.
+func (c ProviderConfig) Option(key string) string {
+	return strings.TrimSpace(c.Options[key])
+}
+
+// ProviderFactory builds a provider from its configuration.
+type ProviderFactory func(cfg ProviderConfig) (VersionProvider, error)
+
+var providers = struct {
+	sync.RWMutex
+	m map[string]ProviderFactory
+}{m: make(map[string]ProviderFactory)}
+
+// RegisterProvider makes a provider type available to NewProvider. Registering the same
+// type twice replaces the earlier factory.
+func RegisterProvider(kind string, factory ProviderFactory) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.m[strings.ToLower(kind)] = factory
+}
+
+// NewProvider builds the provider registered for cfg.Type.
+func NewProvider(cfg ProviderConfig) (VersionProvider, error) {
+	providers.RLock()
+	factory, ok := providers.m[strings.ToLower(cfg.Type)]
+	providers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown version provider %q (known: %s)", cfg.Type, strings.Join(ProviderTypes(), ", "))
+	}
+	return factory(cfg)
+}
+
+// ProviderTypes lists the registered provider types.
+func ProviderTypes() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	kinds := make([]string, 0, len(providers.m))
+	for k := range providers.m {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ChartMissingError reports a chart that its repository index doesn't (or no longer) list.
+type ChartMissingError struct {
+	Repo  string
+	Chart string
+}
+
+func (e *ChartMissingError) Error() string {
+	return fmt.Sprintf("no entries for chart %q in repo %q", e.Chart, e.Repo)
+}
+
+// Unwrap makes the error match ErrChartNotFound.
+func (e *ChartMissingError) Unwrap() error { return ErrChartNotFound }
+
+// IndexMissingError reports a repository without a loaded index.
+type IndexMissingError struct {
+	Repo string
+}
+
+func (e *IndexMissingError) Error() string {
+	return fmt.Sprintf("no index for repo %q", e.Repo)
+}
+
+// Unwrap makes the error match ErrNoIndex.
+func (e *IndexMissingError) Unwrap() error { return ErrNoIndex }
+
+// SplitRepoChart splits a "repo/chart" chart name. As in helm, the repository may also be
+// referenced by alias, "@repo/chart" or "alias:repo/chart". ok is false for other forms
+// (URLs, oci:// references, local paths).
+func SplitRepoChart(name string) (repoName, chartName string, ok bool) {
+	name = strings.TrimSpace(name)
+	if IsLocalChart(name) {
+		return "", "", false
+	}
+	if rest, found := strings.CutPrefix(name, "@"); found {
+		name = rest
+	} else if rest, found := strings.CutPrefix(name, "alias:"); found {
+		name = rest
+	}
+	repoName, chartName, ok = strings.Cut(name, "/")
+	if !ok || repoName == "" || chartName == "" || strings.Contains(repoName, ":") {
+		return "", "", false
+	}
+	return repoName, chartName, true
+}
+
+// IndexProvider resolves "repo/chart" names against loaded helm repository indexes.
+type IndexProvider struct {
+	Indexes map[string]*repo.IndexFile
+	// lookup is built by NewIndexProvider; without it every Resolve walks the index entries
+	lookup ChartLookup
+}
+
+// NewIndexProvider returns an IndexProvider with the chart lookup of indexes precomputed,
+// for runs that resolve many releases against the same indexes.
+func NewIndexProvider(indexes map[string]*repo.IndexFile) IndexProvider {
+	return IndexProvider{Indexes: indexes, lookup: NewChartLookup(indexes)}
+}
+
+// Resolve implements VersionProvider.
+func (p IndexProvider) Resolve(_ context.Context, release Release) (Resolution, error) {
+	repoName, chartName, ok := SplitRepoChart(release.Chart.Name)
+	if !ok {
+		return Resolution{}, fmt.Errorf("unexpected chart.name format %q", release.Chart.Name)
+	}
+	idx, ok := p.Indexes[repoName]
+	if !ok || idx == nil {
+		return Resolution{}, &IndexMissingError{Repo: repoName}
+	}
+	if p.lookup != nil {
+		if res, ok := p.lookup.Resolve(repoName, chartName, release.Chart.Version); ok {
+			return res, nil
+		}
+		return Resolution{}, &ChartMissingError{Repo: repoName, Chart: chartName}
+	}
+	res, err := Resolve(idx, chartName, release.Chart.Version)
+	if err != nil {
+		return Resolution{}, &ChartMissingError{Repo: repoName, Chart: chartName}
+	}
+	return res, nil
+}
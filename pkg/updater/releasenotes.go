@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// ReleaseNote is the condensed release note of one application version.
+type ReleaseNote struct {
+	Version string
+	URL     string
+	// Summary is the first lines of the notes (see CondenseNotes)
+	Summary string
+}
+
+// Sources returns the home and source URLs of an index entry.
+func Sources(cv *repo.ChartVersion) []string {
+	if cv == nil || cv.Metadata == nil {
+		return nil
+	}
+	var out []string
+	for _, u := range append([]string{cv.Home}, cv.Sources...) {
+		if u = strings.TrimSpace(u); u != "" && !slices.Contains(out, u) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// SourcesOf returns the sources of version in versions, nil when it is not listed.
+func SourcesOf(versions []PublishedVersion, version string) []string {
+	v, _ := FindVersion(versions, version)
+	return v.Sources
+}
+
+// GitHubProject returns the first GitHub repository (owner/name) in urls that holds the
+// application rather than the chart. Chart repositories, whose name contains "chart", and
+// Bitnami's image repository are skipped: their releases do not follow the appVersion.
+func GitHubProject(urls []string) (string, bool) {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || !strings.EqualFold(u.Host, "github.com") && !strings.EqualFold(u.Host, "www.github.com") {
+			continue
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		owner, name := parts[0], strings.TrimSuffix(parts[1], ".git")
+		if strings.Contains(strings.ToLower(name), "chart") || strings.EqualFold(owner+"/"+name, "bitnami/containers") {
+			continue
+		}
+		return owner + "/" + name, true
+	}
+	return "", false
+}
+
+// noteVersion finds the version in a release tag such as "v1.2.3", "nginx-1.2.3" or "release-1.2".
+var noteVersion = regexp.MustCompile(`\d+(?:\.\d+)*(?:[-+][0-9A-Za-z.-]+)?$`)
+
+// NotesBetween returns the notes of the versions after current up to and including latest,
+// oldest first. Notes whose tag holds no semver-like version are dropped, and so are
+// prereleases unless latest is one.
+func NotesBetween(notes []ReleaseNote, current, latest string) []ReleaseNote {
+	from, err1 := semver.NewVersion(NormalizeSemVer(current))
+	to, err2 := semver.NewVersion(NormalizeSemVer(latest))
+	if err1 != nil || err2 != nil || !to.GreaterThan(from) {
+		return nil
+	}
+	type versioned struct {
+		v    *semver.Version
+		note ReleaseNote
+	}
+	var between []versioned
+	for _, n := range notes {
+		v, err := semver.NewVersion(NormalizeSemVer(noteVersion.FindString(n.Version)))
+		if err != nil || !v.GreaterThan(from) || v.GreaterThan(to) || (v.Prerelease() != "" && to.Prerelease() == "") {
+			continue
+		}
+		between = append(between, versioned{v, n})
+	}
+	slices.SortStableFunc(between, func(a, b versioned) int { return a.v.Compare(b.v) })
+	out := make([]ReleaseNote, 0, len(between))
+	for _, b := range between {
+		out = append(out, b.note)
+	}
+	return out
+}
+
+// noteNoise matches release note lines not worth a line of summary: HTML comments and
+// markup, horizontal rules and "full changelog" links.
+var noteNoise = regexp.MustCompile(`(?i)^(?:<!--.*-->|<[^>]+>|[-*_]{3,}|\**full changelog\**:?.*)$`)
+
+// CondenseNotes returns the first maxLines meaningful lines of release notes, with markdown
+// headings and list markers kept so the summary still reads as a list. An ellipsis marks
+// cut notes.
+func CondenseNotes(body string, maxLines int) string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || noteNoise.MatchString(line) {
+			continue
+		}
+		if len(lines) == maxLines {
+			lines = append(lines, "…")
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,25 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// movedToRe finds the new home a deprecation notice points to, as in
+// "DEPRECATED: this chart has moved to bitnami/redis" or "use https://charts.bitnami.com/bitnami".
+var movedToRe = regexp.MustCompile("(?i)(?:moved to|migrated to|replaced by|superseded by|available (?:at|in|from)|use)\\s+(?:the\\s+)?[\"'`]?((?:https?|oci)://[^\\s\"'`,;)]+|[a-z0-9][\\w.-]*/[a-z0-9][\\w.-]*)")
+
+// MovedTo returns the repo/chart or URL a deprecated chart version points to in its
+// description, or "" when it doesn't name one.
+func MovedTo(cv *repo.ChartVersion) string {
+	if cv == nil || cv.Metadata == nil {
+		return ""
+	}
+	m := movedToRe.FindStringSubmatch(cv.Description)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimRight(m[1], ".")
+}
@@ -0,0 +1,244 @@
+package updater
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Status is the outcome of checking a single release.
+type Status string
+
+const (
+	StatusUpdated  Status = "updated"
+	StatusUpToDate Status = "up-to-date"
+	StatusSkipped  Status = "skipped"
+	// StatusFailed marks releases that could not be resolved (missing index, registry errors)
+	StatusFailed Status = "failed"
+)
+
+// Reason codes classify skipped and failed releases in reports.
+const (
+	ReasonNoupdate     = "noupdate"
+	ReasonLocalChart   = "local-chart"
+	ReasonOffline      = "offline"
+	ReasonNoVersion    = "no-version"
+	ReasonConstraint   = "constraint"
+	ReasonCooldown     = "cooldown"
+	ReasonStep         = "step-unsupported"
+	ReasonLimit        = "limit"
+	ReasonPolicy       = "policy"
+	ReasonChannel      = "channel"
+	ReasonChartName    = "chart-name"
+	ReasonSecretRef    = "secret-ref"
+	ReasonTemplated    = "templated"
+	ReasonLicense      = "license-change"
+	ReasonPolicyDeny   = "policy-deny"
+	ReasonPolicyHold   = "policy-hold"
+	ReasonNoIndex      = "missing-index"
+	ReasonChartMissing = "chart-not-found"
+	ReasonError        = "error"
+)
+
+// FailureReason returns the reason code of a resolution error.
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoIndex):
+		return ReasonNoIndex
+	case errors.Is(err, ErrChartNotFound):
+		return ReasonChartMissing
+	}
+	return ReasonError
+}
+
+// ReleaseUpdate describes a version bump applied in memory to a single release.
+type ReleaseUpdate struct {
+	Release           string
+	Namespace         string
+	Context           string
+	Chart             string
+	FromVersion       string
+	ToVersion         string
+	CurrentAppVersion string
+	LatestAppVersion  string
+	// Importance is major, minor, patch, none or unknown (see UpdateImportance)
+	Importance string
+	Tags       []string
+	// Digest is the manifest digest of ToVersion for OCI charts pinned by digest
+	Digest string
+	// File is the Chart.yaml of a local chart for dependency updates; empty for the helmwave file
+	File string
+	// MaintainersRemoved and MaintainersAdded are set when the maintainers changed
+	// significantly between FromVersion and ToVersion (see MaintainerChange.Significant)
+	MaintainersRemoved []string
+	MaintainersAdded   []string
+	// FromLicense and ToLicense are set when ToVersion declares another license (see
+	// LicenseChanged)
+	FromLicense string
+	ToLicense   string
+	// ReleaseNotes are the notes of the application versions between CurrentAppVersion and
+	// LatestAppVersion, oldest first (-release-notes)
+	ReleaseNotes []ReleaseNote
+}
+
+// NewReleaseUpdate describes moving release to toVersion.
+func NewReleaseUpdate(release Release, toVersion, currentAppVersion, latestAppVersion string) ReleaseUpdate {
+	return ReleaseUpdate{
+		Release:           release.Name,
+		Namespace:         release.Namespace,
+		Context:           release.Context,
+		Chart:             release.Chart.Name,
+		FromVersion:       release.Chart.Version,
+		ToVersion:         toVersion,
+		CurrentAppVersion: currentAppVersion,
+		LatestAppVersion:  latestAppVersion,
+		Importance:        UpdateImportance(release.Chart.Version, toVersion, currentAppVersion, latestAppVersion),
+		Tags:              release.Tags,
+	}
+}
+
+// ID identifies the updated release for file edits; see Release.ID.
+func (u ReleaseUpdate) ID() string {
+	return ReleaseID(u.Release, u.Namespace, u.Context)
+}
+
+// ReleaseResult is the outcome for one release; Update is set only for StatusUpdated.
+type ReleaseResult struct {
+	Release   string
+	Namespace string
+	// Context is the release's kube context, used to group reports
+	Context string
+	Chart   string
+	Status  Status
+	// Code is the reason code (Reason* constants) of skipped and failed releases
+	Code   string
+	Reason string
+	Update ReleaseUpdate
+	// Version is the chart version in use and Tags the release tags
+	Version string
+	Tags    []string
+	// Latest is the newest published version, set once the release was resolved; Behind
+	// counts the versions published after Version and DaysBehind the days between their
+	// publish dates, when the source records them
+	Latest            string
+	CurrentAppVersion string
+	LatestAppVersion  string
+	Behind            int
+	DaysBehind        int
+}
+
+// ID identifies the release like Release.ID.
+func (r ReleaseResult) ID() string {
+	return ReleaseID(r.Release, r.Namespace, r.Context)
+}
+
+// CheckResult collects the outcomes of a check run in release order.
+type CheckResult struct {
+	Releases []ReleaseResult
+}
+
+// Updated is the result for a release moved to u.ToVersion.
+func Updated(u ReleaseUpdate) ReleaseResult {
+	return ReleaseResult{Release: u.Release, Namespace: u.Namespace, Context: u.Context, Chart: u.Chart, Status: StatusUpdated, Update: u,
+		Version: u.FromVersion, Tags: u.Tags, Latest: u.ToVersion, CurrentAppVersion: u.CurrentAppVersion, LatestAppVersion: u.LatestAppVersion}
+}
+
+// UpToDate is the result for a release already on the latest version.
+func UpToDate(r Release) ReleaseResult {
+	return ReleaseResult{Release: r.Name, Namespace: r.Namespace, Context: r.Context, Chart: r.Chart.Name, Status: StatusUpToDate, Version: r.Chart.Version, Tags: r.Tags}
+}
+
+// Skipped is the result for a release that was intentionally not checked.
+func Skipped(r Release, code, reason string) ReleaseResult {
+	return ReleaseResult{Release: r.Name, Namespace: r.Namespace, Context: r.Context, Chart: r.Chart.Name, Status: StatusSkipped, Code: code, Reason: reason, Version: r.Chart.Version, Tags: r.Tags}
+}
+
+// Failed is the result for a release whose latest version could not be resolved.
+func Failed(r Release, code, reason string) ReleaseResult {
+	return ReleaseResult{Release: r.Name, Namespace: r.Namespace, Context: r.Context, Chart: r.Chart.Name, Status: StatusFailed, Code: code, Reason: reason, Version: r.Chart.Version, Tags: r.Tags}
+}
+
+// Updates returns the applied updates in release order.
+func (c CheckResult) Updates() []ReleaseUpdate {
+	var updates []ReleaseUpdate
+	for _, r := range c.Releases {
+		if r.Status == StatusUpdated {
+			updates = append(updates, r.Update)
+		}
+	}
+	return updates
+}
+
+// AppVersions maps release ID to the appVersion of the chart version the release is on after
+// the run: the latest appVersion of updated releases, the current one of the others.
+// Releases whose appVersion is unknown are left out.
+func (c CheckResult) AppVersions() map[string]string {
+	versions := make(map[string]string, len(c.Releases))
+	for _, r := range c.Releases {
+		v := r.CurrentAppVersion
+		if r.Status == StatusUpdated {
+			v = r.LatestAppVersion
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			versions[r.ID()] = v
+		}
+	}
+	return versions
+}
+
+// Contexts returns the kube contexts of the results in order of first appearance; releases
+// without a context option are grouped under "".
+func (c CheckResult) Contexts() []string {
+	var contexts []string
+	for _, r := range c.Releases {
+		if !slices.Contains(contexts, r.Context) {
+			contexts = append(contexts, r.Context)
+		}
+	}
+	return contexts
+}
+
+// InContext returns the results of releases targeting the kube context.
+func (c CheckResult) InContext(context string) CheckResult {
+	var out CheckResult
+	for _, r := range c.Releases {
+		if r.Context == context {
+			out.Releases = append(out.Releases, r)
+		}
+	}
+	return out
+}
+
+// ReasonGroup is the releases with one status and reason code.
+type ReasonGroup struct {
+	Code     string
+	Releases []ReleaseResult
+}
+
+// ByReason groups the releases with status by reason code, in order of first appearance.
+func (c CheckResult) ByReason(status Status) []ReasonGroup {
+	var groups []ReasonGroup
+	for _, r := range c.Releases {
+		if r.Status != status {
+			continue
+		}
+		i := slices.IndexFunc(groups, func(g ReasonGroup) bool { return g.Code == r.Code })
+		if i < 0 {
+			groups = append(groups, ReasonGroup{Code: r.Code})
+			i = len(groups) - 1
+		}
+		groups[i].Releases = append(groups[i].Releases, r)
+	}
+	return groups
+}
+
+// Count returns the number of releases with the given status.
+func (c CheckResult) Count(status Status) int {
+	n := 0
+	for _, r := range c.Releases {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// ErrChartNotFound is returned by Resolve when the index has no entries for the chart.
+var ErrChartNotFound = errors.New("chart not found in index")
+
+// ErrNoIndex is matched by errors for releases whose repository index is not loaded.
+var ErrNoIndex = errors.New("repository index not loaded")
+
+// Resolution is the latest published version of a chart relative to the version in use.
+type Resolution struct {
+	LatestVersion     string
+	CurrentAppVersion string
+	LatestAppVersion  string
+	// Deprecated is set when the index marks the latest version deprecated; MovedTo is
+	// the replacement its description points to, if any
+	Deprecated bool
+	MovedTo    string
+	// Versions lists all published versions when the source provides them (used by -step)
+	Versions []PublishedVersion
+}
+
+// Resolve finds the latest version of chartName in idx (entries are sorted newest first
+// by helm) and the appVersions of currentVersion and of the latest version.
+func Resolve(idx *repo.IndexFile, chartName, currentVersion string) (Resolution, error) {
+	if idx == nil {
+		return Resolution{}, fmt.Errorf("%w: %s (no index)", ErrChartNotFound, chartName)
+	}
+	entries, ok := idx.Entries[chartName]
+	if !ok || len(entries) == 0 {
+		return Resolution{}, fmt.Errorf("%w: %s", ErrChartNotFound, chartName)
+	}
+	debugf("found %d entries for %s", len(entries), chartName)
+
+	currentAppVersion, latestAppVersion := AppVersions(currentVersion, entries)
+	res := Resolution{
+		LatestVersion:     strings.TrimPrefix(entries[0].Version, "v"),
+		CurrentAppVersion: currentAppVersion,
+		LatestAppVersion:  latestAppVersion,
+	}
+	for _, e := range entries {
+		res.Versions = append(res.Versions, PublishedVersion{Version: strings.TrimPrefix(e.Version, "v"), AppVersion: strings.TrimSpace(e.AppVersion), Created: e.Created, Maintainers: Maintainers(e), License: License(e), Sources: Sources(e)})
+	}
+	if entries[0].Metadata != nil && entries[0].Deprecated {
+		res.Deprecated, res.MovedTo = true, MovedTo(entries[0])
+	}
+	return res, nil
+}
+
+// AppVersions returns the appVersion of currentChartVersion and of the newest entry.
+func AppVersions(currentChartVersion string, versions []*repo.ChartVersion) (string, string) {
+	var currentAppVersion string
+	var latestAppVersion string
+
+	for _, v := range versions {
+		if strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(currentChartVersion, "v") {
+			currentAppVersion = strings.TrimSpace(v.AppVersion)
+			break
+		}
+	}
+
+	if len(versions) > 0 {
+		latestAppVersion = strings.TrimSpace(versions[0].AppVersion)
+	}
+
+	return currentAppVersion, latestAppVersion
+}
+
+// LatestSemverTag returns the highest semver-like tag (without a leading v).
+func LatestSemverTag(tags []string) (string, bool) {
+	var selectedVersion *semver.Version
+	selectedRawTag := ""
+
+	for _, tag := range tags {
+		normalized := NormalizeSemVer(tag)
+		parsed, err := semver.NewVersion(normalized)
+		if err != nil {
+			continue
+		}
+		if selectedVersion == nil || parsed.GreaterThan(selectedVersion) {
+			selectedVersion = parsed
+			selectedRawTag = tag
+		}
+	}
+
+	if selectedVersion == nil {
+		return "", false
+	}
+
+	return strings.TrimPrefix(strings.TrimSpace(selectedRawTag), "v"), true
+}
+
+// NormalizeSemVer attempts to coerce appVersion strings into a semver-compatible form
+func NormalizeSemVer(v string) string {
+	// trim spaces and possible leading 'v'
+	vv := strings.TrimSpace(v)
+	vv = strings.TrimPrefix(vv, "v")
+	// if version looks like '1' or '1.2', pad to three segments
+	parts := strings.Split(vv, ".")
+	if len(parts) == 1 {
+		return vv + ".0.0"
+	}
+	if len(parts) == 2 {
+		return vv + ".0"
+	}
+	return vv
+}
+
+// Importance classifies the step from current to latest as major, minor, patch or none,
+// returning the normalized versions. ok is false when either side isn't semver-like.
+func Importance(current, latest string) (label, currentNormalized, latestNormalized string, ok bool) {
+	cur, err1 := semver.NewVersion(NormalizeSemVer(current))
+	lat, err2 := semver.NewVersion(NormalizeSemVer(latest))
+	if err1 != nil || err2 != nil {
+		return "", "", "", false
+	}
+
+	switch {
+	case lat.Major() > cur.Major():
+		return "major", cur.String(), lat.String(), true
+	case lat.Minor() > cur.Minor():
+		return "minor", cur.String(), lat.String(), true
+	case lat.Patch() > cur.Patch():
+		return "patch", cur.String(), lat.String(), true
+	default:
+		return "none", cur.String(), lat.String(), true
+	}
+}
+
+// UpdateImportance classifies an update by appVersion when both sides are known
+// and falls back to the chart version otherwise.
+func UpdateImportance(fromChart, toChart, currentAppVersion, latestAppVersion string) string {
+	if label, _, _, ok := Importance(currentAppVersion, latestAppVersion); ok && strings.TrimSpace(currentAppVersion) != "" {
+		return label
+	}
+	if label, _, _, ok := Importance(fromChart, toChart); ok {
+		return label
+	}
+	return "unknown"
+}
@@ -0,0 +1,14 @@
+package updater
+
+import "regexp"
+
+// secretRefRe matches vals secret references such as "ref+vault://secret/app#/token" or
+// "secretref+awssecrets://db". vals resolves them when the file is deployed.
+var secretRefRe = regexp.MustCompile(`^(?:secret)?ref\+[a-z0-9]+://`)
+
+// IsSecretRef reports whether the scalar s is a vals secret reference. These values are
+// opaque here: they are never expanded, compared as versions or rewritten. A '#' inside
+// one ("#/key" selects a field) does not start a comment.
+func IsSecretRef(s string) bool {
+	return secretRefRe.MatchString(s)
+}
@@ -0,0 +1,50 @@
+package updater
+
+import (
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// Staleness measures how far current is behind latest: the number of published versions
+// newer than current (up to latest), and the days between their publish dates when both
+// are recorded. A constraint in current stands for the newest version it matches.
+func Staleness(current, latest string, versions []PublishedVersion) (behind, days int) {
+	if IsConstraint(current) {
+		if v, ok := ResolveConstraint(current, versions); ok {
+			current = v.Version
+		}
+	}
+	cur, err := semver.NewVersion(NormalizeSemVer(current))
+	if err != nil {
+		return 0, 0
+	}
+	top, err := semver.NewVersion(NormalizeSemVer(latest))
+	if err != nil || !top.GreaterThan(cur) {
+		return 0, 0
+	}
+	var curCreated, topCreated time.Time
+	for _, pv := range versions {
+		v, err := semver.NewVersion(NormalizeSemVer(pv.Version))
+		if err != nil {
+			continue
+		}
+		switch {
+		case v.Equal(cur):
+			curCreated = pv.Created
+		case v.Equal(top):
+			topCreated = pv.Created
+		}
+		if v.GreaterThan(cur) && !v.GreaterThan(top) && (v.Prerelease() == "" || top.Prerelease() != "") {
+			behind++
+		}
+	}
+	if len(versions) == 0 {
+		// sources that only report the latest version are one version behind at least
+		behind = 1
+	}
+	if !curCreated.IsZero() && !topCreated.IsZero() && topCreated.After(curCreated) {
+		days = int(topCreated.Sub(curCreated).Hours() / 24)
+	}
+	return behind, days
+}
@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"strings"
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// Step modes for StepVersion.
+const (
+	// StepNext proposes the next published version.
+	StepNext = "next"
+	// StepMinor proposes the newest patch of the next minor line.
+	StepMinor = "minor"
+)
+
+// PublishedVersion is one version listed by a source, with its appVersion when known.
+type PublishedVersion struct {
+	Version    string
+	AppVersion string
+	// Created is the publish date when the source records one
+	Created time.Time
+	// Maintainers are the chart's maintainers in this version when the source records
+	// them (see Maintainers)
+	Maintainers []string
+	// License is the license the version declares (see License)
+	License string
+	// Sources are the chart's home and source URLs (see Sources)
+	Sources []string
+}
+
+// Published wraps plain version strings (e.g. tags) without appVersions.
+func Published(versions []string) []PublishedVersion {
+	out := make([]PublishedVersion, 0, len(versions))
+	for _, v := range versions {
+		out = append(out, PublishedVersion{Version: strings.TrimPrefix(strings.TrimSpace(v), "v")})
+	}
+	return out
+}
+
+// FindVersion returns the entry of version in versions; a leading v is ignored.
+func FindVersion(versions []PublishedVersion, version string) (PublishedVersion, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for _, v := range versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return PublishedVersion{}, false
+}
+
+// StepVersion returns the version one step above current instead of the latest: the next
+// published version (StepNext), or the newest patch of the next minor line (StepMinor),
+// which is the current line while it has newer patches. Prereleases are skipped unless
+// current is one or prereleases is set. ok is false when no newer semver version is published.
+func StepVersion(current string, versions []PublishedVersion, mode string, prereleases bool) (PublishedVersion, bool) {
+	cur, err := semver.NewVersion(NormalizeSemVer(current))
+	if err != nil {
+		return PublishedVersion{}, false
+	}
+	type candidate struct {
+		v   *semver.Version
+		pub PublishedVersion
+	}
+	var newer []candidate
+	for _, pv := range versions {
+		v, err := semver.NewVersion(NormalizeSemVer(pv.Version))
+		if err != nil || !v.GreaterThan(cur) || (v.Prerelease() != "" && cur.Prerelease() == "" && !prereleases) {
+			continue
+		}
+		newer = append(newer, candidate{v, pv})
+	}
+	if len(newer) == 0 {
+		return PublishedVersion{}, false
+	}
+
+	next := newer[0]
+	for _, c := range newer[1:] {
+		if c.v.LessThan(next.v) {
+			next = c
+		}
+	}
+	if mode != StepMinor {
+		return next.pub, true
+	}
+	// the next line is the current one while it has newer patches
+	best := next
+	for _, c := range newer {
+		if c.v.Major() == next.v.Major() && c.v.Minor() == next.v.Minor() && c.v.GreaterThan(best.v) {
+			best = c
+		}
+	}
+	return best.pub, true
+}
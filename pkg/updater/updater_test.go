@@ -0,0 +1,1239 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+func TestLatestSemverTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "selects highest semver with v-prefix",
+			tags:   []string{"v0.9.0", "v1.2.3", "v1.10.0"},
+			want:   "1.10.0",
+			wantOK: true,
+		},
+		{
+			name:   "ignores non-semver tags",
+			tags:   []string{"latest", "main", "1.2.0"},
+			want:   "1.2.0",
+			wantOK: true,
+		},
+		{
+			name:   "returns false when no semver tags",
+			tags:   []string{"latest", "dev", "main"},
+			want:   "",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LatestSemverTag(tt.tags)
+			if ok != tt.wantOK {
+				t.Fatalf("LatestSemverTag() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Fatalf("LatestSemverTag() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRepositories(t *testing.T) {
+	t.Setenv("PRIVATE_REPO_USER", "robot")
+	t.Setenv("PRIVATE_REPO_PASSWORD", "s3cret")
+
+	data, err := os.ReadFile("../../helmwave.yml.tpl")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	entries, err := ParseRepositories(data)
+	if err != nil {
+		t.Fatalf("ParseRepositories() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ParseRepositories() got %d entries, want 2", len(entries))
+	}
+	private := entries[1]
+	if private.Name != "private" || private.Username != "robot" || private.Password != "s3cret" {
+		t.Fatalf("unexpected private repo entry: %+v", private)
+	}
+}
+
+func TestParseRepositoriesKeepsExpandedValues(t *testing.T) {
+	t.Setenv("REPO_PASSWORD", "pa$word #1: x")
+	t.Setenv("REPO_HOST", "charts.example.com")
+	t.Setenv("REPO_INSECURE", "true")
+
+	data := []byte(`repositories:
+  - name: private
+    url: https://${REPO_HOST}/stable
+    username: robot
+    password: {{ env "REPO_PASSWORD" }}
+    insecure_skip_tls_verify: {{ env "REPO_INSECURE" }}
+  - name: quoted
+    url: "https://$REPO_HOST/{{ env "REPO_PASSWORD" }}"
+releases: []
+`)
+	entries, err := ParseRepositories(data)
+	if err != nil {
+		t.Fatalf("ParseRepositories() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ParseRepositories() got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.URL != "https://charts.example.com/stable" || e.Password != "pa$word #1: x" || !e.InsecureSkipTLSVerify {
+		t.Fatalf("unexpected private repo entry: %+v", e)
+	}
+	if got, want := entries[1].URL, "https://charts.example.com/pa$word #1: x"; got != want {
+		t.Fatalf("quoted url = %q, want %q", got, want)
+	}
+}
+
+func TestGitChartWithRef(t *testing.T) {
+	g, ok := ParseGitChart("git+ssh://git@github.com/org/charts@stable/foo?ref=v1.0.0&sparse=0")
+	if !ok {
+		t.Fatal("ParseGitChart failed")
+	}
+	if g.Repo != "ssh://git@github.com/org/charts" || g.Path != "stable/foo" || g.Ref != "v1.0.0" {
+		t.Fatalf("unexpected parse result: %+v", g)
+	}
+	if got, want := g.WithRef("v1.1.0"), "git+ssh://git@github.com/org/charts@stable/foo?ref=v1.1.0&sparse=0"; got != want {
+		t.Fatalf("WithRef() = %q, want %q", got, want)
+	}
+	for _, ref := range []string{"release/1.2", "feature/a b", "v1.0.0+build.1", "x&y#z"} {
+		name := g.WithRef(ref)
+		if ref == "release/1.2" && !strings.Contains(name, "?ref=release/1.2&") {
+			t.Errorf("WithRef(%q) = %q, want the ref kept as written", ref, name)
+		}
+		if parsed, _ := ParseGitChart(name); parsed.Ref != ref || parsed.Path != "stable/foo" {
+			t.Errorf("ParseGitChart(WithRef(%q)) = %+v", ref, parsed)
+		}
+	}
+	if _, ok := ParseGitChart("bitnami/nginx"); ok {
+		t.Fatal("ParseGitChart accepted a repo chart")
+	}
+}
+
+func TestSplitOCIDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	for _, tt := range []struct{ name, ref, digest string }{
+		{"oci://ghcr.io/org/chart@" + digest, "oci://ghcr.io/org/chart", digest},
+		{"oci://ghcr.io/org/chart", "oci://ghcr.io/org/chart", ""},
+		{"oci://user@registry.example.com/chart", "oci://user@registry.example.com/chart", ""},
+		{"bitnami/nginx", "bitnami/nginx", ""},
+	} {
+		ref, d := SplitOCIDigest(tt.name)
+		if ref != tt.ref || d != tt.digest {
+			t.Errorf("SplitOCIDigest(%q) = %q, %q; want %q, %q", tt.name, ref, d, tt.ref, tt.digest)
+		}
+	}
+	if got, want := WithOCIDigest("oci://ghcr.io/org/chart@sha256:old", digest), "oci://ghcr.io/org/chart@"+digest; got != want {
+		t.Errorf("WithOCIDigest() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitRepoChart(t *testing.T) {
+	for _, tt := range []struct {
+		name, repo, chart string
+		ok                bool
+	}{
+		{"bitnami/nginx", "bitnami", "nginx", true},
+		{"@bitnami/nginx", "bitnami", "nginx", true},
+		{"alias:bitnami/nginx", "bitnami", "nginx", true},
+		{"oci://ghcr.io/org/chart", "", "", false},
+		{"https://charts.example.com/nginx-1.0.0.tgz", "", "", false},
+		{"nginx", "", "", false},
+		{"@/nginx", "", "", false},
+	} {
+		repoName, chartName, ok := SplitRepoChart(tt.name)
+		if repoName != tt.repo || chartName != tt.chart || ok != tt.ok {
+			t.Errorf("SplitRepoChart(%q) = %q, %q, %v; want %q, %q, %v", tt.name, repoName, chartName, ok, tt.repo, tt.chart, tt.ok)
+		}
+	}
+
+	idx := repo.NewIndexFile()
+	idx.Entries["nginx"] = repo.ChartVersions{{Metadata: &chart.Metadata{Name: "nginx", Version: "15.4.0"}}}
+	res, err := IndexProvider{Indexes: map[string]*repo.IndexFile{"bitnami": idx}}.Resolve(context.Background(), Release{Chart: Chart{Name: "@bitnami/nginx", Version: "15.3.0"}})
+	if err != nil || res.LatestVersion != "15.4.0" {
+		t.Errorf("IndexProvider.Resolve(@bitnami/nginx) = %+v, %v; want 15.4.0", res, err)
+	}
+}
+
+func TestDetectFeatures(t *testing.T) {
+	data := []byte(`registries:
+  - host: ghcr.io
+releases:
+  - name: a
+    chart:
+      name: oci://ghcr.io/org/a
+    lifecycle:
+      pre_up:
+        - cmd: echo
+`)
+	hw, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := DetectFeatures(data, &hw)
+	want := []Feature{FeatureRegistries, FeatureOCICharts, FeatureLifecycle}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectFeatures() = %v, want %v", got, want)
+	}
+	if got := DetectFeatures([]byte("releases: []\n"), &Helmwave{}); len(got) != 0 {
+		t.Errorf("DetectFeatures() on a plain file = %v, want none", got)
+	}
+}
+
+func TestUpdateTopLevelScalar(t *testing.T) {
+	in := "version: \"0.36.0\" # helmwave\nreleases:\n  - name: a\n    version: 0.36.0\n"
+	want := "version: \"0.41.1\" # helmwave\nreleases:\n  - name: a\n    version: 0.36.0\n"
+	if got := UpdateTopLevelScalar([]byte(in), "version", "0.41.1"); got != want {
+		t.Errorf("UpdateTopLevelScalar() = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateDependencyVersions(t *testing.T) {
+	in := `apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+  - name: redis
+    version: "18.1.0" # cache
+    repository: "@bitnami"
+  - version: 12.0.0
+    name: postgresql
+    repository: https://charts.bitnami.com/bitnami
+  - name: common
+    version: 2.0.0
+    repository: file://../common
+annotations:
+  version: 1.0.0
+`
+	want := strings.NewReplacer(`version: "18.1.0" # cache`, `version: "18.2.0" # cache`, "version: 12.0.0", "version: 12.1.0").Replace(in)
+	got := UpdateDependencyVersions([]byte(in), map[string]string{"redis": "18.2.0", "postgresql": "12.1.0", "version": "9.9.9"})
+	if got != want {
+		t.Fatalf("UpdateDependencyVersions() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMovedTo(t *testing.T) {
+	for _, tt := range []struct{ description, want string }{
+		{"DEPRECATED - This chart has moved to bitnami/redis.", "bitnami/redis"},
+		{"Deprecated: please use https://charts.bitnami.com/bitnami instead", "https://charts.bitnami.com/bitnami"},
+		{"This chart is deprecated; use the 'oci://ghcr.io/org/charts/app' chart", "oci://ghcr.io/org/charts/app"},
+		{"DEPRECATED Chart for Redis", ""},
+	} {
+		cv := &repo.ChartVersion{Metadata: &chart.Metadata{Description: tt.description, Deprecated: true}}
+		if got := MovedTo(cv); got != tt.want {
+			t.Errorf("MovedTo(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestCompareMaintainers(t *testing.T) {
+	cv := &repo.ChartVersion{Metadata: &chart.Metadata{Maintainers: []*chart.Maintainer{
+		{Name: "Alice", Email: "alice@example.com"}, {Name: "bob"}, nil, {Email: "ops@example.com"},
+	}}}
+	current := Maintainers(cv)
+	if want := []string{"Alice <alice@example.com>", "bob", "ops@example.com"}; !slices.Equal(current, want) {
+		t.Fatalf("Maintainers() = %q, want %q", current, want)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		candidate      []string
+		removed, added []string
+		significant    bool
+	}{
+		{"same, renamed", []string{"Alice Smith <ALICE@example.com>", "bob", "ops@example.com"}, nil, nil, false},
+		{"one of three left", []string{"Alice <alice@example.com>", "bob", "carol"}, []string{"ops@example.com"}, []string{"carol"}, false},
+		{"handed over", []string{"mallory <m@example.net>", "bob"}, []string{"Alice <alice@example.com>", "ops@example.com"}, []string{"mallory <m@example.net>"}, true},
+		{"not recorded", nil, nil, nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CompareMaintainers(current, tt.candidate)
+			if !slices.Equal(c.Removed, tt.removed) || !slices.Equal(c.Added, tt.added) || c.Significant() != tt.significant {
+				t.Errorf("CompareMaintainers() = %+v (significant %v)", c, c.Significant())
+			}
+		})
+	}
+
+	versions := []PublishedVersion{{Version: "2.0.0", Maintainers: []string{"carol"}}, {Version: "1.0.0", Maintainers: current}}
+	if got := MaintainersOf(versions, "v1.0.0"); !slices.Equal(got, current) {
+		t.Errorf("MaintainersOf(v1.0.0) = %q", got)
+	}
+}
+
+func TestLicense(t *testing.T) {
+	for _, tt := range []struct {
+		annotations map[string]string
+		want        string
+	}{
+		{map[string]string{"licenses": "Apache-2.0"}, "Apache-2.0"},
+		{map[string]string{"licenses": "MIT", "artifacthub.io/license": " BUSL-1.1 "}, "BUSL-1.1"},
+		{nil, ""},
+	} {
+		if got := License(&repo.ChartVersion{Metadata: &chart.Metadata{Annotations: tt.annotations}}); got != tt.want {
+			t.Errorf("License(%v) = %q, want %q", tt.annotations, got, tt.want)
+		}
+	}
+	if !LicenseChanged("Apache-2.0", "BUSL-1.1") || LicenseChanged("Apache-2.0", "apache-2.0") || LicenseChanged("", "BUSL-1.1") {
+		t.Error("LicenseChanged() must ignore case and undeclared licenses")
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	cv := &repo.ChartVersion{Metadata: &chart.Metadata{Home: "https://bitnami.com", Sources: []string{
+		"https://github.com/bitnami/charts/tree/main/bitnami/nginx", "https://github.com/bitnami/containers", "https://github.com/nginx/nginx.git",
+	}}}
+	if got, ok := GitHubProject(Sources(cv)); !ok || got != "nginx/nginx" {
+		t.Errorf("GitHubProject() = %q, %v", got, ok)
+	}
+	if _, ok := GitHubProject([]string{"https://gitlab.com/a/b", "https://github.com/org/helm-charts"}); ok {
+		t.Error("GitHubProject() picked a chart repository")
+	}
+
+	notes := []ReleaseNote{{Version: "release-1.27.0"}, {Version: "release-1.26.1"}, {Version: "release-1.26.0-rc.1"}, {Version: "release-1.26.0"}, {Version: "release-1.25.3"}, {Version: "nightly"}}
+	var got []string
+	for _, n := range NotesBetween(notes, "1.25.3", "1.26.1") {
+		got = append(got, n.Version)
+	}
+	if want := []string{"release-1.26.0", "release-1.26.1"}; !slices.Equal(got, want) {
+		t.Errorf("NotesBetween() = %v, want %v", got, want)
+	}
+	if n := NotesBetween(notes, "1.26.1", "1.25.3"); n != nil {
+		t.Errorf("NotesBetween() of a downgrade = %v", n)
+	}
+
+	body := "<!-- Release notes generated -->\n## What's Changed\n\n* fix a\n* fix b\n* fix c\n* fix d\n---\n**Full Changelog**: https://github.com/o/a/compare/v1...v2\n"
+	if got, want := CondenseNotes(body, 3), "## What's Changed\n* fix a\n* fix b\n…"; got != want {
+		t.Errorf("CondenseNotes() = %q, want %q", got, want)
+	}
+	if got := CondenseNotes("* only\n\n", 3); got != "* only" {
+		t.Errorf("CondenseNotes() = %q", got)
+	}
+}
+
+func TestUpdateTextDuplicateNames(t *testing.T) {
+	in := `releases:
+  - name: app
+    namespace: staging
+    chart:
+      name: repo/app
+      version: 1.0.0
+  - name: app
+    namespace: prod
+    context: eu
+    chart:
+      name: repo/app
+      version: 1.0.0
+`
+	hw, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hw.Releases[1].ID(); got != "app@prod@eu" {
+		t.Fatalf("ID() = %q, want app@prod@eu", got)
+	}
+	hw.Releases[1].Chart.Version = "2.0.0"
+	got := UpdateText([]byte(in), VersionMap(&hw), nil)
+	want := strings.Replace(in, "context: eu\n    chart:\n      name: repo/app\n      version: 1.0.0", "context: eu\n    chart:\n      name: repo/app\n      version: 2.0.0", 1)
+	if got != want {
+		t.Errorf("UpdateText() =\n%s\nwant\n%s", got, want)
+	}
+
+	// without the namespace in the blocks, duplicate names cannot be told apart
+	hidden := strings.NewReplacer("    namespace: staging\n", "", "    namespace: prod\n    context: eu\n", "").Replace(in)
+	if got := UpdateText([]byte(hidden), map[string]string{"app@staging": "2.0.0", "app@prod@eu": "2.0.0"}, nil); got != hidden {
+		t.Errorf("UpdateText() edited an ambiguous block:\n%s", got)
+	}
+}
+
+func TestUpdateTextAnchors(t *testing.T) {
+	in := `common: &common
+  namespace: apps
+  chart:
+    name: bitnami/nginx # web
+    version: 15.3.1
+.cache: &cache
+  chart:
+    name: bitnami/redis
+    version: "18.1.0"
+releases:
+  - name: web
+    <<: *common
+  - name: cache
+    <<: *cache
+`
+	hw, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw.Releases[0].Chart.Version = "15.4.0"
+	hw.Releases[1].Chart.Version = "18.2.0"
+	got := UpdateText([]byte(in), VersionMap(&hw), ChartVersionMap(&hw))
+	want := strings.NewReplacer("version: 15.3.1", "version: 15.4.0", `version: "18.1.0"`, `version: "18.2.0"`).Replace(in)
+	if got != want {
+		t.Errorf("UpdateText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateTextChartAliases(t *testing.T) {
+	in := `.charts:
+  nginx: &nginx
+    name: bitnami/nginx
+    version: 15.3.1
+releases:
+  - name: a
+    chart: *nginx
+  - name: b
+    namespace: b
+    chart: *nginx
+  - name: c
+    chart: &redis
+      name: bitnami/redis
+      version: 18.1.0 # pinned by CI
+  - name: d
+    chart: *redis
+`
+	hw, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hw.Releases[3].Chart.Name != "bitnami/redis" {
+		t.Fatalf("alias not resolved: %+v", hw.Releases[3].Chart)
+	}
+	versions := map[string]string{"a": "15.4.0", "b@b": "15.4.0", "c": "18.2.0", "d": "18.2.0"}
+	got := UpdateText([]byte(in), versions, nil)
+	want := strings.NewReplacer("version: 15.3.1", "version: 15.4.0", "version: 18.1.0", "version: 18.2.0").Replace(in)
+	if got != want {
+		t.Errorf("UpdateText() =\n%s\nwant\n%s", got, want)
+	}
+	if c := ChartAliasConflicts([]byte(in), versions); len(c) != 0 {
+		t.Errorf("ChartAliasConflicts() = %v, want none", c)
+	}
+
+	// d would stay behind, b moves elsewhere: neither anchor is touched
+	versions = map[string]string{"a": "15.4.0", "b@b": "15.5.0", "c": "18.2.0"}
+	if got := UpdateText([]byte(in), versions, nil); got != in {
+		t.Errorf("UpdateText() edited a disputed anchor:\n%s", got)
+	}
+	conflicts := ChartAliasConflicts([]byte(in), versions)
+	if len(conflicts) != 2 || !strings.Contains(conflicts[0], "&nginx") || !strings.Contains(conflicts[1], "releases d share it") {
+		t.Errorf("ChartAliasConflicts() = %q", conflicts)
+	}
+}
+
+func TestParseReleaseID(t *testing.T) {
+	for _, id := range []string{"app", "app@ns", "app@ns@ctx", "app@@ctx"} {
+		if got := ReleaseID(ParseReleaseID(id)); got != id {
+			t.Errorf("ReleaseID(ParseReleaseID(%q)) = %q", id, got)
+		}
+	}
+}
+
+func TestUpdateChartNames(t *testing.T) {
+	in := "releases:\n  - name: foo\n    chart:\n      name: \"git+https://example.com/r@c?ref=v1\" # pinned\n      version: 1.0.0\n"
+	want := "releases:\n  - name: foo\n    chart:\n      name: \"git+https://example.com/r@c?ref=v2\" # pinned\n      version: 1.0.0\n"
+	if got := UpdateChartNames([]byte(in), map[string]string{"foo": "git+https://example.com/r@c?ref=v2"}); got != want {
+		t.Fatalf("UpdateChartNames() =\n%s\nwant\n%s", got, want)
+	}
+	single := strings.ReplaceAll(in, `"`, "'")
+	if got, want := UpdateChartNames([]byte(single), map[string]string{"foo": "git+https://example.com/r@c?ref=v2"}), strings.ReplaceAll(want, `"`, "'"); got != want {
+		t.Fatalf("UpdateChartNames() with single quotes =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestByReason(t *testing.T) {
+	c := CheckResult{Releases: []ReleaseResult{
+		Skipped(Release{Name: "a"}, ReasonNoupdate, "noupdate tag"),
+		Failed(Release{Name: "b"}, FailureReason(&IndexMissingError{Repo: "x"}), "no index"),
+		Skipped(Release{Name: "c"}, ReasonLocalChart, "local chart"),
+		Skipped(Release{Name: "d"}, ReasonNoupdate, "noupdate tag"),
+	}}
+	groups := c.ByReason(StatusSkipped)
+	if len(groups) != 2 || groups[0].Code != ReasonNoupdate || len(groups[0].Releases) != 2 || groups[1].Code != ReasonLocalChart {
+		t.Errorf("ByReason(skipped) = %+v", groups)
+	}
+	if failed := c.ByReason(StatusFailed); len(failed) != 1 || failed[0].Code != ReasonNoIndex {
+		t.Errorf("ByReason(failed) = %+v", failed)
+	}
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/helmwave.yml"
+	data := `repositories:
+  - name: bitnami
+    url: {{ env "REPO_URL" }}
+
+releases:
+  - name: a
+    chart:
+      name: bitnami/a: x
+      version: 1.0.0
+`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := ReadFile(file)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("ReadFile() error = %v, want *ParseError", err)
+	}
+	if perr.File != file || perr.Line != 8 {
+		t.Errorf("location = %s:%d, want %s:8", perr.File, perr.Line, file)
+	}
+	if !strings.Contains(perr.Snippet, ">  8 |       name: bitnami/a: x") {
+		t.Errorf("snippet does not mark line 8:\n%s", perr.Snippet)
+	}
+
+	// type errors are located too
+	_, err = Parse([]byte("releases:\n  - name: [a, b]\n"))
+	if !errors.As(err, &perr) || perr.Line != 2 {
+		t.Errorf("Parse() type error = %v, want line 2", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := `project: demo
+repositories:
+  - name: bitnami
+    url: {{ env "URL" }}
+.options: &options
+  namespace: apps
+releases:
+  - name: web
+    <<: *options
+    chart:
+      name: bitnami/nginx
+      verison: 1.0.0
+  - name: web
+    namespace: apps
+    chart:
+      name: bitnami/nginx
+  - name: api
+    chart:
+      name: private/api
+    replicas: 2
+  - namespace: x
+    chart:
+      name: bitnami/redis
+typo: true
+`
+	problems, err := Validate([]byte(data), func(name string) bool { return name == "bitnami" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, fmt.Sprintf("%d %s %s", p.Line, p.Severity, p.Message))
+	}
+	want := []string{
+		`12 warning unknown chart key "verison"`,
+		`13 error duplicate release web@apps (first defined on line 8); set namespace or context to tell them apart`,
+		`17 error release api: repository "private" is not defined`,
+		`20 warning unknown release key "replicas"`,
+		`21 error release without a name`,
+		`24 warning unknown top-level key "typo"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStepVersion(t *testing.T) {
+	versions := Published([]string{"2.0.0", "1.4.0-rc.1", "1.3.1", "1.3.0", "1.2.5", "1.2.4", "v1.2.3"})
+	tests := []struct {
+		current, mode, want string
+		ok                  bool
+	}{
+		{"1.2.3", StepNext, "1.2.4", true},
+		{"1.2.3", StepMinor, "1.2.5", true},
+		{"1.2.5", StepMinor, "1.3.1", true},
+		{"1.3.1", StepNext, "2.0.0", true},
+		{"1.4.0-rc.0", StepNext, "1.4.0-rc.1", true},
+		{"2.0.0", StepNext, "", false},
+		{"latest", StepNext, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := StepVersion(tt.current, versions, tt.mode, false)
+		if ok != tt.ok || got.Version != tt.want {
+			t.Errorf("StepVersion(%s, %s) = %q, %v; want %q, %v", tt.current, tt.mode, got.Version, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestVersionRange(t *testing.T) {
+	tests := []struct {
+		version, latest string
+		allows          bool
+		bumped          string
+	}{
+		{"~1.25.0", "1.25.4", true, "~1.25.4"},
+		{"~1.25.0", "1.27.3", false, "~1.27.3"},
+		{"^2.3", "2.9.1", true, "^2.9"},
+		{"^2.3", "3.1.4", false, "^3.1"},
+		{">=1.0", "4.0.0", true, ">=4.0"},
+	}
+	for _, tt := range tests {
+		r, ok := ParseVersionRange(tt.version)
+		if !ok {
+			t.Fatalf("ParseVersionRange(%q) not ok", tt.version)
+		}
+		if got := r.Allows(tt.latest); got != tt.allows {
+			t.Errorf("%s.Allows(%s) = %v", tt.version, tt.latest, got)
+		}
+		if got := r.Bump(tt.latest); got != tt.bumped {
+			t.Errorf("%s.Bump(%s) = %q, want %q", tt.version, tt.latest, got, tt.bumped)
+		}
+	}
+	for _, v := range []string{"1.25.0", "1.2", ">= 1.0, < 2.0", "1.x"} {
+		if _, ok := ParseVersionRange(v); ok {
+			t.Errorf("ParseVersionRange(%q) should not be a single-operator range", v)
+		}
+	}
+	if IsConstraint("1.2") || IsConstraint("") || !IsConstraint(">= 1.0, < 2.0") || !IsConstraint("1.x") {
+		t.Error("IsConstraint misclassified a version")
+	}
+
+	data := []byte("releases:\n  - name: nginx\n    chart:\n      name: bitnami/nginx\n      version: \"~1.25.0\"\n")
+	out := UpdateText(data, map[string]string{"nginx": "~1.27.3"}, nil)
+	if !strings.Contains(out, `version: "~1.27.3"`) {
+		t.Errorf("UpdateText() = %q", out)
+	}
+}
+
+func TestResolveConstraint(t *testing.T) {
+	versions := []PublishedVersion{{Version: "2.0.0"}, {Version: "1.26.0-rc.1"}, {Version: "1.25.4", AppVersion: "1.25"}, {Version: "1.25.0"}}
+	if got, ok := ResolveConstraint("~1.25.0", versions); !ok || got.Version != "1.25.4" || got.AppVersion != "1.25" {
+		t.Errorf("ResolveConstraint(~1.25.0) = %+v, %v", got, ok)
+	}
+	if got, ok := ResolveConstraint(">= 1.0, < 2", versions); !ok || got.Version != "1.25.4" {
+		t.Errorf("ResolveConstraint(>= 1.0, < 2) = %+v, %v", got, ok)
+	}
+	if _, ok := ResolveConstraint("~3.0", versions); ok {
+		t.Error("ResolveConstraint(~3.0) should match nothing")
+	}
+}
+
+func TestPolicyEligible(t *testing.T) {
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	versions := []PublishedVersion{
+		{Version: "3.0.0", Created: now.Add(-48 * time.Hour)},
+		{Version: "2.5.0-rc.1"},
+		{Version: "2.4.0", Created: now.Add(-24 * time.Hour)},
+		{Version: "2.3.1"},
+		{Version: "2.2.0"},
+	}
+	tests := []struct {
+		policy Policy
+		want   string
+	}{
+		{Policy{}, "3.0.0"},
+		{Policy{Strategy: StrategySameMajor}, "2.4.0"},
+		{Policy{Strategy: StrategySameMinor}, "2.3.1"},
+		{Policy{Strategy: StrategySameMajor, MinAge: 36 * time.Hour}, "2.3.1"},
+		{Policy{MinAge: 72 * time.Hour}, "2.3.1"},
+	}
+	for _, tt := range tests {
+		got, ok := Newest(tt.policy.Eligible("2.3.0", versions, now))
+		if !ok || got.Version != tt.want {
+			t.Errorf("%s: newest eligible = %q, want %q", tt.policy, got.Version, tt.want)
+		}
+	}
+	if err := (Policy{Strategy: "newest"}).Validate(); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestReleaseChannel(t *testing.T) {
+	if got := ReleaseChannel([]string{"web", "Channel:RC"}); got != "rc" {
+		t.Errorf("ReleaseChannel() = %q", got)
+	}
+	if got := ReleaseChannel([]string{"web", "channel:"}); got != "" {
+		t.Errorf("ReleaseChannel(empty channel) = %q", got)
+	}
+	versions := []PublishedVersion{{Version: "2.0.0-rc.1"}, {Version: "2.0.0-beta.2"}, {Version: "1.9.0"}}
+	for channel, want := range map[string]string{ChannelStable: "1.9.0", "rc": "2.0.0-rc.1", "beta": "2.0.0-beta.2"} {
+		if got, ok := Newest(ChannelVersions(versions, channel)); !ok || got.Version != want {
+			t.Errorf("channel %s: newest = %q, want %q", channel, got.Version, want)
+		}
+	}
+}
+
+func TestFlux(t *testing.T) {
+	manifests := `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: bitnami
+spec:
+  url: https://charts.bitnami.com/bitnami
+---
+apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: podinfo
+spec:
+  type: oci
+  url: oci://ghcr.io/stefanprodan/charts
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: nginx
+  namespace: web
+  annotations:
+    helmwave-updater/tags: "frontend, channel:rc"
+spec:
+  chart:
+    spec:
+      chart: nginx
+      version: "15.0.0" # pinned
+      sourceRef:
+        kind: HelmRepository
+        name: bitnami
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.5.0
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+spec:
+  chartRef:
+    kind: OCIRepository
+    name: app
+`
+	hw, repos, err := ParseFlux([]byte(manifests))
+	if err != nil {
+		t.Fatalf("ParseFlux failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "bitnami" || repos[0].URL != "https://charts.bitnami.com/bitnami" {
+		t.Errorf("repositories = %+v", repos)
+	}
+	want := []Release{
+		{Name: "nginx", Namespace: "web", Chart: Chart{Name: "bitnami/nginx", Version: "15.0.0"}, Tags: []string{"frontend", "channel:rc"}},
+		{Name: "podinfo", Chart: Chart{Name: "oci://ghcr.io/stefanprodan/charts/podinfo", Version: "6.5.0"}},
+	}
+	if !reflect.DeepEqual(hw.Releases, want) {
+		t.Errorf("releases = %+v, want %+v", hw.Releases, want)
+	}
+
+	out, err := UpdateFluxVersions([]byte(manifests), map[string]string{"nginx@web": "15.1.0", "podinfo": "6.5.0"})
+	if err != nil {
+		t.Fatalf("UpdateFluxVersions failed: %v", err)
+	}
+	wantOut := strings.Replace(manifests, `version: "15.0.0" # pinned`, `version: "15.1.0" # pinned`, 1)
+	if out != wantOut {
+		t.Errorf("UpdateFluxVersions() =\n%s", out)
+	}
+
+	flow := strings.Replace(manifests, "    spec:\n      chart: podinfo\n      version: 6.5.0\n", "    spec: {chart: podinfo, version: 6.5.0,\n", 1)
+	flow = strings.Replace(flow, "      sourceRef:\n        kind: HelmRepository\n        name: podinfo\n", "      sourceRef: {kind: HelmRepository, name: podinfo}}\n", 1)
+	if _, err := UpdateFluxVersions([]byte(flow), map[string]string{"podinfo": "6.6.0"}); err == nil {
+		t.Error("expected error for a flow-style chart spec")
+	}
+}
+
+func TestHelmfile(t *testing.T) {
+	helmfile := `repositories:
+  - name: bitnami
+    url: https://charts.bitnami.com/bitnami
+  - name: ghcr
+    url: ghcr.io/stefanprodan/charts
+    oci: true
+---
+releases:
+  - name: nginx
+    namespace: web
+    kubeContext: prod
+    chart: bitnami/nginx
+    version: 15.0.0 # pinned
+    labels:
+      helmwave-updater/tags: channel:rc
+{{- if eq .Environment.Name "prod" }}
+  - name: podinfo
+    chart: ghcr/podinfo
+    version: '6.5.0'
+{{- end }}
+  - name: redis
+    chart: bitnami/redis
+    version: {{ .Values.redisVersion }}
+  - name: local
+    chart: ./charts/local
+`
+	hw, repos, err := ParseHelmfile([]byte(helmfile))
+	if err != nil {
+		t.Fatalf("ParseHelmfile failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "bitnami" {
+		t.Errorf("repositories = %+v", repos)
+	}
+	want := []Release{
+		{Name: "nginx", Namespace: "web", Context: "prod", Chart: Chart{Name: "bitnami/nginx", Version: "15.0.0"}, Tags: []string{"channel:rc"}},
+		{Name: "podinfo", Chart: Chart{Name: "oci://ghcr.io/stefanprodan/charts/podinfo", Version: "6.5.0"}},
+		{Name: "redis", Chart: Chart{Name: "bitnami/redis"}},
+		{Name: "local", Chart: Chart{Name: "./charts/local"}},
+	}
+	if !reflect.DeepEqual(hw.Releases, want) {
+		t.Errorf("releases = %+v, want %+v", hw.Releases, want)
+	}
+
+	out, err := UpdateHelmfileVersions([]byte(helmfile), map[string]string{"nginx@web@prod": "15.1.0", "podinfo": "6.6.0", "redis": "18.0.0"})
+	if err != nil {
+		t.Fatalf("UpdateHelmfileVersions failed: %v", err)
+	}
+	wantOut := strings.NewReplacer("version: 15.0.0 # pinned", "version: 15.1.0 # pinned", "version: '6.5.0'", "version: '6.6.0'").Replace(helmfile)
+	if out != wantOut {
+		t.Errorf("UpdateHelmfileVersions() =\n%s", out)
+	}
+}
+
+func TestArgoCD(t *testing.T) {
+	apps := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: nginx
+  namespace: argocd
+  annotations:
+    helmwave-updater/tags: noupdate
+spec:
+  destination:
+    name: prod
+  source:
+    repoURL: https://charts.bitnami.com/bitnami
+    chart: nginx
+    targetRevision: 15.0.0
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: stack
+spec:
+  sources:
+    - repoURL: https://charts.bitnami.com/bitnami
+      chart: redis
+      targetRevision: "18.0.0"
+    - repoURL: ghcr.io/stefanprodan/charts
+      chart: podinfo
+      targetRevision: 6.5.0
+    - repoURL: https://github.com/org/values.git
+      targetRevision: main
+      ref: values
+`
+	hw, repos, err := ParseArgoCD([]byte(apps))
+	if err != nil {
+		t.Fatalf("ParseArgoCD failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "charts-bitnami-com-bitnami" || repos[0].URL != "https://charts.bitnami.com/bitnami" {
+		t.Errorf("repositories = %+v", repos)
+	}
+	want := []Release{
+		{Name: "nginx", Namespace: "argocd", Context: "prod", Chart: Chart{Name: "charts-bitnami-com-bitnami/nginx", Version: "15.0.0"}, Tags: []string{"noupdate"}},
+		{Name: "stack/redis", Chart: Chart{Name: "charts-bitnami-com-bitnami/redis", Version: "18.0.0"}},
+		{Name: "stack/podinfo", Chart: Chart{Name: "oci://ghcr.io/stefanprodan/charts/podinfo", Version: "6.5.0"}},
+	}
+	if !reflect.DeepEqual(hw.Releases, want) {
+		t.Errorf("releases = %+v, want %+v", hw.Releases, want)
+	}
+
+	out, err := UpdateArgoCDVersions([]byte(apps), map[string]string{"stack/redis": "18.1.0", "stack/podinfo": "6.5.0"})
+	if err != nil {
+		t.Fatalf("UpdateArgoCDVersions failed: %v", err)
+	}
+	if wantOut := strings.Replace(apps, `targetRevision: "18.0.0"`, `targetRevision: "18.1.0"`, 1); out != wantOut {
+		t.Errorf("UpdateArgoCDVersions() =\n%s", out)
+	}
+}
+
+func TestChartLookup(t *testing.T) {
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	idx := repo.NewIndexFile()
+	idx.Entries["nginx"] = repo.ChartVersions{
+		{Metadata: &chart.Metadata{Name: "nginx", Version: "v15.4.0", AppVersion: " 1.27.0 "}, Created: created},
+		{Metadata: &chart.Metadata{Name: "nginx", Version: "15.3.0", AppVersion: "1.26.1"}},
+	}
+	idx.Entries["redis"] = repo.ChartVersions{
+		{Metadata: &chart.Metadata{Name: "redis", Version: "18.2.0", Deprecated: true, Description: "DEPRECATED: moved to valkey/valkey"}},
+	}
+	indexes := map[string]*repo.IndexFile{"bitnami": idx}
+
+	// the lookup agrees with resolving against the index directly
+	for _, tt := range []struct{ chart, version string }{{"nginx", "15.3.0"}, {"nginx", "v15.4.0"}, {"redis", "18.1.0"}} {
+		want, err := Resolve(idx, tt.chart, tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewIndexProvider(indexes).Resolve(context.Background(), Release{Chart: Chart{Name: "bitnami/" + tt.chart, Version: tt.version}})
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("lookup Resolve(%s %s) = %+v, %v; want %+v", tt.chart, tt.version, got, err, want)
+		}
+	}
+
+	lookup := NewChartLookup(indexes)
+	res, _ := lookup.Resolve("bitnami", "nginx", "15.3.0")
+	res.Versions[0].Version = "changed"
+	if again, _ := lookup.Resolve("bitnami", "nginx", "15.3.0"); again.Versions[0].Version != "15.4.0" {
+		t.Errorf("Resolve shares its Versions with the lookup: %+v", again.Versions)
+	}
+	if _, err := NewIndexProvider(indexes).Resolve(context.Background(), Release{Chart: Chart{Name: "bitnami/missing"}}); !errors.Is(err, ErrChartNotFound) {
+		t.Errorf("missing chart: err = %v", err)
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	input := `repositories:
+  - name: private
+    url: https://charts.example.com
+    username: ref+vault://secret/helm#/user
+    password: ref+envsubst://$REPO_PASSWORD
+releases:
+  - name: app
+    chart:
+      name: private/app
+      version: 1.0.0 # pinned
+    values:
+      - token: ref+vault://secret/app#/token
+  - name: vaulted
+    chart:
+      name: ref+vault://secret/charts#/name
+      version: ref+vault://secret/charts#/version
+`
+	hw, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := hw.Releases[1].Chart.Version; got != "ref+vault://secret/charts#/version" {
+		t.Errorf("secret ref version parsed as %q", got)
+	}
+
+	got := UpdateText([]byte(input), map[string]string{"app": "1.1.0", "vaulted": "2.0.0"}, nil)
+	want := strings.Replace(input, "version: 1.0.0 # pinned", "version: 1.1.0 # pinned", 1)
+	if got != want {
+		t.Errorf("UpdateText() =\n%s\nwant\n%s", got, want)
+	}
+	if got := UpdateChartNames([]byte(input), map[string]string{"vaulted": "private/other"}); got != input {
+		t.Errorf("UpdateChartNames() rewrote a secret ref:\n%s", got)
+	}
+
+	entries, err := ParseRepositories([]byte(input))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ParseRepositories() = %v, %v", entries, err)
+	}
+	if entries[0].Username != "" || entries[0].Password != "" {
+		t.Errorf("secret ref credentials sent as-is: %+v", entries[0])
+	}
+
+	problems, err := Validate([]byte(input), func(name string) bool { return name == "private" })
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Validate() = %v, %v", problems, err)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	original := `releases:
+  - name: nginx
+    chart:
+      name: bitnami/nginx
+      version: 15.0.0 # pinned
+  - name: redis
+    chart:
+      name: bitnami/redis
+      version: "18.0.0"
+`
+	updated := strings.NewReplacer("15.0.0", "15.1.0", `"18.0.0"`, `"18.1.0"`).Replace(original)
+	got := Annotate(original, updated, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC))
+	want := strings.NewReplacer(
+		"version: 15.0.0 # pinned", "version: 15.1.0 # pinned # was 15.0.0 (updated 2024-06-01)",
+		`version: "18.0.0"`, `version: "18.1.0" # was 18.0.0 (updated 2024-06-01)`,
+	).Replace(original)
+	if got != want {
+		t.Fatalf("Annotate() =\n%s\nwant\n%s", got, want)
+	}
+
+	// the next update replaces the annotation instead of stacking another one
+	next := strings.Replace(got, "version: 15.1.0", "version: 15.2.0", 1)
+	if got := Annotate(want, next, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)); !strings.Contains(got, "version: 15.2.0 # pinned # was 15.1.0 (updated 2024-07-01)\n") {
+		t.Errorf("re-annotated line:\n%s", got)
+	}
+
+	// reverting a line drops its annotation; untouched lines keep theirs
+	reverted := strings.Replace(want, "version: 15.1.0", "version: 15.0.0", 1)
+	if got := RemoveAnnotations(want, reverted); !strings.Contains(got, "version: 15.0.0 # pinned\n") || !strings.Contains(got, "# was 18.0.0") {
+		t.Errorf("RemoveAnnotations() =\n%s", got)
+	}
+}
+
+func TestUpdateAppVersionComments(t *testing.T) {
+	original := `releases:
+  - name: nginx
+    chart:
+      name: bitnami/nginx
+      version: 15.1.0 # pinned # was 15.0.0 (updated 2024-06-01)
+  - name: redis
+    chart:
+      name: bitnami/redis
+      version: "18.0.0" # appVersion: 7.2.0
+  - name: pg
+    chart:
+      name: bitnami/postgresql
+      version: 13.0.0
+`
+	result := CheckResult{Releases: []ReleaseResult{
+		Updated(ReleaseUpdate{Release: "nginx", FromVersion: "15.0.0", ToVersion: "15.1.0", CurrentAppVersion: "1.25.0", LatestAppVersion: "1.25.3"}),
+		{Release: "redis", Status: StatusUpToDate, Version: "18.0.0", CurrentAppVersion: "7.2.4"},
+		{Release: "pg", Status: StatusFailed, Version: "13.0.0"},
+	}}
+	got := UpdateAppVersionComments([]byte(original), result.AppVersions())
+	want := strings.NewReplacer(
+		"15.1.0 # pinned # was", "15.1.0 # pinned # appVersion: 1.25.3 # was",
+		"# appVersion: 7.2.0", "# appVersion: 7.2.4",
+	).Replace(original)
+	if got != want {
+		t.Fatalf("UpdateAppVersionComments() =\n%s\nwant\n%s", got, want)
+	}
+	if again := UpdateAppVersionComments([]byte(got), result.AppVersions()); again != got {
+		t.Errorf("second run changed the file:\n%s", again)
+	}
+
+	// a new appVersion alone is not a version change for Annotate
+	if annotated := Annotate(original, got, time.Now()); annotated != got {
+		t.Errorf("Annotate() annotated appVersion comments:\n%s", annotated)
+	}
+	if line := StripComments(`      version: 1.0.0 # keep # appVersion: 2.0 # was 0.9.0 (updated 2024-06-01)`); line != "      version: 1.0.0 # keep" {
+		t.Errorf("StripComments() = %q", line)
+	}
+}
+
+func TestReplaceKeyLine(t *testing.T) {
+	tests := []struct {
+		line, key, value, want string
+	}{
+		{"      version: 1.0.0", "version", "2.0.0", "      version: 2.0.0"},
+		{"\tversion:   '1.0.0'   # keep  spacing", "version", "2.0.0", "\tversion:   '2.0.0'   # keep  spacing"},
+		{`  - version: "1.0.0"# tight`, "version", "2.0.0", `  - version: "2.0.0"# tight`},
+		{"version:", "version", "2.0.0", "version: 2.0.0"},
+		{"version:  # set by CI", "version", "2.0.0", "version:  2.0.0 # set by CI"},
+		{"name: 'it''s'", "name", "o'k", "name: 'o''k'"},
+		{`name: "a\"b"`, "name", `c"d`, `name: "c\"d"`},
+		{`      "version": 1.0.0`, "version", "2.0.0", `      "version": 2.0.0`},
+		{"version: !!str 1.0.0 # tagged", "version", "2.0.0", "version: !!str 2.0.0 # tagged"},
+		{"version: &v '1.0.0'", "version", "2.0.0", "version: &v '2.0.0'"},
+		// unchanged
+		{`version: "2.0.0"`, "version", "2.0.0", `version: "2.0.0"`},
+		{"version: ref+vault://app#/version", "version", "2.0.0", "version: ref+vault://app#/version"},
+		{"appVersion: 1.0.0", "version", "2.0.0", "appVersion: 1.0.0"},
+		{"version: '1.0.0", "version", "2.0.0", "version: '1.0.0"},
+		{"version: *v", "version", "2.0.0", "version: *v"},
+	}
+	for _, tt := range tests {
+		got, changed := replaceKeyLine(tt.line, tt.key, tt.value)
+		if got != tt.want || changed != (tt.line != tt.want) {
+			t.Errorf("replaceKeyLine(%q, %q, %q) = %q, %v; want %q", tt.line, tt.key, tt.value, got, changed, tt.want)
+		}
+	}
+}
+
+func TestUpdateTextScalarStyles(t *testing.T) {
+	original := `releases:
+  - "name": nginx
+    chart:
+      'name': bitnami/nginx
+      "version": !!str 15.0.0
+  - name: redis
+    chart:
+      name: bitnami/redis
+      version: >-
+        18.0.0
+  - name: pg
+    chart:
+      name: bitnami/postgresql
+      version: |
+        13.0.0
+        13.0.1
+`
+	hw, err := Parse([]byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := hw.Releases[1].Chart.Version; v != "18.0.0" {
+		t.Errorf("folded version parsed as %q", v)
+	}
+	got := UpdateText([]byte(original), map[string]string{"nginx": "15.1.0", "redis": "18.1.0", "pg": "14.0.0"}, nil)
+	want := strings.NewReplacer("!!str 15.0.0", "!!str 15.1.0", "        18.0.0", "        18.1.0").Replace(original)
+	if got != want {
+		t.Errorf("UpdateText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	top := write("helmwave.yml.tpl", `version: 0.41.1
+releases:
+{{ readFile "releases/ingress.yml" | indent 2 }}
+  - name: redis
+    chart:
+      name: bitnami/redis
+      version: 18.0.0
+`)
+	ingress := write("releases/ingress.yml", `- name: nginx
+  chart:
+    name: bitnami/nginx
+    version: 15.0.0 # pinned
+{{ readFile "releases/extra.yml" }}
+`)
+	extra := write("releases/extra.yml", "# nothing here\n")
+
+	in, hw, err := ReadIncluded(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hw.Releases) != 2 || hw.Releases[0].Chart.Version != "15.0.0" || hw.Releases[1].Name != "redis" {
+		t.Fatalf("releases = %+v", hw.Releases)
+	}
+	if want := []string{top, ingress, extra}; !slices.Equal(in.Files, want) {
+		t.Errorf("Files = %v, want %v", in.Files, want)
+	}
+	if file, line := in.Locate(6); file != ingress || line != 4 {
+		t.Errorf("Locate(6) = %s:%d", file, line)
+	}
+
+	lines := ReleaseLines([]byte(in.Text), []string{"redis", "nginx", "podinfo"})
+	if len(lines) != 2 || lines["redis"] != 8 {
+		t.Errorf("ReleaseLines() = %v", lines)
+	}
+	if file, _ := in.Locate(lines["nginx"]); file != ingress {
+		t.Errorf("nginx located in %s, want %s", file, ingress)
+	}
+
+	out := UpdateText([]byte(in.Text), map[string]string{"nginx": "15.1.0", "redis": "18.1.0"}, nil)
+	files, err := in.Split(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Split() returned %d files, want the main file and the edited include", len(files))
+	}
+	if got := files[ingress]; !strings.Contains(got, "\n    version: 15.1.0 # pinned\n") || !strings.Contains(got, `{{ readFile "releases/extra.yml" }}`) {
+		t.Errorf("included file =\n%s", got)
+	}
+	if got := files[top]; !strings.Contains(got, "version: 18.1.0") || !strings.Contains(got, `{{ readFile "releases/ingress.yml" | indent 2 }}`) {
+		t.Errorf("main file =\n%s", got)
+	}
+
+	joined, err := in.Join(map[string]string{ingress: files[ingress]})
+	if err != nil || joined != strings.Replace(in.Text, "15.0.0 # pinned", "15.1.0 # pinned", 1) {
+		t.Errorf("Join() = %q, %v", joined, err)
+	}
+	if _, err := in.Join(map[string]string{ingress: "- name: nginx\n"}); err == nil {
+		t.Error("Join() accepted a file with other lines")
+	}
+
+	write("releases/extra.yml", `{{ readFile "releases/ingress.yml" }}`)
+	if _, _, err := ReadIncluded(top); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("cyclic include: err = %v", err)
+	}
+}
+
+func TestParseVersionVar(t *testing.T) {
+	tests := []struct {
+		version string
+		want    VersionVar
+		ok      bool
+	}{
+		{`{{ env "NGINX_CHART_VERSION" }}`, VersionVar{Env: true, Key: "NGINX_CHART_VERSION"}, true},
+		{`{{- requiredEnv "V" -}}`, VersionVar{Env: true, Key: "V"}, true},
+		{`${V}`, VersionVar{Env: true, Key: "V"}, true},
+		{`{{ get "charts.nginx" (readFile "versions.yaml" | fromYaml) }}`, VersionVar{File: "versions.yaml", Key: "charts.nginx"}, true},
+		{`{{ (readFile "vars/versions.yml" | fromYaml).nginx }}`, VersionVar{File: "vars/versions.yml", Key: "nginx"}, true},
+		{`{{ .Values.nginx }}`, VersionVar{}, false},
+		{`15.0.0`, VersionVar{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseVersionVar(tt.version); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseVersionVar(%q) = %+v, %v; want %+v, %v", tt.version, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// a templated version parses as written and is never overwritten with a literal
+	text := `releases:
+  - name: nginx
+    chart:
+      name: bitnami/nginx
+      version: {{ env "NGINX_CHART_VERSION" }}
+`
+	hw, err := Parse([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := hw.Releases[0].Chart.Version; v != `{{ env "NGINX_CHART_VERSION" }}` {
+		t.Errorf("templated version parsed as %q", v)
+	}
+	if got := UpdateText([]byte(text), map[string]string{"nginx": "15.1.0"}, nil); got != text {
+		t.Errorf("UpdateText() rewrote the template:\n%s", got)
+	}
+}
+
+func TestUpdateEnvFile(t *testing.T) {
+	env := "# versions\nNGINX=15.0.0\nexport REDIS='18.0.0' # pinned\nPG=\"13.0.0\"\n"
+	if vars := ReadEnvFile([]byte(env)); vars["NGINX"] != "15.0.0" || vars["REDIS"] != "18.0.0" || vars["PG"] != "13.0.0" {
+		t.Errorf("ReadEnvFile() = %v", vars)
+	}
+	got, missing := UpdateEnvFile([]byte(env), map[string]string{"REDIS": "18.1.0", "PG": "13.0.0", "MYSQL": "9.0.0"})
+	if want := strings.Replace(env, "'18.0.0'", "'18.1.0'", 1); got != want || !slices.Equal(missing, []string{"MYSQL"}) {
+		t.Errorf("UpdateEnvFile() = %q, %v; want %q, [MYSQL]", got, missing, want)
+	}
+
+	versions := "charts:\n  nginx: \"15.0.0\" # web\n  redis: 18.0.0\n"
+	if v, err := VersionsFileValue([]byte(versions), "charts.nginx"); err != nil || v != "15.0.0" {
+		t.Errorf("VersionsFileValue() = %q, %v", v, err)
+	}
+	out, err := UpdateVersionsFile([]byte(versions), map[string]string{"charts.nginx": "15.1.0"})
+	if want := strings.Replace(versions, `"15.0.0"`, `"15.1.0"`, 1); err != nil || out != want {
+		t.Errorf("UpdateVersionsFile() = %q, %v; want %q", out, err, want)
+	}
+	if _, err := UpdateVersionsFile([]byte(versions), map[string]string{"charts.pg": "1.0.0"}); err == nil {
+		t.Error("missing key: no error")
+	}
+}
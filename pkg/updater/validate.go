@@ -0,0 +1,162 @@
+package updater
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a validation finding, located in the original file.
+type Problem struct {
+	Line int
+	// Severity is "error" or "warning"
+	Severity string
+	Message  string
+}
+
+// Keys helmwave accepts at the top level, in a release and in its chart block.
+var (
+	topLevelKeys = []string{"project", "version", "repositories", "registries", "releases", "monitors", "lifecycle"}
+	releaseKeys  = []string{
+		"name", "chart", "namespace", "context", "description", "create_namespace", "values", "tags",
+		"depends_on", "store", "timeout", "wait", "wait_for_jobs", "atomic", "max_history",
+		"offline_kube_version", "lifecycle", "monitors", "show_notes", "post_renderer",
+		"pending_release_strategy", "allow_failure", "skip_crds", "disable_hooks", "disable_openapi_validation",
+		"reuse_values", "reset_values", "recreate", "force", "cleanup_on_fail", "enable_dns",
+		"delete_propagation", "labels", "sub_notes", "hide_notes", "chart_depends_on",
+	}
+	chartKeys = []string{
+		"name", "version", "username", "password", "ca_file", "cert_file", "key_file",
+		"cafile", "certfile", "keyfile", "insecure", "insecureskiptlsverify", "insecure_skip_tls_verify",
+		"plain_http", "pass_credentials_all", "skip_dependency_update", "skip_refresh", "verify", "keyring",
+	}
+)
+
+// Validate checks a helmwave file against the built-in schema: required release fields,
+// unknown keys, duplicate releases (by ID, see Release.ID) and repositories that
+// hasRepo does not know. A file that is not valid YAML returns a *ParseError.
+func Validate(data []byte, hasRepo func(name string) bool) ([]Problem, error) {
+	lines := strings.Split(string(data), "\n")
+	origin := make([]int, len(lines))
+	for i := range origin {
+		origin[i] = i
+	}
+	// as in Parse, the possibly templated repositories and registries sections are not parsed
+	processed, origin := removeTopLevelSection(lines, origin, "repositories")
+	processed, origin = removeTopLevelSection(processed, origin, "registries")
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(processed, "\n")), &doc); err != nil {
+		return nil, newParseError(err, lines, origin)
+	}
+	v := validator{origin: origin}
+	if len(doc.Content) == 0 {
+		return v.problems, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.errorf(root, "the file must be a mapping")
+		return v.problems, nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch {
+		case key.Value == "releases":
+			v.releases(value, hasRepo)
+		case slices.Contains(topLevelKeys, key.Value), strings.HasPrefix(key.Value, "."), value.Anchor != "":
+		default:
+			v.warnf(key, "unknown top-level key %q", key.Value)
+		}
+	}
+	slices.SortStableFunc(v.problems, func(a, b Problem) int { return a.Line - b.Line })
+	return v.problems, nil
+}
+
+type validator struct {
+	origin   []int
+	problems []Problem
+}
+
+// line maps a node of the parsed (stripped) text to its line in the original file.
+func (v *validator) line(n *yaml.Node) int {
+	if n.Line < 1 || n.Line > len(v.origin) {
+		return 0
+	}
+	return v.origin[n.Line-1] + 1
+}
+
+func (v *validator) errorf(n *yaml.Node, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Line: v.line(n), Severity: "error", Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(n *yaml.Node, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Line: v.line(n), Severity: "warning", Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) releases(list *yaml.Node, hasRepo func(string) bool) {
+	if list.Kind != yaml.SequenceNode {
+		v.errorf(list, "releases must be a list")
+		return
+	}
+	firstLine := make(map[string]int)
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			v.errorf(item, "a release must be a mapping")
+			continue
+		}
+		var rel Release
+		if err := item.Decode(&rel); err != nil {
+			v.errorf(item, "invalid release: %v", err)
+			continue
+		}
+		v.unknownKeys(item, releaseKeys, "release")
+		if chart := mappingValue(item, "chart"); chart != nil {
+			if chart.Kind == yaml.AliasNode {
+				chart = chart.Alias
+			}
+			if chart.Kind == yaml.MappingNode {
+				v.unknownKeys(chart, chartKeys, "chart")
+			}
+		}
+
+		if rel.Name == "" {
+			v.errorf(item, "release without a name")
+			continue
+		}
+		if rel.Chart.Name == "" {
+			v.errorf(item, "release %s: chart.name is required", rel.Name)
+		}
+		if first, ok := firstLine[rel.ID()]; ok {
+			v.errorf(item, "duplicate release %s (first defined on line %d); set namespace or context to tell them apart", rel.ID(), first)
+		} else {
+			firstLine[rel.ID()] = v.line(item)
+		}
+		if repoName, _, ok := SplitRepoChart(rel.Chart.Name); ok && hasRepo != nil && !hasRepo(repoName) && !IsSecretRef(rel.Chart.Name) {
+			v.errorf(item, "release %s: repository %q is not defined", rel.Name, repoName)
+		}
+	}
+}
+
+// unknownKeys warns about keys of m that are not in known; merge keys (<<) are allowed.
+func (v *validator) unknownKeys(m *yaml.Node, known []string, what string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key := m.Content[i]
+		if key.Value == "<<" || slices.Contains(known, key.Value) {
+			continue
+		}
+		v.warnf(key, "unknown %s key %q", what, key.Value)
+	}
+}
+
+// mappingValue returns the value of key in mapping m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// rangeVersion matches a chart.version holding a single-operator range, e.g. ~1.25.0 or ^2.3.
+var rangeVersion = regexp.MustCompile(`^(~>|~|\^|>=|>)\s*v?(\d+(?:\.\d+){0,2})$`)
+
+// VersionRange is a chart.version holding a single-operator semver range such as ~1.25.0 or
+// ^2.3. Bumps rewrite its base and keep the operator.
+type VersionRange struct {
+	Operator string
+	Base     string
+}
+
+// ParseVersionRange parses a single-operator range; ok is false for exact versions and for
+// compound or wildcard constraints.
+func ParseVersionRange(v string) (VersionRange, bool) {
+	m := rangeVersion.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return VersionRange{}, false
+	}
+	return VersionRange{Operator: m[1], Base: m[2]}, true
+}
+
+// IsConstraint reports whether v is a semver constraint expression rather than a version.
+func IsConstraint(v string) bool {
+	v = strings.TrimSpace(v)
+	if _, err := semver.NewVersion(v); err == nil || v == "" {
+		return false
+	}
+	_, err := semver.NewConstraint(v)
+	return err == nil
+}
+
+func (r VersionRange) String() string {
+	return r.Operator + r.Base
+}
+
+// Allows reports whether version is within the range.
+func (r VersionRange) Allows(version string) bool {
+	c, err := semver.NewConstraint(r.String())
+	if err != nil {
+		return false
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && c.Check(v)
+}
+
+// Bump rebases the range onto version, keeping the operator and the number of segments of
+// the base: ~1.25.0 becomes ~1.27.3 and ^2.3 becomes ^3.1 for 3.1.4.
+func (r VersionRange) Bump(version string) string {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	n := strings.Count(r.Base, ".") + 1
+	if len(parts) > n {
+		parts = parts[:n]
+	}
+	// drop prerelease and build metadata from the last kept segment
+	last := parts[len(parts)-1]
+	if i := strings.IndexAny(last, "-+"); i >= 0 {
+		parts[len(parts)-1] = last[:i]
+	}
+	return r.Operator + strings.Join(parts, ".")
+}
+
+// ResolveConstraint returns the newest of versions that satisfies constraint. As in helm,
+// prereleases only match constraints that mention one.
+func ResolveConstraint(constraint string, versions []PublishedVersion) (PublishedVersion, bool) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return PublishedVersion{}, false
+	}
+	var best PublishedVersion
+	var bestVersion *semver.Version
+	for _, pv := range versions {
+		v, err := semver.NewVersion(pv.Version)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if bestVersion == nil || v.GreaterThan(bestVersion) {
+			best, bestVersion = pv, v
+		}
+	}
+	return best, bestVersion != nil
+}
@@ -0,0 +1,165 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VersionVar is the variable a templated chart version reads: an environment variable or
+// a key of a YAML versions file.
+type VersionVar struct {
+	// Env is set for {{ env "X" }}, {{ requiredEnv "X" }} and ${X}
+	Env bool
+	// File is the versions file read with readFile; empty for environment variables
+	File string
+	// Key is the variable name, or the dotted key path in File
+	Key string
+}
+
+func (v VersionVar) String() string {
+	if v.Env {
+		return "$" + v.Key
+	}
+	return v.File + ":" + v.Key
+}
+
+var (
+	envVersionRe = regexp.MustCompile(`^(?:\{\{-?\s*(?:env|requiredEnv)\s+"([A-Za-z_][A-Za-z0-9_]*)"\s*-?\}\}|\$\{([A-Za-z_][A-Za-z0-9_]*)\})$`)
+	// {{ get "nginx" (readFile "versions.yaml" | fromYaml) }}
+	getVersionRe = regexp.MustCompile(`^\{\{-?\s*get\s+"([^"]+)"\s+\(\s*readFile\s+"([^"]+)"\s*\|\s*fromYaml\s*\)\s*-?\}\}$`)
+	// {{ (readFile "versions.yaml" | fromYaml).nginx }}
+	fieldVersionRe = regexp.MustCompile(`^\{\{-?\s*\(\s*readFile\s+"([^"]+)"\s*\|\s*fromYaml\s*\)\.([A-Za-z0-9_.-]+)\s*-?\}\}$`)
+)
+
+// IsTemplated reports whether a chart version is a template expression or an environment
+// reference rather than a version.
+func IsTemplated(version string) bool {
+	return strings.Contains(version, "{{") || strings.Contains(version, "${")
+}
+
+// ParseVersionVar returns the variable version reads when it is exactly one of the
+// supported expressions.
+func ParseVersionVar(version string) (VersionVar, bool) {
+	version = strings.TrimSpace(version)
+	if m := envVersionRe.FindStringSubmatch(version); m != nil {
+		return VersionVar{Env: true, Key: m[1] + m[2]}, true
+	}
+	if m := getVersionRe.FindStringSubmatch(version); m != nil {
+		return VersionVar{File: m[2], Key: m[1]}, true
+	}
+	if m := fieldVersionRe.FindStringSubmatch(version); m != nil {
+		return VersionVar{File: m[1], Key: m[2]}, true
+	}
+	return VersionVar{}, false
+}
+
+// envAssignment splits a .env line ("KEY=value", optionally "export KEY=value") into its
+// key and the byte range of its value, quotes included, with the value's quote character.
+func envAssignment(line string) (key string, start, end int, quote byte, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if strings.HasPrefix(trimmed, "#") {
+		return "", 0, 0, 0, false
+	}
+	trimmed = strings.TrimPrefix(trimmed, "export ")
+	key, _, ok = strings.Cut(trimmed, "=")
+	if key = strings.TrimSpace(key); !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", 0, 0, 0, false
+	}
+	// values are quoted and commented like YAML scalars
+	start, end, quote, ok = valueSpan(line, strings.Index(line, "=")+1)
+	return key, start, end, quote, ok
+}
+
+// ReadEnvFile returns the variables a .env file assigns.
+func ReadEnvFile(data []byte) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, start, end, quote, ok := envAssignment(line); ok {
+			vars[key] = unquoteScalar(line[start:end], quote)
+		}
+	}
+	return vars
+}
+
+// UpdateEnvFile sets the variables of a .env file to values, keeping quoting, comments and
+// every other line. The last assignment of a variable is the one updated. Variables the file
+// does not assign are returned as missing.
+func UpdateEnvFile(data []byte, values map[string]string) (string, []string) {
+	lines := strings.Split(string(data), "\n")
+	last := make(map[string]int)
+	for i, line := range lines {
+		if key, _, _, _, ok := envAssignment(line); ok {
+			last[key] = i
+		}
+	}
+	var missing []string
+	for _, key := range sortedKeys(values) {
+		i, ok := last[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		_, start, end, quote, _ := envAssignment(lines[i])
+		if unquoteScalar(lines[i][start:end], quote) == values[key] {
+			continue
+		}
+		newLine := lines[i][:start] + quoteScalar(values[key], quote) + lines[i][end:]
+		debugf("replacing line %d for %s: %q -> %q", i+1, key, lines[i], newLine)
+		lines[i] = newLine
+	}
+	return strings.Join(lines, "\n"), missing
+}
+
+// VersionsFileValue returns the scalar at the dotted key path of a YAML versions file.
+func VersionsFileValue(data []byte, key string) (string, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return "", err
+	}
+	for _, doc := range docs {
+		if n := nodeAt(doc, strings.Split(key, ".")...); n != nil && n.Kind == yaml.ScalarNode {
+			return n.Value, nil
+		}
+	}
+	return "", fmt.Errorf("no %s key", key)
+}
+
+// UpdateVersionsFile sets the dotted key paths of a YAML versions file to values, editing
+// their lines in place like UpdateText.
+func UpdateVersionsFile(data []byte, values map[string]string) (string, error) {
+	docs, err := manifestDocuments(data)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, key := range sortedKeys(values) {
+		path := strings.Split(key, ".")
+		var m *yaml.Node
+		for _, doc := range docs {
+			if m = nodeAt(doc, path[:len(path)-1]...); m != nil {
+				break
+			}
+		}
+		if m == nil {
+			return "", fmt.Errorf("no %s key", key)
+		}
+		if err := setKeyLine(lines, m, path[len(path)-1], values[key]); err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// sortedKeys returns the keys of m in order, so edits are logged in the same order every run.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
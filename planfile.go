@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// planFile is a helmwave planfile (.helmwave/planfile) checked against the source file and
+// the latest versions (-planfile)
+var planFile string
+
+// planDrift is a release whose chart version in the planfile differs from the source file
+// or from the latest published version. Plan or Source is nil when the release is missing
+// on that side; Latest is empty when the release was not resolved.
+type planDrift struct {
+	Release      string
+	Plan, Source *string
+	Latest       string
+}
+
+// comparePlan lists the releases of plan and source whose planned chart version differs
+// from the source file or the latest version in result, in plan order followed by source
+// releases missing from the plan. Releases are matched by ID.
+func comparePlan(plan, source *Helmwave, result checkResult) []planDrift {
+	latest := make(map[string]string, len(result.Releases))
+	for _, r := range result.Releases {
+		if r.Latest != "" {
+			latest[r.ID()] = r.Latest
+		}
+	}
+
+	var drifts []planDrift
+	planned := make(map[string]bool, len(plan.Releases))
+	for _, p := range plan.Releases {
+		id := p.ID()
+		planned[id] = true
+		d := planDrift{Release: id, Plan: &p.Chart.Version, Latest: latest[id]}
+		if i := slices.IndexFunc(source.Releases, func(r Release) bool { return r.ID() == id }); i >= 0 {
+			d.Source = &source.Releases[i].Chart.Version
+		}
+		if d.Source == nil || *d.Plan != *d.Source || (d.Latest != "" && *d.Plan != d.Latest) {
+			drifts = append(drifts, d)
+		}
+	}
+	for _, s := range source.Releases {
+		if id := s.ID(); !planned[id] {
+			drifts = append(drifts, planDrift{Release: id, Source: &s.Chart.Version, Latest: latest[id]})
+		}
+	}
+	return drifts
+}
+
+// reportPlanfile compares -planfile with source, read from file, and the run's results and
+// writes a table of the differences to out (with the text -output). A plan that differs is
+// logged as a warning.
+func reportPlanfile(out io.Writer, file string, source *Helmwave, result checkResult) error {
+	if planFile == "" {
+		return nil
+	}
+	_, plan, err := updater.ReadFile(planFile)
+	if err != nil {
+		return fmt.Errorf("failed to read planfile: %w", err)
+	}
+	drifts := comparePlan(&plan, source, result)
+	if len(drifts) == 0 {
+		logInfof("planfile %s matches %s and the latest chart versions", planFile, file)
+		return nil
+	}
+	logWarnf("⚠️ planfile %s is stale: %d releases differ from %s or the latest chart versions", planFile, len(drifts), file)
+	if outputFormat != outputText {
+		return nil
+	}
+
+	pauseProgress()
+	fmt.Fprintf(out, tr("\nPlanfile %s:\n"), planFile)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tr("RELEASE"), tr("PLAN"), tr("SOURCE"), tr("LATEST"))
+	for _, d := range drifts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Release, planCell(d.Plan), planCell(d.Source), orDash(d.Latest))
+	}
+	return w.Flush()
+}
+
+// planCell shows a version of the planfile table; a release missing on that side is marked.
+func planCell(version *string) string {
+	if version == nil {
+		return tr("(missing)")
+	}
+	return orDash(*version)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// policyCommand is run for every proposed update and decides whether it is made
+// (-policy-command, or policyCommand in the config)
+var policyCommand string
+
+// policyCommandTimeout bounds one run of the policy command.
+const policyCommandTimeout = 30 * time.Second
+
+// Decisions of the policy command.
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+	policyHold  = "hold"
+)
+
+// policyReasons are the reason codes of updates the policy command did not allow.
+var policyReasons = map[string]string{policyDeny: updater.ReasonPolicyDeny, policyHold: updater.ReasonPolicyHold}
+
+// policyInput is what the policy command reads on stdin for one proposed update.
+type policyInput struct {
+	Release           string   `json:"release"`
+	Namespace         string   `json:"namespace,omitempty"`
+	Context           string   `json:"context,omitempty"`
+	Chart             string   `json:"chart"`
+	FromVersion       string   `json:"fromVersion"`
+	ToVersion         string   `json:"toVersion"`
+	CurrentAppVersion string   `json:"currentAppVersion,omitempty"`
+	LatestAppVersion  string   `json:"latestAppVersion,omitempty"`
+	Importance        string   `json:"importance"`
+	Tags              []string `json:"tags,omitempty"`
+	// AgeDays is how long ago ToVersion was published; -1 when the source records no date
+	AgeDays            int      `json:"ageDays"`
+	MaintainersRemoved []string `json:"maintainersRemoved,omitempty"`
+	MaintainersAdded   []string `json:"maintainersAdded,omitempty"`
+	FromLicense        string   `json:"fromLicense,omitempty"`
+	ToLicense          string   `json:"toLicense,omitempty"`
+}
+
+// policyDecision is the answer of the policy command.
+type policyDecision struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// describe is the skip reason of a denied or held update to version.
+func (d policyDecision) describe(version string) string {
+	verb := "denied"
+	if d.Decision == policyHold {
+		verb = "held"
+	}
+	reason := fmt.Sprintf("%s %s by the policy command", version, verb)
+	if d.Reason != "" {
+		reason += ": " + d.Reason
+	}
+	return reason
+}
+
+// newPolicyInput describes u for the policy command; published is when ToVersion was released.
+func newPolicyInput(u releaseUpdate, published time.Time, now time.Time) policyInput {
+	age := -1
+	if !published.IsZero() {
+		age = int(now.Sub(published).Hours() / 24)
+	}
+	return policyInput{
+		Release: u.Release, Namespace: u.Namespace, Context: u.Context, Chart: u.Chart,
+		FromVersion: u.FromVersion, ToVersion: u.ToVersion,
+		CurrentAppVersion: u.CurrentAppVersion, LatestAppVersion: u.LatestAppVersion,
+		Importance: u.Importance, Tags: u.Tags, AgeDays: age,
+		MaintainersRemoved: u.MaintainersRemoved, MaintainersAdded: u.MaintainersAdded,
+		FromLicense: u.FromLicense, ToLicense: u.ToLicense,
+	}
+}
+
+// evaluatePolicy runs -policy-command with the JSON of in on stdin. The command prints a
+// decision (allow, deny or hold) and optionally a reason, either as JSON
+// ({"decision": "deny", "reason": "..."}) or as plain text ("deny too fresh"), so an
+// `opa eval --format raw` query or a CEL evaluator can answer directly. A failing command
+// or an unknown answer is an error: a guardrail that cannot decide does not let updates through.
+func evaluatePolicy(ctx context.Context, command string, in policyInput) (policyDecision, error) {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return policyDecision{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, policyCommandTimeout)
+	defer cancel()
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return policyDecision{}, fmt.Errorf("policy command: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parsePolicyDecision(string(out))
+}
+
+// parsePolicyDecision reads the output of the policy command.
+func parsePolicyDecision(out string) (policyDecision, error) {
+	out = strings.TrimSpace(out)
+	var d policyDecision
+	if strings.HasPrefix(out, "{") {
+		if err := json.Unmarshal([]byte(out), &d); err != nil {
+			return policyDecision{}, fmt.Errorf("policy command printed invalid JSON: %w", err)
+		}
+	} else {
+		decision, reason, _ := strings.Cut(out, " ")
+		d = policyDecision{Decision: strings.Trim(decision, `"`), Reason: strings.TrimSpace(reason)}
+	}
+	d.Decision = strings.ToLower(strings.TrimSpace(d.Decision))
+	switch d.Decision {
+	case policyAllow, policyDeny, policyHold:
+		return d, nil
+	}
+	return policyDecision{}, fmt.Errorf("policy command answered %q (expected allow, deny or hold)", out)
+}
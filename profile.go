@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// cpuProfile receives a CPU profile of the whole run (-cpuprofile)
+var cpuProfile string
+
+// memProfile receives a heap profile taken at the end of the run (-memprofile)
+var memProfile string
+
+// pprofEndpoints adds /debug/pprof/ to the controller's -health-addr server (-pprof)
+var pprofEndpoints bool
+
+// startProfiling starts the -cpuprofile CPU profile. The returned stop function ends it and
+// writes the -memprofile heap profile; failures there are only logged, the run is done.
+func startProfiling() (stop func(), err error) {
+	var cpu *os.File
+	if cpuProfile != "" {
+		if cpu, err = os.Create(cpuProfile); err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := rpprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+	}
+	return func() {
+		if cpu != nil {
+			rpprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				logWarnf("⚠️ failed to write CPU profile %s: %v", cpuProfile, err)
+			} else {
+				logInfof("wrote CPU profile to %s", cpuProfile)
+			}
+		}
+		if memProfile != "" {
+			if err := writeHeapProfile(memProfile); err != nil {
+				logWarnf("⚠️ failed to write heap profile %s: %v", memProfile, err)
+			} else {
+				logInfof("wrote heap profile to %s", memProfile)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes a heap profile of live objects to path.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// collect garbage first so the profile shows what the run still holds
+	runtime.GC()
+	if err := rpprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// registerPprof serves the net/http/pprof handlers under /debug/pprof/ on mux.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
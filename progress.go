@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// noProgress disables the progress line (-no-progress)
+var noProgress bool
+
+// progressFrames are the spinner frames of the progress line; plainProgressFrames are used
+// with -no-emoji
+var (
+	progressFrames      = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	plainProgressFrames = []string{"|", "/", "-", "\\"}
+)
+
+// progressMu guards currentProgress and its fields: the spinner goroutine redraws the line
+// while logs and the report are written from the main goroutine.
+var (
+	progressMu      sync.Mutex
+	currentProgress *progress
+)
+
+// progress is a spinner line on stderr naming the repository or release being processed,
+// so that long runs visibly move on. It is cleared before logs and report output.
+type progress struct {
+	w        io.Writer
+	phase    string
+	total, n int
+	item     string
+	frame    int
+	shown    bool
+	paused   bool
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// startProgress starts a progress line for total items. It returns nil (a no-op progress)
+// with -no-progress, -quiet or when stderr is not a terminal.
+func startProgress(phase string, total int) *progress {
+	if noProgress || quiet || total == 0 || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return runProgress(os.Stderr, phase, total, 100*time.Millisecond)
+}
+
+func runProgress(w io.Writer, phase string, total int, tick time.Duration) *progress {
+	p := &progress{w: w, phase: phase, total: total, stop: make(chan struct{})}
+	progressMu.Lock()
+	currentProgress = p
+	progressMu.Unlock()
+
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				progressMu.Lock()
+				p.frame++
+				if !p.paused {
+					p.draw()
+				}
+				progressMu.Unlock()
+			}
+		}
+	}()
+	return p
+}
+
+// step moves on to item and resumes a paused line.
+func (p *progress) step(item string) {
+	if p == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	p.n++
+	p.item = item
+	p.paused = false
+	p.draw()
+}
+
+// finish stops the spinner and clears the line.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	p.clear()
+	if currentProgress == p {
+		currentProgress = nil
+	}
+}
+
+func (p *progress) draw() {
+	frames := progressFrames
+	if noEmoji {
+		frames = plainProgressFrames
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s %s [%d/%d] %s", frames[p.frame%len(frames)], p.phase, p.n, p.total, p.item)
+	p.shown = true
+}
+
+func (p *progress) clear() {
+	if p.shown {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// withProgressCleared runs write (a log record) with the progress line cleared; the
+// spinner draws it again on its next tick.
+func withProgressCleared(write func()) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	if currentProgress != nil {
+		currentProgress.clear()
+	}
+	write()
+}
+
+// pauseProgress clears the progress line until the next step, so that report output on
+// stdout is not interleaved with it.
+func pauseProgress() {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	if currentProgress != nil {
+		currentProgress.clear()
+		currentProgress.paused = true
+	}
+}
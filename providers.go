@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/registry"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// Built-in provider types. helm, oci and git are chosen from the chart name when a release
+// has no source in the config file; the others must be configured explicitly.
+const (
+	providerHelm   = "helm"
+	providerOCI    = "oci"
+	providerGitHub = "github"
+	providerHTTP   = "http"
+)
+
+func init() {
+	updater.RegisterProvider(providerGitHub, newGitHubProvider)
+	updater.RegisterProvider(providerHTTP, newHTTPProvider)
+}
+
+// providerTypes lists every type accepted in the config file.
+func providerTypes() []string {
+	return append([]string{providerHelm, providerOCI}, updater.ProviderTypes()...)
+}
+
+// providerSet picks the version provider for each release of a run.
+type providerSet struct {
+	index   updater.IndexProvider
+	oci     ociProvider
+	sources map[string]updater.ProviderConfig
+	// builtMu guards built: releases are resolved concurrently
+	builtMu sync.Mutex
+	built   map[string]updater.VersionProvider
+	// resolutions and appVersions are shared by releases using the same chart (see resolveKey)
+	resolutions memo[updater.Resolution]
+	appVersions memo[[2]string]
+	// releaseNotes are the GitHub release notes by project (-release-notes)
+	releaseNotes memo[[]updater.ReleaseNote]
+	// localDir is the directory local chart paths are relative to: that of the checked file
+	localDir string
+}
+
+func newProviderSet(indexes map[string]*repo.IndexFile, getOCIConn func(Release) (*ociConn, error)) *providerSet {
+	return &providerSet{
+		index:   updater.NewIndexProvider(indexes),
+		oci:     ociProvider{getConn: getOCIConn},
+		sources: config.Sources,
+		built:   make(map[string]updater.VersionProvider),
+	}
+}
+
+// forRelease returns the provider, its type and the release as it should be looked up
+// (the chart option of a configured source overrides chart.name).
+func (s *providerSet) forRelease(release Release) (updater.VersionProvider, string, Release, error) {
+	cfg, configured := s.sources[release.Name]
+	if !configured {
+		if updater.IsLocalChart(release.Chart.Name) {
+			return nil, providerLocal, release, nil
+		}
+		if strings.HasPrefix(release.Chart.Name, registry.OCIScheme+"://") {
+			// tags are listed on the repository; a digest pin is handled by processRelease
+			release.Chart.Name, _ = updater.SplitOCIDigest(release.Chart.Name)
+			return s.oci, providerOCI, release, nil
+		}
+		if _, ok := updater.ParseGitChart(release.Chart.Name); ok {
+			cfg = updater.ProviderConfig{Type: providerGit}
+		} else {
+			return s.index, providerHelm, release, nil
+		}
+	}
+
+	kind := strings.ToLower(cfg.Type)
+	if chart := cfg.Option("chart"); chart != "" {
+		release.Chart.Name = chart
+	}
+	if kind == providerHelm {
+		return s.index, kind, release, nil
+	}
+	if kind == providerOCI {
+		if repository := cfg.Option("repository"); repository != "" {
+			release.Chart.Name = registry.OCIScheme + "://" + strings.TrimPrefix(repository, registry.OCIScheme+"://")
+		}
+		if !strings.HasPrefix(release.Chart.Name, registry.OCIScheme+"://") {
+			return nil, kind, release, fmt.Errorf("oci source needs a repository option for chart %q", release.Chart.Name)
+		}
+		release.Chart.Name, _ = updater.SplitOCIDigest(release.Chart.Name)
+	}
+	s.builtMu.Lock()
+	defer s.builtMu.Unlock()
+	if p, ok := s.built[release.Name]; ok {
+		return p, kind, release, nil
+	}
+	var p updater.VersionProvider
+	var err error
+	if kind == providerOCI {
+		p, err = s.oci.withOptions(cfg)
+	} else {
+		p, err = updater.NewProvider(cfg)
+	}
+	if err != nil {
+		return nil, kind, release, err
+	}
+	s.built[release.Name] = p
+	return p, kind, release, nil
+}
+
+// resolveKey identifies what a lookup resolves to: the provider, the chart, the version in
+// use (or constraint) and the TLS settings. Shared providers (helm indexes, OCI registries)
+// are keyed by type; a configured source is built per release, so its key is the release.
+func (s *providerSet) resolveKey(kind string, lookup Release) string {
+	scope := kind
+	if _, configured := s.sources[lookup.Name]; configured && kind != providerHelm {
+		scope = "source:" + lookup.Name
+	}
+	return strings.Join([]string{scope, lookup.Chart.Name, lookup.Chart.Version, chartTLSOptions(lookup.Chart).key()}, "\x00")
+}
+
+// resolve resolves lookup with provider once per distinct chart (see resolveKey); releases
+// sharing a chart reuse the first resolution instead of querying and logging it again.
+func (s *providerSet) resolve(ctx context.Context, provider updater.VersionProvider, kind string, lookup Release) (updater.Resolution, error) {
+	res, shared, err := s.resolutions.do(s.resolveKey(kind, lookup), func() (updater.Resolution, error) {
+		return provider.Resolve(ctx, lookup)
+	})
+	if shared {
+		logDebugf("release %s: reusing the resolution of %s %s", lookup.Name, lookup.Chart.Name, lookup.Chart.Version)
+	}
+	return res, err
+}
+
+// resolveAppVersions is resolve for the lazily fetched appVersions of the version in use
+// and of latestVersion.
+func (s *providerSet) resolveAppVersions(ctx context.Context, r updater.AppVersionResolver, kind string, lookup Release, latestVersion string) (string, string, error) {
+	v, _, err := s.appVersions.do(s.resolveKey(kind, lookup)+"\x00"+latestVersion, func() ([2]string, error) {
+		current, latest, err := r.AppVersions(ctx, lookup, latestVersion)
+		return [2]string{current, latest}, err
+	})
+	return v[0], v[1], err
+}
+
+// ociProvider lists registry tags; appVersions need a chart pull and are fetched lazily.
+// As a configured source it can read any OCI repository (repository option) and select tags
+// with tagPattern, a regex whose "version" group (or first group, or whole match) is the
+// semver used for ordering; the matching tag itself becomes the chart version.
+type ociProvider struct {
+	getConn    func(Release) (*ociConn, error)
+	tagPattern *regexp.Regexp
+}
+
+func (p ociProvider) withOptions(cfg updater.ProviderConfig) (ociProvider, error) {
+	if pattern := cfg.Option("tagPattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return p, fmt.Errorf("oci provider: invalid tagPattern: %w", err)
+		}
+		p.tagPattern = re
+	}
+	return p, nil
+}
+
+func (p ociProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	conn, err := p.getConn(release)
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("OCI registry client: %w", err)
+	}
+	if p.tagPattern == nil {
+		latest, tags, err := latestOCIVersion(ctx, conn.client, release.Chart.Name)
+		if err != nil {
+			return updater.Resolution{}, fmt.Errorf("OCI tags: %w", err)
+		}
+		return updater.Resolution{LatestVersion: latest, Versions: updater.Published(tags)}, nil
+	}
+
+	tags, err := listRawOCITags(ctx, conn.authorizer, release.Chart.Name)
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("OCI tags: %w", err)
+	}
+	latest, ok := latestMatchingTag(tags, p.tagPattern)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("OCI tags: no tag matches %q", p.tagPattern)
+	}
+	return updater.Resolution{LatestVersion: latest}, nil
+}
+
+// latestMatchingTag returns the raw tag whose extracted version is highest.
+func latestMatchingTag(tags []string, re *regexp.Regexp) (string, bool) {
+	group := re.SubexpIndex("version")
+	if group < 0 && re.NumSubexp() > 0 {
+		group = 1
+	}
+	var best *semver.Version
+	bestTag := ""
+	for _, tag := range tags {
+		m := re.FindStringSubmatch(tag)
+		if m == nil {
+			continue
+		}
+		extracted := m[0]
+		if group > 0 {
+			extracted = m[group]
+		}
+		v, err := semver.NewVersion(updater.NormalizeSemVer(extracted))
+		if err != nil {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, bestTag = v, tag
+		}
+	}
+	return bestTag, best != nil
+}
+
+// Digest returns the manifest digest the registry serves for tag.
+func (p ociProvider) Digest(ctx context.Context, release Release, tag string) (string, error) {
+	conn, err := p.getConn(release)
+	if err != nil {
+		return "", err
+	}
+	return resolveOCIDigest(ctx, conn.authorizer, release.Chart.Name, tag)
+}
+
+func (p ociProvider) AppVersions(ctx context.Context, release Release, latestVersion string) (string, string, error) {
+	conn, err := p.getConn(release)
+	if err != nil {
+		return "", "", err
+	}
+	return ociAppVersions(ctx, conn.client, release.Chart.Name, release.Chart.Version, latestVersion)
+}
+
+// httpProvider reads versions from a custom endpoint: a JSON array of strings, a JSON object
+// with a "versions" array, or plain text with one version per line. tokenEnv (or tokenFile /
+// tokenCommand) supplies a bearer token (username* / password* for basic auth); prerelease versions are ignored unless
+// prereleases: "true".
+type httpProvider struct {
+	url         string
+	auth        providerAuth
+	prereleases bool
+}
+
+func newHTTPProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	auth, err := authFromOptions(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("http provider: %w", err)
+	}
+	p := httpProvider{url: cfg.Option("url"), auth: auth, prereleases: cfg.Option("prereleases") == "true"}
+	if p.url == "" {
+		return nil, errors.New("http provider: url is required")
+	}
+	return p, nil
+}
+
+func (p httpProvider) Resolve(ctx context.Context, _ Release) (updater.Resolution, error) {
+	var body []byte
+	err := withRetry(ctx, "fetch versions from "+p.url, func() error {
+		var err error
+		body, err = providerGet(ctx, p.url, p.auth)
+		return err
+	})
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("versions endpoint: %w", err)
+	}
+	versions, err := parseVersionList(body)
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("versions endpoint: %w", err)
+	}
+	latest, ok := latestVersion(versions, p.prereleases)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("no semver-compatible versions at %s", p.url)
+	}
+	return updater.Resolution{LatestVersion: latest, Versions: updater.Published(versions)}, nil
+}
+
+// latestVersion picks the highest semver version, skipping prereleases unless asked to.
+func latestVersion(versions []string, prereleases bool) (string, bool) {
+	if !prereleases {
+		versions = stableVersions(versions)
+	}
+	return updater.LatestSemverTag(versions)
+}
+
+// stableVersions drops semver prereleases (1.2.0-rc.1).
+func stableVersions(versions []string) []string {
+	var out []string
+	for _, v := range versions {
+		parsed, err := semver.NewVersion(updater.NormalizeSemVer(v))
+		if err == nil && parsed.Prerelease() == "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseVersionList accepts ["1.0.0", ...], {"versions": [...]} or newline-separated text.
+func parseVersionList(body []byte) ([]string, error) {
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var versions []string
+		err := json.Unmarshal(trimmed, &versions)
+		return versions, err
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var doc struct {
+			Versions []string `json:"versions"`
+		}
+		err := json.Unmarshal(trimmed, &doc)
+		return doc.Versions, err
+	}
+	var versions []string
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		if v := strings.TrimSpace(scanner.Text()); v != "" && !strings.HasPrefix(v, "#") {
+			versions = append(versions, v)
+		}
+	}
+	return versions, scanner.Err()
+}
+
+// providerAuth holds the credentials a provider sends. Secrets are never read from the
+// config file itself: each of token, username, password and apiKey is named by a <key>Env,
+// <key>File or <key>Command option (see secretOption). The API key is only sent by providers
+// that set apiKeyHeader.
+type providerAuth struct {
+	token        string
+	username     string
+	password     string
+	apiKey       string
+	apiKeyHeader string
+}
+
+// authFromOptions reads the credential options; defaultTokenEnv is used when no token option is set.
+func authFromOptions(cfg updater.ProviderConfig, defaultTokenEnv string) (providerAuth, error) {
+	var a providerAuth
+	var err error
+	if a.token, err = secretOption(cfg, "token", defaultTokenEnv); err != nil {
+		return a, err
+	}
+	if a.username, err = secretOption(cfg, "username", ""); err != nil {
+		return a, err
+	}
+	if a.password, err = secretOption(cfg, "password", ""); err != nil {
+		return a, err
+	}
+	if a.apiKey, err = secretOption(cfg, "apiKey", ""); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+func (a providerAuth) apply(req *http.Request) {
+	switch {
+	case a.apiKey != "" && a.apiKeyHeader != "":
+		req.Header.Set(a.apiKeyHeader, a.apiKey)
+	case a.token != "":
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	case a.username != "" || a.password != "":
+		req.SetBasicAuth(a.username, a.password)
+	}
+}
+
+// providerGet performs an authenticated GET and returns the body of a 200 response.
+func providerGet(ctx context.Context, url string, auth providerAuth) ([]byte, error) {
+	body, _, err := providerGetPage(ctx, url, auth)
+	return body, err
+}
+
+// providerGetPage is providerGet for paginated APIs: it also returns the URL of the next
+// page from the Link header (rel="next"), or "" on the last page.
+func providerGetPage(ctx context.Context, url string, auth providerAuth) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "helmwave-updater/"+version)
+	auth.apply(req)
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GET %s: %s", url, resp.Status)
+		if !retryableStatus(resp.StatusCode) {
+			return nil, "", permanent(err)
+		}
+		return nil, "", err
+	}
+	body, err := io.ReadAll(resp.Body)
+	return body, nextPageLink(resp.Header.Values("Link")), err
+}
+
+// nextPageLink returns the rel="next" target of RFC 8288 Link header values.
+func nextPageLink(links []string) string {
+	for _, header := range links {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "rel") && slices.Contains(strings.Fields(strings.Trim(value, `"`)), "next") {
+					return strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedMask replaces credentials in log output, as url.URL.Redacted does for passwords.
+const redactedMask = "xxxxx"
+
+// knownSecrets are credential values seen during the run (tokens, repository and registry
+// passwords); they are masked wherever they show up in a log record or error.
+var knownSecrets = struct {
+	sync.Mutex
+	values []string
+}{}
+
+// registerSecret records credential values to mask. Very short values are ignored: masking
+// them would garble unrelated text.
+func registerSecret(values ...string) {
+	knownSecrets.Lock()
+	defer knownSecrets.Unlock()
+	for _, v := range values {
+		if len(v) < 6 || slices.Contains(knownSecrets.values, v) {
+			continue
+		}
+		knownSecrets.values = append(knownSecrets.values, v)
+		// longest first, so a secret containing another is masked whole
+		slices.SortFunc(knownSecrets.values, func(a, b string) int { return len(b) - len(a) })
+	}
+}
+
+// registerEnvSecrets records the credentials the tool reads from its environment: GitHub
+// tokens and proxy passwords.
+func registerEnvSecrets() {
+	registerSecret(os.Getenv("GITHUB_TOKEN"))
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		if u, err := url.Parse(os.Getenv(name)); err == nil && u.User != nil {
+			password, _ := u.User.Password()
+			registerSecret(password)
+		}
+	}
+}
+
+var (
+	// userinfoPattern matches scheme://user[:password]@ in URLs
+	userinfoPattern = regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://)([^\s/@:]+)(:[^\s/@]*)?@`)
+	// authHeaderPattern matches Bearer and Basic credentials, e.g. from a dumped Authorization header
+	authHeaderPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/]{8,}=*`)
+	// secretParamPattern matches credentials passed as query parameters
+	secretParamPattern = regexp.MustCompile(`(?i)([?&](?:access_token|token|password|api_key|apikey)=)[^&\s"']+`)
+)
+
+// redactSecrets masks credentials in s: passwords in URL userinfo (and the user of http(s)
+// URLs without one, which is how tokens are passed), Bearer/Basic credentials, secret
+// query parameters and every registered secret value.
+func redactSecrets(s string) string {
+	s = userinfoPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := userinfoPattern.FindStringSubmatch(m)
+		scheme, user, password := parts[1], parts[2], parts[3]
+		switch {
+		case password != "":
+			return scheme + user + ":" + redactedMask + "@"
+		case strings.HasPrefix(strings.ToLower(scheme), "http"):
+			return scheme + redactedMask + "@"
+		}
+		return m
+	})
+	s = authHeaderPattern.ReplaceAllString(s, "$1 "+redactedMask)
+	s = secretParamPattern.ReplaceAllString(s, "${1}"+redactedMask)
+	knownSecrets.Lock()
+	defer knownSecrets.Unlock()
+	for _, v := range knownSecrets.values {
+		s = strings.ReplaceAll(s, v, redactedMask)
+	}
+	return s
+}
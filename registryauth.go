@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v4/pkg/helmpath"
+	"helm.sh/helm/v4/pkg/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// registryConfig overrides the helm registry credentials file (-registry-config)
+var registryConfig string
+
+// Cloud registries whose short-lived tokens are obtained from the ambient cloud credentials.
+const (
+	cloudECR = "ecr"
+	cloudGCR = "gcr"
+	cloudACR = "acr"
+)
+
+// cloudTokenTTL is how long an exchanged token is reused, kept below its lifetime (ECR 12h,
+// gcloud access tokens 1h, ACR 3h) so a long-running controller never presents an expired one.
+var cloudTokenTTL = map[string]time.Duration{cloudECR: 11 * time.Hour, cloudGCR: 45 * time.Minute, cloudACR: 2 * time.Hour}
+
+// cloudToken is an exchanged registry token and when it stops being reused.
+type cloudToken struct {
+	cred    auth.Credential
+	expires time.Time
+}
+
+// cloudCredentials caches exchanged tokens per registry host. hosts serializes the exchange
+// per host, so concurrent lookups of one registry share a token while other hosts proceed.
+var cloudCredentials = struct {
+	sync.Mutex
+	tokens map[string]cloudToken
+	hosts  map[string]*sync.Mutex
+}{tokens: make(map[string]cloudToken), hosts: make(map[string]*sync.Mutex)}
+
+// resetCloudCredentials forgets the exchanged tokens; the controller starts every check with
+// fresh ones.
+func resetCloudCredentials() {
+	cloudCredentials.Lock()
+	defer cloudCredentials.Unlock()
+	clear(cloudCredentials.tokens)
+}
+
+// cachedCloudToken returns the unexpired token of host and the lock guarding its exchange.
+func cachedCloudToken(host string, now time.Time) (auth.Credential, bool, *sync.Mutex) {
+	cloudCredentials.Lock()
+	defer cloudCredentials.Unlock()
+	mu, ok := cloudCredentials.hosts[host]
+	if !ok {
+		mu = new(sync.Mutex)
+		cloudCredentials.hosts[host] = mu
+	}
+	t, ok := cloudCredentials.tokens[host]
+	if !ok || !now.Before(t.expires) {
+		return auth.EmptyCredential, false, mu
+	}
+	return t.cred, true, mu
+}
+
+// cloudRegistry classifies a registry host as ECR, GCR / Artifact Registry or ACR.
+func cloudRegistry(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case strings.Contains(host, ".dkr.ecr.") && (strings.HasSuffix(host, ".amazonaws.com") || strings.HasSuffix(host, ".amazonaws.com.cn")):
+		return cloudECR
+	case host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev"):
+		return cloudGCR
+	case strings.HasSuffix(host, ".azurecr.io"):
+		return cloudACR
+	}
+	return ""
+}
+
+// registryCredential looks up credentials in the helm and docker stores (including their
+// configured credential helpers) and, for cloud registries without stored credentials,
+// falls back to cloudCredential.
+func registryCredential(store credentials.Store) auth.CredentialFunc {
+	stored := credentials.Credential(store)
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		cred, err := stored(ctx, hostport)
+		if err == nil && cred != auth.EmptyCredential {
+			registerSecret(cred.Password, cred.RefreshToken, cred.AccessToken)
+			logDebugf("using stored registry credentials for %s", hostport)
+			return cred, nil
+		}
+		if cloudRegistry(hostport) == "" {
+			return cred, err
+		}
+		return cloudCredential(ctx, hostport)
+	}
+}
+
+// cloudCredential returns the token of a cloud registry, exchanged by exchangeCloudToken
+// and reused until cloudTokenTTL passes. A failure is logged and yields anonymous access, so
+// public repositories keep working; it is not cached, so the next lookup tries again.
+func cloudCredential(ctx context.Context, host string) (auth.Credential, error) {
+	cred, ok, mu := cachedCloudToken(host, time.Now())
+	if ok {
+		return cred, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// another lookup may have exchanged the token while this one waited
+	if cred, ok, _ := cachedCloudToken(host, time.Now()); ok {
+		return cred, nil
+	}
+
+	kind := cloudRegistry(host)
+	cred, err := exchangeCloudToken(ctx, kind, host)
+	if err != nil {
+		logWarnf("⚠️ no %s credentials for %s, trying anonymous access: %v", strings.ToUpper(kind), host, err)
+		return auth.EmptyCredential, nil
+	}
+	registerSecret(cred.Password)
+	cloudCredentials.Lock()
+	cloudCredentials.tokens[host] = cloudToken{cred: cred, expires: time.Now().Add(cloudTokenTTL[kind])}
+	cloudCredentials.Unlock()
+	return cred, nil
+}
+
+// exchangeCloudToken obtains a registry token from the well-known docker credential helper
+// for the cloud, or else by token exchange through the cloud CLI (aws, gcloud, az).
+func exchangeCloudToken(ctx context.Context, kind, host string) (auth.Credential, error) {
+	helper := map[string]string{cloudECR: "ecr-login", cloudGCR: "gcr", cloudACR: "acr-env"}[kind]
+	cred, err := credentials.NewNativeStore(helper).Get(ctx, host)
+	if err == nil && cred != auth.EmptyCredential {
+		return cred, nil
+	}
+	logDebugf("docker-credential-%s unavailable for %s: %v", helper, host, err)
+	return cloudCLICredential(ctx, kind, host)
+}
+
+// cloudCLICredential exchanges the CLI's ambient login for a registry token.
+func cloudCLICredential(ctx context.Context, kind, host string) (auth.Credential, error) {
+	var username string
+	var cmd *exec.Cmd
+	switch kind {
+	case cloudECR:
+		// <account>.dkr.ecr.<region>.amazonaws.com
+		labels := strings.Split(host, ".")
+		if len(labels) < 4 {
+			return auth.EmptyCredential, fmt.Errorf("unexpected ECR host %q", host)
+		}
+		username = "AWS"
+		cmd = exec.CommandContext(ctx, "aws", "ecr", "get-login-password", "--region", labels[3])
+	case cloudGCR:
+		username = "oauth2accesstoken"
+		cmd = exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	case cloudACR:
+		username = "00000000-0000-0000-0000-000000000000"
+		cmd = exec.CommandContext(ctx, "az", "acr", "login", "--name", strings.Split(host, ".")[0],
+			"--expose-token", "--output", "tsv", "--query", "accessToken")
+	default:
+		return auth.EmptyCredential, fmt.Errorf("%s is not a cloud registry", host)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return auth.EmptyCredential, fmt.Errorf("%s: %w: %s", strings.Join(cmd.Args[:3], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return auth.EmptyCredential, fmt.Errorf("%s returned no token", cmd.Args[0])
+	}
+	return auth.Credential{Username: username, Password: token}, nil
+}
+
+// registryConfigPath returns the credentials file written by `helm registry login`:
+// -registry-config, else $HELM_REGISTRY_CONFIG, else helm's default location.
+func registryConfigPath() string {
+	return firstNonEmpty(registryConfig, os.Getenv("HELM_REGISTRY_CONFIG"), helmpath.ConfigPath(registry.CredentialsFileBasename))
+}
+
+// registryStore combines the helm registry credentials with docker's ($DOCKER_CONFIG or
+// ~/.docker/config.json) as fallback, as helm does. An unreadable file is skipped with a
+// warning instead of failing every OCI lookup.
+func registryStore() credentials.Store {
+	storeOptions := credentials.StoreOptions{AllowPlaintextPut: true, DetectDefaultNativeStore: true}
+	var stores []credentials.Store
+	if helmStore, err := credentials.NewStore(registryConfigPath(), storeOptions); err != nil {
+		logWarnf("⚠️ ignoring helm registry config %s: %v", registryConfigPath(), err)
+	} else {
+		stores = append(stores, helmStore)
+	}
+	if dockerStore, err := credentials.NewStoreFromDocker(storeOptions); err != nil {
+		logWarnf("⚠️ ignoring docker config: %v", err)
+	} else {
+		stores = append(stores, dockerStore)
+	}
+	if len(stores) == 0 {
+		return credentials.NewMemoryStore()
+	}
+	return credentials.NewStoreWithFallbacks(stores[0], stores[1:]...)
+}
+
+// runRegistryStore loads the credential stores once per run, however many registry
+// connections are created.
+var runRegistryStore = sync.OnceValue(registryStore)
+
+// newRegistryAuthorizer builds the authorizing client for OCI lookups: stored registry
+// logins (registryStore) plus cloud token exchange.
+func newRegistryAuthorizer(httpClient *http.Client) *auth.Client {
+	client := &auth.Client{
+		Client:     httpClient,
+		Cache:      auth.NewCache(),
+		Credential: registryCredential(runRegistryStore()),
+	}
+	client.SetUserAgent("helmwave-updater/" + version)
+	return client
+}
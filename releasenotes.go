@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// releaseNotes attaches the upstream release notes of appVersion jumps to updates (-release-notes)
+var releaseNotes bool
+
+// releaseNoteLines bounds the summary of each release note.
+const releaseNoteLines = 5
+
+type githubReleaseNote struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+	Draft   bool   `json:"draft"`
+}
+
+// fetchReleaseNotes lists the published releases of a GitHub project (owner/name) with their
+// notes, as paged by the API (newest first). GITHUB_TOKEN raises the API rate limit.
+func fetchReleaseNotes(ctx context.Context, project string) ([]updater.ReleaseNote, error) {
+	apiURL := strings.TrimSuffix(firstNonEmpty(os.Getenv("GITHUB_API_URL"), githubAPIURL), "/")
+	auth := providerAuth{token: githubToken()}
+	var notes []updater.ReleaseNote
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", apiURL, project)
+	for page := 0; url != "" && page < githubMaxPages; page++ {
+		var batch []githubReleaseNote
+		var next string
+		err := withRetry(ctx, "list GitHub releases of "+project, func() error {
+			body, link, err := providerGetPage(ctx, url, auth)
+			if err != nil {
+				return err
+			}
+			batch, next = nil, link
+			return permanent(json.Unmarshal(body, &batch))
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range batch {
+			if !r.Draft {
+				notes = append(notes, updater.ReleaseNote{Version: r.TagName, URL: r.HTMLURL, Summary: r.Body})
+			}
+		}
+		url = next
+	}
+	return notes, nil
+}
+
+// appReleaseNotes returns the condensed notes of the application versions after
+// currentAppVersion up to latestAppVersion, from the GitHub project the chart's home or
+// sources point to. It returns nothing when the appVersion did not move or the chart names
+// no GitHub project.
+func (s *providerSet) appReleaseNotes(ctx context.Context, sources []string, currentAppVersion, latestAppVersion string) ([]updater.ReleaseNote, error) {
+	if currentAppVersion == "" || latestAppVersion == "" || currentAppVersion == latestAppVersion {
+		return nil, nil
+	}
+	project, ok := updater.GitHubProject(sources)
+	if !ok {
+		logDebugf("no GitHub project among the chart sources %v; no release notes", sources)
+		return nil, nil
+	}
+	// releases sharing the project share its release list
+	notes, _, err := s.releaseNotes.do(project, func() ([]updater.ReleaseNote, error) {
+		return fetchReleaseNotes(ctx, project)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("release notes of %s: %w", project, err)
+	}
+	between := updater.NotesBetween(notes, currentAppVersion, latestAppVersion)
+	for i := range between {
+		between[i].Summary = updater.CondenseNotes(between[i].Summary, releaseNoteLines)
+	}
+	logDebugf("%d release notes of %s between %s and %s", len(between), project, currentAppVersion, latestAppVersion)
+	return between, nil
+}
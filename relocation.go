@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/cli"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// chartLocations lists the other repositories publishing chartName: the loaded indexes, and
+// helm's <repo>-charts.txt files for repositories whose loaded index has no entry for it.
+// Indexes are loaded with the referenced charts only, so a missing entry proves nothing.
+func chartLocations(indexes map[string]*repo.IndexFile, repoName, chartName string) []string {
+	found := make(map[string]bool)
+	for name, idx := range indexes {
+		if name == repoName || idx == nil {
+			continue
+		}
+		if versions := idx.Entries[chartName]; len(versions) > 0 && (versions[0].Metadata == nil || !versions[0].Deprecated) {
+			found[name] = true
+		}
+	}
+	if indexDir == "" {
+		lists, _ := filepath.Glob(filepath.Join(cli.New().RepositoryCache, "*-charts.txt"))
+		for _, list := range lists {
+			name := strings.TrimSuffix(filepath.Base(list), "-charts.txt")
+			if name == repoName || found[name] {
+				continue
+			}
+			// a loaded entry decides, e.g. when the chart is deprecated there
+			if idx := indexes[name]; idx != nil && len(idx.Entries[chartName]) > 0 {
+				continue
+			}
+			data, err := os.ReadFile(list)
+			if err == nil && slices.Contains(strings.Split(string(data), "\n"), chartName) {
+				found[name] = true
+			}
+		}
+	}
+	locations := make([]string, 0, len(found))
+	for name := range found {
+		locations = append(locations, name+"/"+chartName)
+	}
+	sort.Strings(locations)
+	return locations
+}
+
+// relocationHint suggests new coordinates for a chart that left repoName or was deprecated
+// there (e.g. the stable → bitnami migration): the deprecation notice's pointer first,
+// then other repositories with the same chart. It is empty when there is nothing to suggest.
+func relocationHint(indexes map[string]*repo.IndexFile, repoName, chartName, movedTo string) string {
+	var candidates []string
+	if movedTo != "" {
+		candidates = append(candidates, movedTo)
+	}
+	if chartName != "" {
+		for _, c := range chartLocations(indexes, repoName, chartName) {
+			if c != movedTo {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	return "; it may have moved to " + strings.Join(candidates, ", ")
+}
+
+// missingChartHint is relocationHint for a resolution error that reports a chart missing
+// from its index; it is empty for other errors.
+func missingChartHint(indexes map[string]*repo.IndexFile, err error) string {
+	var missing *updater.ChartMissingError
+	if !errors.As(err, &missing) {
+		return ""
+	}
+	return relocationHint(indexes, missing.Repo, missing.Chart, "")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// reportJSON is the path of the JSON report (-report-json); "-" writes it to stdout.
+var reportJSON string
+
+// reportSchemaVersion is the schemaVersion of jsonReport. Fields are only added within a
+// version; removing, renaming or retyping one bumps it (see report.schema.json).
+const reportSchemaVersion = 1
+
+// reportSchema is the JSON Schema of jsonReport (-report-schema).
+//
+//go:embed report.schema.json
+var reportSchema []byte
+
+// jsonReport is the machine-readable outcome of a check run. -output yaml renders the same
+// report with the same keys.
+type jsonReport struct {
+	SchemaVersion int          `json:"schemaVersion" yaml:"schemaVersion"`
+	ToolVersion   string       `json:"toolVersion,omitempty" yaml:"toolVersion,omitempty"`
+	Summary       jsonSummary  `json:"summary" yaml:"summary"`
+	Updates       []jsonUpdate `json:"updates" yaml:"updates"`
+	Skipped       []jsonReason `json:"skipped" yaml:"skipped"`
+	Failed        []jsonReason `json:"failed" yaml:"failed"`
+	// NextCommand is the helmwave command that deploys the updated releases
+	NextCommand string `json:"nextCommand,omitempty" yaml:"nextCommand,omitempty"`
+}
+
+type jsonSummary struct {
+	Checked  int `json:"checked" yaml:"checked"`
+	UpToDate int `json:"upToDate" yaml:"upToDate"`
+	Updated  int `json:"updated" yaml:"updated"`
+	Skipped  int `json:"skipped" yaml:"skipped"`
+	Failed   int `json:"failed" yaml:"failed"`
+}
+
+type jsonUpdate struct {
+	Release           string   `json:"release" yaml:"release"`
+	Namespace         string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Context           string   `json:"context,omitempty" yaml:"context,omitempty"`
+	Chart             string   `json:"chart" yaml:"chart"`
+	FromVersion       string   `json:"fromVersion" yaml:"fromVersion"`
+	ToVersion         string   `json:"toVersion" yaml:"toVersion"`
+	CurrentAppVersion string   `json:"currentAppVersion,omitempty" yaml:"currentAppVersion,omitempty"`
+	LatestAppVersion  string   `json:"latestAppVersion,omitempty" yaml:"latestAppVersion,omitempty"`
+	Importance        string   `json:"importance" yaml:"importance"`
+	Digest            string   `json:"digest,omitempty" yaml:"digest,omitempty"`
+	File              string   `json:"file,omitempty" yaml:"file,omitempty"`
+	Tags              []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// MaintainersRemoved and MaintainersAdded flag a significant maintainer change
+	MaintainersRemoved []string `json:"maintainersRemoved,omitempty" yaml:"maintainersRemoved,omitempty"`
+	MaintainersAdded   []string `json:"maintainersAdded,omitempty" yaml:"maintainersAdded,omitempty"`
+	// FromLicense and ToLicense flag an update allowed by -allow-license-change
+	FromLicense string `json:"fromLicense,omitempty" yaml:"fromLicense,omitempty"`
+	ToLicense   string `json:"toLicense,omitempty" yaml:"toLicense,omitempty"`
+	// ReleaseNotes are the condensed notes of the appVersions between the two (-release-notes)
+	ReleaseNotes []jsonReleaseNote `json:"releaseNotes,omitempty" yaml:"releaseNotes,omitempty"`
+}
+
+type jsonReleaseNote struct {
+	Version string `json:"version" yaml:"version"`
+	URL     string `json:"url,omitempty" yaml:"url,omitempty"`
+	Summary string `json:"summary,omitempty" yaml:"summary,omitempty"`
+}
+
+// jsonReason is a skipped or failed release; code is one of the updater.Reason* codes.
+type jsonReason struct {
+	Release   string `json:"release" yaml:"release"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Context   string `json:"context,omitempty" yaml:"context,omitempty"`
+	Chart     string `json:"chart" yaml:"chart"`
+	Code      string `json:"code" yaml:"code"`
+	Reason    string `json:"reason" yaml:"reason"`
+}
+
+func newJSONReport(c checkResult) jsonReport {
+	r := jsonReport{
+		SchemaVersion: reportSchemaVersion,
+		ToolVersion:   version,
+		Summary: jsonSummary{
+			Checked:  len(c.Releases),
+			UpToDate: c.Count(statusUpToDate),
+			Updated:  c.Count(statusUpdated),
+			Skipped:  c.Count(statusSkipped),
+			Failed:   c.Count(statusFailed),
+		},
+		Updates: []jsonUpdate{},
+		Skipped: []jsonReason{},
+		Failed:  []jsonReason{},
+	}
+	for _, rel := range c.Releases {
+		switch rel.Status {
+		case statusUpdated:
+			u := rel.Update
+			r.Updates = append(r.Updates, jsonUpdate{
+				Release: u.Release, Namespace: u.Namespace, Context: u.Context, Chart: u.Chart,
+				FromVersion: u.FromVersion, ToVersion: u.ToVersion,
+				CurrentAppVersion: u.CurrentAppVersion, LatestAppVersion: u.LatestAppVersion,
+				Importance: u.Importance, Digest: u.Digest, File: u.File, Tags: u.Tags,
+				MaintainersRemoved: u.MaintainersRemoved, MaintainersAdded: u.MaintainersAdded,
+				FromLicense: u.FromLicense, ToLicense: u.ToLicense,
+				ReleaseNotes: newJSONReleaseNotes(u.ReleaseNotes),
+			})
+		case statusSkipped:
+			r.Skipped = append(r.Skipped, newJSONReason(rel))
+		case statusFailed:
+			r.Failed = append(r.Failed, newJSONReason(rel))
+		}
+	}
+	// an invalid -tags-export mode is reported by the run itself
+	r.NextCommand, _ = suggestedCommand(c.Updates())
+	return r
+}
+
+func newJSONReleaseNotes(notes []updater.ReleaseNote) []jsonReleaseNote {
+	var out []jsonReleaseNote
+	for _, n := range notes {
+		out = append(out, jsonReleaseNote{Version: n.Version, URL: n.URL, Summary: n.Summary})
+	}
+	return out
+}
+
+func newJSONReason(r releaseResult) jsonReason {
+	return jsonReason{Release: r.Release, Namespace: r.Namespace, Context: r.Context, Chart: r.Chart, Code: r.Code, Reason: r.Reason}
+}
+
+// writeJSONReport writes the report of c to path ("-" for stdout).
+func writeJSONReport(path string, c checkResult) error {
+	data, err := json.MarshalIndent(newJSONReport(c), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return nil
+}
+
+// reasonLabels describe the reason codes in the summary.
+var reasonLabels = map[string]string{
+	updater.ReasonNoupdate:     "noupdate tag",
+	updater.ReasonLocalChart:   "local chart",
+	updater.ReasonOffline:      "offline mode",
+	updater.ReasonNoVersion:    "no chart version",
+	updater.ReasonConstraint:   "version constraint",
+	updater.ReasonCooldown:     "cooldown",
+	updater.ReasonStep:         "no version list for -step",
+	updater.ReasonLimit:        "held back by -limit",
+	updater.ReasonPolicy:       "repo policy",
+	updater.ReasonChannel:      "release channel",
+	updater.ReasonChartName:    "chart name",
+	updater.ReasonSecretRef:    "vals secret reference",
+	updater.ReasonTemplated:    "templated version",
+	updater.ReasonLicense:      "license change",
+	updater.ReasonPolicyDeny:   "denied by policy command",
+	updater.ReasonPolicyHold:   "held by policy command",
+	updater.ReasonNoIndex:      "missing index",
+	updater.ReasonChartMissing: "chart not in index",
+	updater.ReasonError:        "error",
+}
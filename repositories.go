@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/cli"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// helmwaveRepositories are the entries of the helmwave file's repositories block
+// (with env references expanded); they are merged with helm's repositories.yaml.
+var helmwaveRepositories []*repo.Entry
+
+// repoEntries returns helm's configured repositories merged with the helmwave repositories:
+// helmwave credentials fill in missing helm credentials, helmwave-only repos are appended.
+func repoEntries(settings *cli.EnvSettings) ([]*repo.Entry, error) {
+	var entries []*repo.Entry
+	f, err := repo.LoadFile(settings.RepositoryConfig)
+	switch {
+	case err == nil:
+		entries = f.Repositories
+	case errors.Is(err, fs.ErrNotExist) && len(helmwaveRepositories) > 0:
+		logDebugf("no helm repo config at %s; using helmwave repositories only", settings.RepositoryConfig)
+	default:
+		return nil, err
+	}
+
+	byName := make(map[string]*repo.Entry, len(entries))
+	for _, e := range entries {
+		byName[e.Name] = e
+	}
+	for _, hwEntry := range helmwaveRepositories {
+		existing, ok := byName[hwEntry.Name]
+		if !ok {
+			logDebugf("using repository %s (%s) from helmwave file", hwEntry.Name, hwEntry.URL)
+			entries = append(entries, hwEntry)
+			byName[hwEntry.Name] = hwEntry
+			continue
+		}
+		if existing.Username == "" && existing.Password == "" {
+			existing.Username, existing.Password = hwEntry.Username, hwEntry.Password
+			existing.PassCredentialsAll = existing.PassCredentialsAll || hwEntry.PassCredentialsAll
+		}
+		if existing.CertFile == "" && existing.KeyFile == "" {
+			existing.CertFile, existing.KeyFile = hwEntry.CertFile, hwEntry.KeyFile
+		}
+		if existing.CAFile == "" {
+			existing.CAFile = hwEntry.CAFile
+		}
+		existing.InsecureSkipTLSVerify = existing.InsecureSkipTLSVerify || hwEntry.InsecureSkipTLSVerify
+	}
+
+	for _, e := range entries {
+		registerSecret(e.Password)
+		if insecureRepos[e.Name] && !e.InsecureSkipTLSVerify {
+			logDebugf("repo %s: skipping TLS verification (insecureskiptlsverify set on a chart)", e.Name)
+			e.InsecureSkipTLSVerify = true
+		}
+	}
+	return entries, nil
+}
+
+// insecureRepos lists repos whose charts set insecureskiptlsverify in the helmwave file.
+var insecureRepos map[string]bool
+
+// collectInsecureRepos records repos referenced by charts with insecureskiptlsverify: true.
+func collectInsecureRepos(hw *Helmwave) {
+	insecureRepos = make(map[string]bool)
+	for _, r := range hw.Releases {
+		if !chartTLSOptions(r.Chart).Insecure {
+			continue
+		}
+		if repoName, _, ok := updater.SplitRepoChart(r.Chart.Name); ok {
+			insecureRepos[repoName] = true
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// Check results are defined in pkg/updater.
+type (
+	releaseStatus = updater.Status
+	releaseUpdate = updater.ReleaseUpdate
+	releaseResult = updater.ReleaseResult
+	checkResult   = updater.CheckResult
+)
+
+const (
+	statusUpdated  = updater.StatusUpdated
+	statusUpToDate = updater.StatusUpToDate
+	statusSkipped  = updater.StatusSkipped
+	statusFailed   = updater.StatusFailed
+)
+
+var (
+	updatedResult  = updater.Updated
+	upToDateResult = updater.UpToDate
+	skippedResult  = updater.Skipped
+	failedResult   = updater.Failed
+)
+
+// printSummary prints the end-of-run counters and the skipped/failed releases with reasons.
+func printSummary(w io.Writer, c checkResult) {
+	if quiet || outputFormat != outputText {
+		return
+	}
+	contexts := c.Contexts()
+	if len(contexts) <= 1 {
+		fmt.Fprintf(w, tr("\nSummary: %d releases checked\n"), len(c.Releases))
+		printCounts(w, c)
+	} else {
+		fmt.Fprintf(w, tr("\nSummary: %d releases checked in %d kube contexts\n"), len(c.Releases), len(contexts))
+		for _, kc := range contexts {
+			in := c.InContext(kc)
+			fmt.Fprintf(w, tr("\nContext %s: %d releases\n"), contextLabel(kc), len(in.Releases))
+			printCounts(w, in)
+		}
+	}
+
+	printReasons(w, tr("\nSkipped releases:\n"), c.ByReason(statusSkipped), len(contexts) > 1)
+	printReasons(w, tr("\nFailed releases:\n"), c.ByReason(statusFailed), len(contexts) > 1)
+}
+
+// printReasons prints skipped or failed releases under heading, grouped by reason code.
+func printReasons(w io.Writer, heading string, groups []updater.ReasonGroup, withContext bool) {
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprint(w, heading)
+	for _, g := range groups {
+		fmt.Fprintf(w, "   %s (%d):\n", firstNonEmpty(tr(reasonLabels[g.Code]), g.Code, tr("other")), len(g.Releases))
+		for _, r := range g.Releases {
+			name := r.Release
+			if withContext {
+				name += " (" + contextLabel(r.Context) + ")"
+			}
+			fmt.Fprintf(w, "      - %s: %s\n", name, r.Reason)
+		}
+	}
+}
+
+// contextLabel names a kube context in reports; releases without one use the current context.
+func contextLabel(name string) string {
+	if name == "" {
+		return tr("(current)")
+	}
+	return name
+}
+
+// printCounts prints the status and importance counters of c.
+func printCounts(w io.Writer, c checkResult) {
+	byImportance := make(map[string]int)
+	for _, u := range c.Updates() {
+		byImportance[u.Importance]++
+	}
+	fmt.Fprintf(w, tr("   up-to-date: %d\n"), c.Count(statusUpToDate))
+	fmt.Fprintf(w, tr("   updates:    %d (%s major, %s minor, %s patch"),
+		c.Count(statusUpdated),
+		colorize(colorRed, fmt.Sprint(byImportance["major"])),
+		colorize(colorYellow, fmt.Sprint(byImportance["minor"])),
+		colorize(colorGreen, fmt.Sprint(byImportance["patch"])),
+	)
+	if other := byImportance["none"] + byImportance["unknown"]; other > 0 {
+		fmt.Fprintf(w, tr(", %d other"), other)
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintf(w, tr("   skipped:    %d\n"), c.Count(statusSkipped))
+	fmt.Fprintf(w, tr("   failed:     %d\n"), c.Count(statusFailed))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// retries is the number of additional attempts for failed network fetches (-retries)
+var retries = 3
+
+// retryBackoff is the delay before the first retry; it doubles on every attempt (-retry-backoff)
+var retryBackoff = time.Second
+
+// maxRetryBackoff caps the exponential delay
+const maxRetryBackoff = 30 * time.Second
+
+// permanentError marks a failure that retrying cannot fix, such as a 404 or a malformed response.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent wraps err so that withRetry gives up immediately. It returns nil for a nil err.
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retryableStatus reports whether an HTTP status may succeed on retry: server errors,
+// 408 Request Timeout and 429 Too Many Requests. Other client errors are permanent.
+func retryableStatus(code int) bool {
+	return code < 400 || code >= 500 || code == 408 || code == 429
+}
+
+// withRetry runs fn until it succeeds, fails permanently, retries are exhausted or ctx is done.
+// Delays grow exponentially from retryBackoff with ±20% jitter.
+func withRetry(ctx context.Context, op string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = runWithContext(ctx, fn); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if perm := (*permanentError)(nil); errors.As(err, &perm) {
+			return err
+		}
+		if attempt >= retries {
+			return err
+		}
+
+		wait := jitter(backoff(attempt))
+		logDebugf("%s failed (attempt %d/%d): %v; retrying in %s", op, attempt+1, retries+1, err, wait)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff returns the delay before retry attempt+1, without jitter.
+func backoff(attempt int) time.Duration {
+	delay := retryBackoff
+	for range attempt {
+		delay *= 2
+		if delay >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	spread := int64(d) / 5
+	if spread == 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*spread)-spread)
+}
+
+// runWithContext runs fn (typically a helm call without context support) and returns early
+// with ctx.Err() when ctx is cancelled; the abandoned call finishes in the background.
+func runWithContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
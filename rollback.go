@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+func runRollback(args []string) {
+	if err := rollback(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitUpToDate)
+		}
+		logErrorf("rollback: %v", err)
+		os.Exit(exitFatal)
+	}
+}
+
+// rollback reverts version fields changed by the chosen run (and every later run against
+// the same file) back to the values recorded as oldVersion in the audit log.
+func rollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	logPath := fs.String("audit-log", "", "audit log written by -audit-log (required)")
+	runID := fs.String("run", "", "run ID to roll back (defaults to the last run in the audit log)")
+	file := fs.String("file", "", "helmwave file to revert (defaults to the output file recorded in the audit log)")
+	dryRun := fs.Bool("dry-run", false, "print the reverted file to stdout instead of writing it")
+	addLoggingFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyLoggingFlags(fs); err != nil {
+		return err
+	}
+	if *logPath == "" {
+		return errors.New("-audit-log is required")
+	}
+
+	entries, err := readAuditLog(*logPath)
+	if err != nil {
+		return err
+	}
+	plan, err := planRollback(entries, *runID, *file)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, *logPath)
+	}
+	for _, e := range plan.reverted {
+		logInfof("reverting release %s: %s -> %s (run %s)", e.Release, e.NewVersion, e.OldVersion, e.RunID)
+	}
+	for _, other := range plan.others {
+		logWarnf("⚠️ run %s also edited %s; roll it back with -file %s", plan.reverted[0].RunID, other, other)
+	}
+
+	data, err := os.ReadFile(plan.outFile)
+	if err != nil {
+		return err
+	}
+	out := updater.UpdateText(data, plan.versions, plan.chartVersions)
+	out = updater.UpdateChartNames([]byte(out), plan.chartNames)
+	// a "# was" comment from -annotate describes the update being reverted, an appVersion
+	// comment its chart version
+	out = updater.RemoveAnnotations(string(data), out)
+	if *dryRun {
+		fmt.Print(out)
+		return nil
+	}
+	return writeOutput(plan.outFile, out)
+}
+
+// rollbackPlan is what a rollback writes back, and where.
+type rollbackPlan struct {
+	outFile       string
+	versions      map[string]string
+	chartVersions map[string]string
+	// git-sourced charts are reverted by pointing the ref in chart.name back
+	chartNames map[string]string
+	reverted   []auditEntry
+	// others are further files the chosen run edited (included files); each is rolled
+	// back on its own with -file
+	others []string
+}
+
+// planRollback selects the entries of runID (default: the last run) and of every later run
+// against the same file, and maps each release back to its value before runID. Without
+// file, the file is the one the chosen run edited.
+func planRollback(entries []auditEntry, runID, file string) (rollbackPlan, error) {
+	if file != "" {
+		entries = filterAuditEntries(entries, file)
+	}
+	if len(entries) == 0 {
+		return rollbackPlan{}, errors.New("no entries to roll back")
+	}
+	if runID == "" {
+		runID = entries[len(entries)-1].RunID
+	}
+	start := slices.IndexFunc(entries, func(e auditEntry) bool { return e.RunID == runID })
+	if start < 0 {
+		return rollbackPlan{}, fmt.Errorf("run %q not found", runID)
+	}
+	first := entries[start]
+
+	plan := rollbackPlan{
+		outFile:       firstNonEmpty(file, first.Output),
+		versions:      make(map[string]string),
+		chartVersions: make(map[string]string),
+		chartNames:    make(map[string]string),
+	}
+	// the first entry per release/chart at or after the chosen run holds the value before that run
+	seen := make(map[string]bool)
+	for _, e := range entries[start:] {
+		if file == "" && (e.File != first.File || e.Output != first.Output) {
+			if e.RunID == runID && !slices.Contains(plan.others, e.Output) {
+				plan.others = append(plan.others, e.Output)
+			}
+			continue
+		}
+		id := updater.ReleaseID(e.Release, e.Namespace, e.Context)
+		if !seen[id] {
+			seen[id] = true
+			if g, ok := updater.ParseGitChart(e.Chart); ok {
+				plan.chartNames[id] = g.WithRef(e.OldVersion)
+			} else {
+				plan.versions[id] = e.OldVersion
+				// a digest pin is restored together with the version
+				if _, digest := updater.SplitOCIDigest(e.Chart); digest != "" {
+					plan.chartNames[id] = e.Chart
+				}
+			}
+			plan.reverted = append(plan.reverted, e)
+		}
+		if _, ok := plan.chartVersions[e.Chart]; !ok {
+			plan.chartVersions[e.Chart] = e.OldVersion
+		}
+	}
+	return plan, nil
+}
+
+func readAuditLog(path string) ([]auditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func filterAuditEntries(entries []auditEntry, file string) []auditEntry {
+	out := entries[:0:0]
+	for _, e := range entries {
+		if e.File == file || e.Output == file {
+			out = append(out, e)
+		}
+	}
+	return out
+}
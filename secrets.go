@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// secretCommandTimeout bounds a <key>Command credential helper.
+const secretCommandTimeout = 30 * time.Second
+
+// secretCommands caches the output of credential commands for the rest of the process, so
+// that sources sharing a helper run it once.
+var secretCommands = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// secretEnv reads a credential from the environment variable name or, when that is unset,
+// from the file named by name_FILE (the Docker and Kubernetes secrets convention).
+func secretEnv(name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		registerSecret(v)
+		return v, nil
+	}
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		return secretFile(path)
+	}
+	return "", nil
+}
+
+// secretFile reads a credential from a file; surrounding whitespace (a trailing newline) is dropped.
+func secretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	v := strings.TrimSpace(string(data))
+	registerSecret(v)
+	return v, nil
+}
+
+// secretCommand runs command with sh and returns its trimmed stdout as the credential.
+func secretCommand(command string) (string, error) {
+	secretCommands.Lock()
+	defer secretCommands.Unlock()
+	if v, ok := secretCommands.m[command]; ok {
+		return v, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret command %q: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	v := strings.TrimSpace(string(out))
+	if v == "" {
+		return "", fmt.Errorf("secret command %q printed nothing", command)
+	}
+	registerSecret(v)
+	secretCommands.m[command] = v
+	return v, nil
+}
+
+// secretOption resolves the credential key (token, username, password, apiKey) of a source
+// config from <key>Env (with its _FILE fallback), <key>File or <key>Command, in that order;
+// defaultEnv is used when none is set. The config file itself never holds the value.
+func secretOption(cfg updater.ProviderConfig, key, defaultEnv string) (string, error) {
+	env, file, command := cfg.Option(key+"Env"), cfg.Option(key+"File"), cfg.Option(key+"Command")
+	var v string
+	var err error
+	switch {
+	case env != "":
+		v, err = secretEnv(env)
+	case file != "":
+		v, err = secretFile(file)
+	case command != "":
+		v, err = secretCommand(command)
+	case defaultEnv != "":
+		v, err = secretEnv(defaultEnv)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	return v, nil
+}
+
+// githubToken returns GITHUB_TOKEN (or the file named by GITHUB_TOKEN_FILE); an unreadable
+// file is logged and yields unauthenticated requests.
+func githubToken() string {
+	token, err := secretEnv("GITHUB_TOKEN")
+	if err != nil {
+		logWarnf("⚠️ GITHUB_TOKEN: %v", err)
+	}
+	return token
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"runtime"
 	"strings"
 )
 
-const githubReleaseURL = "https://api.github.com/repos/Sovigod/helmwave-updater/releases/latest"
+// githubReleaseURL is the latest release of this tool, for self-update and -check-update.
+var githubReleaseURL = "https://api.github.com/repos/Sovigod/helmwave-updater/releases/latest"
+
+// checksumsAssetName is the sha256sum-formatted checksum file published with every release
+const checksumsAssetName = "checksums.txt"
 
 type githubRelease struct {
 	TagName string        `json:"tag_name"`
@@ -23,21 +31,33 @@ type githubAsset struct {
 	BrowserDownloadURL string `json:"browser_download_url"`
 }
 
-func runSelfUpdate(currentVersion string) {
-	if err := selfUpdate(currentVersion); err != nil {
-		log.Fatalf("self-update: %v", err)
+func runSelfUpdate(currentVersion string, args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	skipChecksum := fs.Bool("skip-checksum", false, "install even if the release publishes no checksum for the binary")
+	addLoggingFlags(fs)
+	parseFlags(fs, args)
+	if err := applyLoggingFlags(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := selfUpdate(ctx, currentVersion, *skipChecksum); err != nil {
+		logErrorf("self-update: %v", err)
+		os.Exit(exitFatal)
 	}
 }
 
-func selfUpdate(currentVersion string) error {
-	log.Println("fetching latest release from GitHub...")
-	release, err := fetchLatestRelease()
+func selfUpdate(ctx context.Context, currentVersion string, skipChecksum bool) error {
+	logInfof("fetching latest release from GitHub...")
+	release, err := fetchLatestRelease(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch release info: %w", err)
 	}
 
 	latestTag := release.TagName
-	log.Printf("current: %s, latest: %s", currentVersion, latestTag)
+	logInfof("current: %s, latest: %s", currentVersion, latestTag)
 
 	if currentVersion != "dev" && strings.TrimPrefix(currentVersion, "v") == strings.TrimPrefix(latestTag, "v") {
 		fmt.Println("already up to date")
@@ -45,22 +65,42 @@ func selfUpdate(currentVersion string) error {
 	}
 
 	assetName := fmt.Sprintf("helmwave-updater-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName += ".exe"
+	}
 	downloadURL, err := findAssetURL(release, assetName)
 	if err != nil {
 		return fmt.Errorf("no binary for %s/%s in release %s: %w", runtime.GOOS, runtime.GOARCH, latestTag, err)
 	}
 
+	expectedSum, err := releaseChecksum(ctx, release, assetName)
+	if err != nil {
+		if !skipChecksum {
+			return fmt.Errorf("cannot verify %s: %w (use -skip-checksum to install anyway)", assetName, err)
+		}
+		logWarnf("⚠️ installing without checksum verification: %v", err)
+	}
+
 	exePath, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to resolve executable path: %w", err)
 	}
 
 	tmpPath := exePath + ".new"
-	log.Printf("downloading %s...", latestTag)
-	if err := downloadBinary(downloadURL, tmpPath); err != nil {
+	logInfof("downloading %s...", latestTag)
+	if err := downloadBinary(ctx, downloadURL, tmpPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("download failed: %w", err)
 	}
 
+	if expectedSum != "" {
+		if err := verifyChecksum(tmpPath, expectedSum); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("checksum verification failed for %s: %w", assetName, err)
+		}
+		logInfof("checksum verified (sha256 %s)", expectedSum)
+	}
+
 	// copy permissions from existing binary
 	info, err := os.Stat(exePath)
 	if err != nil {
@@ -81,18 +121,22 @@ func selfUpdate(currentVersion string) error {
 		return fmt.Errorf("failed to replace binary: %w", err)
 	}
 
-	fmt.Printf("updated %s → %s\n", currentVersion, latestTag)
+	fmt.Println(icons(fmt.Sprintf("updated %s → %s", currentVersion, latestTag)))
 	return nil
 }
 
-func fetchLatestRelease() (*githubRelease, error) {
-	req, err := http.NewRequest(http.MethodGet, githubReleaseURL, nil)
+func fetchLatestRelease(ctx context.Context) (*githubRelease, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubReleaseURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
+	// authenticated requests get a much higher API rate limit (useful on shared CI runners)
+	if token := githubToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := outboundClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -121,8 +165,8 @@ func findAssetURL(release *githubRelease, assetName string) (string, error) {
 	return "", fmt.Errorf("asset %q not found", assetName)
 }
 
-func downloadBinary(url, destPath string) error {
-	resp, err := http.Get(url) //nolint:noctx
+func downloadBinary(ctx context.Context, url, destPath string) error {
+	resp, err := httpGet(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -142,6 +186,67 @@ func downloadBinary(url, destPath string) error {
 	return err
 }
 
+// releaseChecksum fetches checksums.txt from the release and returns the sha256 for assetName.
+func releaseChecksum(ctx context.Context, release *githubRelease, assetName string) (string, error) {
+	url, err := findAssetURL(release, checksumsAssetName)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpGet(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: server returned %s", checksumsAssetName, resp.Status)
+	}
+	return parseChecksums(resp.Body, assetName)
+}
+
+// parseChecksums finds assetName in sha256sum output ("<hex>  <name>" or "<hex> *<name>").
+func parseChecksums(r io.Reader, assetName string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(fields[1], "*") == assetName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %q in %s", assetName, checksumsAssetName)
+}
+
+func verifyChecksum(path, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != expected {
+		return fmt.Errorf("expected sha256 %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return outboundClient().Do(req)
+}
+
 func isPermissionError(err error) bool {
 	return strings.Contains(err.Error(), "permission denied") ||
 		strings.Contains(err.Error(), "operation not permitted")
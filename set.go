@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/cli"
+	"helm.sh/helm/v4/pkg/registry"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// setPins holds -set release=version values; when present the full update pass is skipped.
+var setPins stringList
+
+// noValidate disables checking pinned versions against the repo index / registry tags
+var noValidate bool
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runSet(args []string) {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	fs.StringVar(&filename, "file", "helmwave.yml.tpl", "path to helmwave yaml file")
+	fs.BoolVar(&inplace, "inplace", false, "modify the original file instead of creating a .updated copy")
+	fs.StringVar(&indexDir, "index-dir", "", "load repo indexes from this directory instead of the helm cache")
+	fs.BoolVar(&offline, "offline", false, "forbid network access (OCI versions are not validated)")
+	fs.BoolVar(&noValidate, "no-validate", false, "do not check that the version exists in the repo index / registry")
+	fs.StringVar(&auditLog, "audit-log", "", "append every applied pin as a JSON line to this audit log")
+	addLoggingFlags(fs)
+	parseFlags(fs, args)
+	if err := applyLoggingFlags(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := applyPins(ctx, fs.Args()); err != nil {
+		logErrorf("set: %v", err)
+		os.Exit(exitFatal)
+	}
+}
+
+// applyPins sets explicit versions (release=version) and edits only those releases.
+func applyPins(ctx context.Context, pins []string) error {
+	if len(pins) == 0 {
+		return errors.New("expected at least one RELEASE=VERSION argument")
+	}
+
+	in, hw, err := updater.ReadIncluded(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read helmwave: %w", err)
+	}
+	helmwaveIncludes = in
+	data := []byte(in.Text)
+
+	if helmwaveRepositories, err = updater.ParseRepositories(data); err != nil {
+		logWarnf("⚠️ failed to parse repositories section: %v", err)
+	}
+	collectInsecureRepos(&hw)
+
+	settings := cli.New()
+	var indexes map[string]*repo.IndexFile
+	var updates []releaseUpdate
+	versionMap := make(map[string]string, len(pins))
+	for _, pin := range pins {
+		name, ver, ok := strings.Cut(pin, "=")
+		name, ver = strings.TrimSpace(name), strings.TrimSpace(ver)
+		if !ok || name == "" || ver == "" {
+			return fmt.Errorf("invalid pin %q (expected RELEASE=VERSION)", pin)
+		}
+
+		release, err := findRelease(&hw, name)
+		if err != nil {
+			return fmt.Errorf("%w in %s", err, filename)
+		}
+
+		if !noValidate {
+			if indexes == nil && !strings.HasPrefix(release.Chart.Name, registry.OCIScheme+"://") {
+				if indexes, err = loadIndexes(ctx, settings, referencedCharts(hw.Releases)); err != nil {
+					return fmt.Errorf("failed to load repo file: %w", err)
+				}
+			}
+			// the version is written as published, so 1.2.3 pins a chart tagged v1.2.3 to v1.2.3
+			if ver, err = publishedPinVersion(release, ver, indexes); err != nil {
+				return fmt.Errorf("release %s: %w", name, err)
+			}
+		}
+
+		logInfof("pinning release %s: %s -> %s", name, release.Chart.Version, ver)
+		versionMap[release.ID()] = ver
+		if ver != release.Chart.Version {
+			updates = append(updates, updater.NewReleaseUpdate(release, ver, "", ""))
+		}
+	}
+
+	out := updater.UpdateText(data, versionMap, nil)
+	for _, conflict := range updater.ChartAliasConflicts(data, versionMap) {
+		logWarnf("⚠️ not updated: %s", conflict)
+	}
+	if out == string(data) {
+		logWarnf("no version line changed; the version may already match or be defined outside the release block")
+		updates = nil
+	}
+
+	if err := writeUpdated(filename, out, inplace); err != nil {
+		return err
+	}
+	if inplace {
+		// pins are recorded like update runs, so rollback can undo them
+		auditEdits(filename, updates)
+	}
+	return nil
+}
+
+// findRelease looks a release up by ID (name@namespace[@context]) or, when the name is
+// unique in the file, by name alone.
+func findRelease(hw *Helmwave, name string) (Release, error) {
+	var matches []Release
+	for _, r := range hw.Releases {
+		if r.ID() == name {
+			return r, nil
+		}
+		if r.Name == name {
+			matches = append(matches, r)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Release{}, fmt.Errorf("release %q not found", name)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, r := range matches {
+		ids[i] = r.ID()
+	}
+	return Release{}, fmt.Errorf("release name %q is ambiguous (%s); use name@namespace", name, strings.Join(ids, ", "))
+}
+
+// publishedPinVersion checks that ver is published for the release's chart and returns it
+// exactly as the index or tag list has it: a "v" prefix may differ from what was typed.
+func publishedPinVersion(release Release, ver string, indexes map[string]*repo.IndexFile) (string, error) {
+	if strings.HasPrefix(release.Chart.Name, registry.OCIScheme+"://") {
+		if offline {
+			logWarnf("cannot validate OCI version for release %s in offline mode", release.Name)
+			return ver, nil
+		}
+		conn, err := newOCIConn(chartTLSOptions(release.Chart))
+		if err != nil {
+			return "", err
+		}
+		tags, err := conn.client.Tags(strings.TrimPrefix(release.Chart.Name, registry.OCIScheme+"://"))
+		if err != nil {
+			return "", fmt.Errorf("failed to list OCI tags: %w", err)
+		}
+		if published, ok := matchPinVersion(tags, ver); ok {
+			return published, nil
+		}
+		return "", fmt.Errorf("version %s not found in %s", ver, release.Chart.Name)
+	}
+
+	repoName, chartName, ok := updater.SplitRepoChart(release.Chart.Name)
+	if !ok {
+		return "", fmt.Errorf("unexpected chart.name format %q", release.Chart.Name)
+	}
+	idx, ok := indexes[repoName]
+	if !ok || idx == nil {
+		return "", fmt.Errorf("no index for repo %q", repoName)
+	}
+	versions := make([]string, 0, len(idx.Entries[chartName]))
+	for _, e := range idx.Entries[chartName] {
+		versions = append(versions, e.Version)
+	}
+	if published, ok := matchPinVersion(versions, ver); ok {
+		return published, nil
+	}
+	return "", fmt.Errorf("version %s not found for chart %s", ver, release.Chart.Name)
+}
+
+// matchPinVersion finds ver among the published versions, ignoring a "v" prefix; an exact
+// match wins.
+func matchPinVersion(published []string, ver string) (string, bool) {
+	if slices.Contains(published, ver) {
+		return ver, true
+	}
+	for _, v := range published {
+		if strings.TrimPrefix(v, "v") == strings.TrimPrefix(ver, "v") {
+			return v, true
+		}
+	}
+	return "", false
+}
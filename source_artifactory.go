@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// providerArtifactory lists chart packages through the Artifactory REST API.
+const providerArtifactory = "artifactory"
+
+func init() {
+	updater.RegisterProvider(providerArtifactory, newArtifactoryProvider)
+}
+
+// artifactoryProvider searches a helm repository for <chart>-*.tgz packages with Artifactory's
+// quick search (/api/search/artifact), which also covers virtual repositories and the cached
+// content of remote ones, without reading the aggregated index.yaml.
+//
+// Options:
+//   - url: the Artifactory base URL (e.g. https://example.jfrog.io/artifactory)
+//   - repository: the helm repository key
+//   - apiKeyEnv (sent as X-JFrog-Art-Api), tokenEnv, or usernameEnv / passwordEnv
+//   - prereleases: "true" to include prereleases
+type artifactoryProvider struct {
+	baseURL     string
+	repository  string
+	auth        providerAuth
+	prereleases bool
+}
+
+type artifactorySearchResult struct {
+	Results []struct {
+		URI string `json:"uri"`
+	} `json:"results"`
+}
+
+func newArtifactoryProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	auth, err := authFromOptions(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("artifactory provider: %w", err)
+	}
+	p := artifactoryProvider{
+		baseURL:     strings.TrimSuffix(cfg.Option("url"), "/"),
+		repository:  cfg.Option("repository"),
+		auth:        auth,
+		prereleases: cfg.Option("prereleases") == "true",
+	}
+	if p.baseURL == "" || p.repository == "" {
+		return nil, errors.New("artifactory provider: url and repository are required")
+	}
+	p.auth.apiKeyHeader = "X-JFrog-Art-Api"
+	return p, nil
+}
+
+func (p artifactoryProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	chartName := path.Base(release.Chart.Name)
+	query := url.Values{"name": {chartName + "-*.tgz"}, "repos": {p.repository}}
+	searchURL := p.baseURL + "/api/search/artifact?" + query.Encode()
+
+	var result artifactorySearchResult
+	err := withRetry(ctx, "search Artifactory for "+chartName, func() error {
+		body, err := providerGet(ctx, searchURL, p.auth)
+		if err != nil {
+			return err
+		}
+		return permanent(json.Unmarshal(body, &result))
+	})
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("Artifactory API: %w", err)
+	}
+
+	var versions []string
+	for _, r := range result.Results {
+		if v, ok := packageVersion(path.Base(r.URI), chartName); ok {
+			versions = append(versions, v)
+		}
+	}
+	latest, ok := latestVersion(versions, p.prereleases)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("no versions of %s in Artifactory repository %s", chartName, p.repository)
+	}
+	return updater.Resolution{LatestVersion: latest}, nil
+}
+
+// packageVersion extracts the version from a chart package file name (<chart>-<version>.tgz).
+// Packages of other charts sharing the prefix (nginx-ingress-1.0.0.tgz for nginx) yield
+// non-semver remainders and are dropped later by the semver filter.
+func packageVersion(file, chartName string) (string, bool) {
+	v, ok := strings.CutPrefix(file, chartName+"-")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(v, ".tgz")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// providerChartMuseum queries the ChartMuseum API instead of downloading its index.
+const providerChartMuseum = "chartmuseum"
+
+func init() {
+	updater.RegisterProvider(providerChartMuseum, newChartMuseumProvider)
+}
+
+// chartMuseumProvider reads <url>/api/charts/<chart>, which only returns the versions of one
+// chart and is served from ChartMuseum's live storage rather than a cached index.yaml.
+//
+// Options:
+//   - url: the ChartMuseum base URL (with the tenant path on multitenant servers, e.g. https://museum/org/repo)
+//   - tokenEnv, or usernameEnv / passwordEnv: environment variables with the credentials
+//
+// The chart is the last path element of chart.name (or of the chart option).
+type chartMuseumProvider struct {
+	apiURL string
+	auth   providerAuth
+}
+
+func newChartMuseumProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	base := strings.TrimSuffix(cfg.Option("url"), "/")
+	if base == "" {
+		return nil, errors.New("chartmuseum provider: url is required")
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("chartmuseum provider: invalid url %q", base)
+	}
+	// the API sits under /api, before the tenant path
+	u.Path = "/api" + u.Path + "/charts"
+	auth, err := authFromOptions(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("chartmuseum provider: %w", err)
+	}
+	return chartMuseumProvider{apiURL: u.String(), auth: auth}, nil
+}
+
+func (p chartMuseumProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	chartName := path.Base(release.Chart.Name)
+	if chartName == "" || chartName == "." || chartName == "/" {
+		return updater.Resolution{}, fmt.Errorf("unexpected chart.name format %q", release.Chart.Name)
+	}
+	var versions repo.ChartVersions
+	err := withRetry(ctx, "query ChartMuseum for "+chartName, func() error {
+		body, err := providerGet(ctx, p.apiURL+"/"+url.PathEscape(chartName), p.auth)
+		if err != nil {
+			return err
+		}
+		return permanent(json.Unmarshal(body, &versions))
+	})
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("ChartMuseum API: %w", err)
+	}
+
+	// same ordering and appVersion handling as an index lookup
+	idx := repo.NewIndexFile()
+	idx.Entries[chartName] = versions
+	idx.SortEntries()
+	return updater.Resolve(idx, chartName, release.Chart.Version)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// providerGit resolves charts referenced as git+<repo>@<path>?ref=<tag> (helm-git plugin form)
+// by listing the upstream tags; the newest semver tag becomes the new ref.
+const providerGit = "git"
+
+func init() {
+	updater.RegisterProvider(providerGit, newGitProvider)
+}
+
+// gitProvider runs `git ls-remote --tags`, so credentials come from the usual git setup
+// (credential helpers, ssh agent). tagPrefix selects and strips prefixed tags.
+type gitProvider struct {
+	tagPrefix string
+}
+
+func newGitProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	return gitProvider{tagPrefix: cfg.Option("tagPrefix")}, nil
+}
+
+func (p gitProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	chart, ok := updater.ParseGitChart(release.Chart.Name)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("chart %q is not a git+ reference", release.Chart.Name)
+	}
+	var tags []string
+	err := withRetry(ctx, "list git tags of "+chart.Repo, func() error {
+		var err error
+		tags, err = gitRemoteTags(ctx, chart.Repo)
+		return err
+	})
+	if err != nil {
+		return updater.Resolution{}, fmt.Errorf("git tags: %w", err)
+	}
+
+	byVersion := make(map[string]string)
+	var versions []string
+	for _, tag := range tags {
+		if v, ok := strings.CutPrefix(tag, p.tagPrefix); ok {
+			versions = append(versions, v)
+			byVersion[strings.TrimPrefix(v, "v")] = tag
+		}
+	}
+	latest, ok := updater.LatestSemverTag(stableVersions(versions))
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("no semver-compatible tags in %s", chart.Repo)
+	}
+	// the ref must be the tag as published (keeping its v or prefix)
+	return updater.Resolution{LatestVersion: byVersion[latest]}, nil
+}
+
+// gitRemoteTags lists tag names of a remote repository.
+func gitRemoteTags(ctx context.Context, repoURL string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--tags", "--refs", repoURL)
+	cmd.Stderr = &stderr
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote %s: %w: %s", repoURL, err, strings.TrimSpace(stderr.String()))
+	}
+	var tags []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		_, ref, ok := strings.Cut(scanner.Text(), "\t")
+		if tag, isTag := strings.CutPrefix(ref, "refs/tags/"); ok && isTag {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, scanner.Err()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// githubProvider takes the version stream from GitHub releases of a repository (repo: owner/name),
+// for charts published as release assets instead of through an index.
+//
+// Options:
+//   - tagPrefix: only tags with this prefix count, with the prefix stripped ("podinfo-" in monorepos)
+//   - asset: a glob with one "*" (e.g. "podinfo-*.tgz"); only releases carrying a matching asset
+//     count, and the version is taken from the part matched by "*" instead of the tag
+//   - prereleases: "true" to include prereleases (drafts are always ignored)
+//   - tokenEnv, tokenFile or tokenCommand: the API token (default: the GITHUB_TOKEN variable)
+//   - api: API base URL for GitHub Enterprise (default https://api.github.com)
+type githubProvider struct {
+	repo        string
+	apiURL      string
+	tagPrefix   string
+	asset       string
+	prereleases bool
+	auth        providerAuth
+}
+
+type githubReleaseInfo struct {
+	TagName    string        `json:"tag_name"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+	Assets     []githubAsset `json:"assets"`
+}
+
+// githubMaxPages bounds pagination of the releases API (100 releases per page)
+const githubMaxPages = 10
+
+func newGitHubProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	auth, err := authFromOptions(cfg, "GITHUB_TOKEN")
+	if err != nil {
+		return nil, fmt.Errorf("github provider: %w", err)
+	}
+	p := githubProvider{
+		repo:        cfg.Option("repo"),
+		apiURL:      strings.TrimSuffix(firstNonEmpty(cfg.Option("api"), "https://api.github.com"), "/"),
+		tagPrefix:   cfg.Option("tagPrefix"),
+		asset:       cfg.Option("asset"),
+		prereleases: cfg.Option("prereleases") == "true",
+		auth:        auth,
+	}
+	if strings.Count(p.repo, "/") != 1 {
+		return nil, fmt.Errorf("github provider: repo must be owner/name, got %q", p.repo)
+	}
+	if p.asset != "" {
+		if strings.Count(p.asset, "*") != 1 {
+			return nil, fmt.Errorf("github provider: asset must contain exactly one '*', got %q", p.asset)
+		}
+		if _, err := path.Match(p.asset, ""); err != nil {
+			return nil, fmt.Errorf("github provider: invalid asset pattern %q: %w", p.asset, err)
+		}
+	}
+	return p, nil
+}
+
+func (p githubProvider) Resolve(ctx context.Context, _ Release) (updater.Resolution, error) {
+	var releases []githubReleaseInfo
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", p.apiURL, p.repo)
+	for page := 0; url != "" && page < githubMaxPages; page++ {
+		var batch []githubReleaseInfo
+		var next string
+		err := withRetry(ctx, "list GitHub releases of "+p.repo, func() error {
+			body, link, err := providerGetPage(ctx, url, p.auth)
+			if err != nil {
+				return err
+			}
+			batch, next = nil, link
+			return permanent(json.Unmarshal(body, &batch))
+		})
+		if err != nil {
+			return updater.Resolution{}, fmt.Errorf("GitHub releases: %w", err)
+		}
+		releases = append(releases, batch...)
+		url = next
+	}
+
+	versions := p.versions(releases)
+	latest, ok := updater.LatestSemverTag(versions)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("no semver-compatible releases in %s", p.repo)
+	}
+	return updater.Resolution{LatestVersion: latest, Versions: updater.Published(versions)}, nil
+}
+
+// versions extracts the candidate versions from the releases according to the options.
+func (p githubProvider) versions(releases []githubReleaseInfo) []string {
+	var versions []string
+	for _, r := range releases {
+		if r.Draft || (r.Prerelease && !p.prereleases) {
+			continue
+		}
+		if p.asset == "" {
+			if v, ok := strings.CutPrefix(r.TagName, p.tagPrefix); ok {
+				versions = append(versions, v)
+			}
+			continue
+		}
+		if p.tagPrefix != "" && !strings.HasPrefix(r.TagName, p.tagPrefix) {
+			continue
+		}
+		before, after, _ := strings.Cut(p.asset, "*")
+		for _, a := range r.Assets {
+			if ok, _ := path.Match(p.asset, a.Name); ok {
+				versions = append(versions, strings.TrimSuffix(strings.TrimPrefix(a.Name, before), after))
+			}
+		}
+	}
+	return versions
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/registry"
+)
+
+// providerHarbor lists OCI chart tags through Harbor's artifact API.
+const providerHarbor = "harbor"
+
+const (
+	harborPageSize = 100
+	harborMaxPages = 20
+)
+
+func init() {
+	updater.RegisterProvider(providerHarbor, newHarborProvider)
+}
+
+// harborProvider reads the artifacts of a Harbor repository. Unlike a plain registry tag
+// listing it sees Harbor labels, so only artifacts carrying every configured label count,
+// and the chart appVersion comes along with the tags.
+//
+// Options:
+//   - url: the Harbor base URL; defaults to https://<registry host> of an oci:// chart name
+//   - repository: project/repository; defaults to the path of an oci:// chart name
+//   - labels: comma-separated Harbor labels an artifact must have (e.g. "approved")
+//   - usernameEnv / passwordEnv: robot account name (robot$...) and secret, or tokenEnv
+//   - prereleases: "true" to include prereleases
+type harborProvider struct {
+	baseURL     string
+	repository  string
+	labels      []string
+	auth        providerAuth
+	prereleases bool
+}
+
+type harborArtifact struct {
+	Tags []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	ExtraAttrs struct {
+		AppVersion string `json:"appVersion"`
+	} `json:"extra_attrs"`
+}
+
+func newHarborProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	auth, err := authFromOptions(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("harbor provider: %w", err)
+	}
+	p := harborProvider{
+		baseURL:     strings.TrimSuffix(cfg.Option("url"), "/"),
+		repository:  strings.Trim(cfg.Option("repository"), "/"),
+		auth:        auth,
+		prereleases: cfg.Option("prereleases") == "true",
+	}
+	for _, label := range strings.Split(cfg.Option("labels"), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			p.labels = append(p.labels, label)
+		}
+	}
+	return p, nil
+}
+
+// target returns the API base URL and project/repository for release.
+func (p harborProvider) target(release Release) (string, string, string, error) {
+	baseURL, repository := p.baseURL, p.repository
+	if ref, ok := strings.CutPrefix(release.Chart.Name, registry.OCIScheme+"://"); ok {
+		host, path, _ := strings.Cut(ref, "/")
+		if baseURL == "" {
+			baseURL = "https://" + host
+		}
+		if repository == "" {
+			repository = path
+		}
+	}
+	project, repoName, ok := strings.Cut(repository, "/")
+	if baseURL == "" || !ok || project == "" || repoName == "" {
+		return "", "", "", errors.New("harbor provider: url and repository (project/repository) are required unless chart.name is an oci:// reference")
+	}
+	return baseURL, project, repoName, nil
+}
+
+func (p harborProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	baseURL, project, repoName, err := p.target(release)
+	if err != nil {
+		return updater.Resolution{}, err
+	}
+	// nested repository names must be URL-encoded twice in Harbor's API paths
+	artifactsURL := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts",
+		baseURL, url.PathEscape(project), url.PathEscape(url.PathEscape(repoName)))
+
+	appVersions := make(map[string]string)
+	var versions []string
+	for page := 1; page <= harborMaxPages; page++ {
+		query := url.Values{
+			"page":       {fmt.Sprint(page)},
+			"page_size":  {fmt.Sprint(harborPageSize)},
+			"with_tag":   {"true"},
+			"with_label": {"true"},
+		}
+		var artifacts []harborArtifact
+		err := withRetry(ctx, "list Harbor artifacts of "+project+"/"+repoName, func() error {
+			body, err := providerGet(ctx, artifactsURL+"?"+query.Encode(), p.auth)
+			if err != nil {
+				return err
+			}
+			artifacts = nil
+			return permanent(json.Unmarshal(body, &artifacts))
+		})
+		if err != nil {
+			return updater.Resolution{}, fmt.Errorf("Harbor API: %w", err)
+		}
+		for _, a := range artifacts {
+			if !p.hasLabels(a) {
+				continue
+			}
+			for _, tag := range a.Tags {
+				versions = append(versions, tag.Name)
+				appVersions[strings.TrimPrefix(tag.Name, "v")] = strings.TrimSpace(a.ExtraAttrs.AppVersion)
+			}
+		}
+		if len(artifacts) < harborPageSize {
+			break
+		}
+	}
+
+	latest, ok := latestVersion(versions, p.prereleases)
+	if !ok {
+		if len(p.labels) > 0 {
+			return updater.Resolution{}, fmt.Errorf("no semver-compatible tags labelled %s in %s/%s", strings.Join(p.labels, ", "), project, repoName)
+		}
+		return updater.Resolution{}, fmt.Errorf("no semver-compatible tags in %s/%s", project, repoName)
+	}
+	return updater.Resolution{
+		LatestVersion:     latest,
+		CurrentAppVersion: appVersions[strings.TrimPrefix(release.Chart.Version, "v")],
+		LatestAppVersion:  appVersions[latest],
+	}, nil
+}
+
+// hasLabels reports whether the artifact carries every required label.
+func (p harborProvider) hasLabels(a harborArtifact) bool {
+	for _, want := range p.labels {
+		found := false
+		for _, l := range a.Labels {
+			if l.Name == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
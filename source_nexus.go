@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// providerNexus lists chart versions through the Nexus Repository search API.
+const providerNexus = "nexus"
+
+// nexusMaxPages bounds pagination of the search API
+const nexusMaxPages = 50
+
+func init() {
+	updater.RegisterProvider(providerNexus, newNexusProvider)
+}
+
+// nexusProvider queries /service/rest/v1/search for the components of one chart. Group
+// repositories are searched like hosted ones, so the lookup doesn't depend on the merged index.
+//
+// Options:
+//   - url: the Nexus base URL (e.g. https://nexus.example.com)
+//   - repository: the helm repository (hosted, proxy or group)
+//   - tokenEnv, or usernameEnv / passwordEnv (a Nexus user token works as basic auth)
+//   - prereleases: "true" to include prereleases
+type nexusProvider struct {
+	baseURL     string
+	repository  string
+	auth        providerAuth
+	prereleases bool
+}
+
+type nexusSearchPage struct {
+	Items []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+func newNexusProvider(cfg updater.ProviderConfig) (updater.VersionProvider, error) {
+	auth, err := authFromOptions(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("nexus provider: %w", err)
+	}
+	p := nexusProvider{
+		baseURL:     strings.TrimSuffix(cfg.Option("url"), "/"),
+		repository:  cfg.Option("repository"),
+		auth:        auth,
+		prereleases: cfg.Option("prereleases") == "true",
+	}
+	if p.baseURL == "" || p.repository == "" {
+		return nil, errors.New("nexus provider: url and repository are required")
+	}
+	return p, nil
+}
+
+func (p nexusProvider) Resolve(ctx context.Context, release Release) (updater.Resolution, error) {
+	chartName := path.Base(release.Chart.Name)
+	var versions []string
+	token := ""
+	for range nexusMaxPages {
+		query := url.Values{"repository": {p.repository}, "format": {"helm"}, "name": {chartName}}
+		if token != "" {
+			query.Set("continuationToken", token)
+		}
+		var page nexusSearchPage
+		err := withRetry(ctx, "search Nexus for "+chartName, func() error {
+			body, err := providerGet(ctx, p.baseURL+"/service/rest/v1/search?"+query.Encode(), p.auth)
+			if err != nil {
+				return err
+			}
+			page = nexusSearchPage{}
+			return permanent(json.Unmarshal(body, &page))
+		})
+		if err != nil {
+			return updater.Resolution{}, fmt.Errorf("Nexus API: %w", err)
+		}
+		for _, item := range page.Items {
+			// name is an exact-or-wildcard filter depending on the Nexus version
+			if item.Name == chartName {
+				versions = append(versions, item.Version)
+			}
+		}
+		if token = page.ContinuationToken; token == "" {
+			break
+		}
+	}
+
+	latest, ok := latestVersion(versions, p.prereleases)
+	if !ok {
+		return updater.Resolution{}, fmt.Errorf("no versions of %s in Nexus repository %s", chartName, p.repository)
+	}
+	return updater.Resolution{LatestVersion: latest}, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// staleAfter warns when an index used for resolution is older than this (-stale-after); 0 disables
+var staleAfter time.Duration
+
+// autoRefreshOlderThan re-fetches cached indexes older than this (-auto-refresh-older-than); 0 disables
+var autoRefreshOlderThan time.Duration
+
+// indexAge returns how long ago the index file at path was written.
+func indexAge(path string) (time.Duration, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(info.ModTime()), true
+}
+
+// warnIfStale logs a warning when the index for repo is older than staleAfter.
+func warnIfStale(repoName, path string) {
+	if staleAfter <= 0 {
+		return
+	}
+	if age, ok := indexAge(path); ok && age > staleAfter {
+		logWarnf("⚠️ index of repo %s is %s old (older than %s); results may miss recent releases", repoName, age.Round(time.Minute), staleAfter)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStateFile keeps the run state when -cooldown or -limit-order oldest is used without -state-file.
+const defaultStateFile = ".helmwave-updater-state.json"
+
+// stateFile is the path of the run state file (-state-file); empty disables it unless -cooldown is set.
+var stateFile string
+
+// cooldown holds a release back for this long after its last bump (-cooldown, e.g. 7d); 0 disables
+var cooldown time.Duration
+
+// bumpState is the persistent run state: when each release (by ID) was last bumped.
+type bumpState struct {
+	Releases map[string]bumpRecord `json:"releases"`
+}
+
+type bumpRecord struct {
+	LastBumped time.Time `json:"lastBumped"`
+	Version    string    `json:"version"`
+}
+
+// bumps is the state loaded at the start of a run.
+var bumps bumpState
+
+// statePath returns the state file in use, or "" when the run keeps no state.
+func statePath() string {
+	if stateFile == "" && (cooldown > 0 || (limit > 0 && limitOrder == "oldest")) {
+		return defaultStateFile
+	}
+	return stateFile
+}
+
+// loadState reads the state file at path; a missing file is an empty state.
+func loadState(path string) (bumpState, error) {
+	s := bumpState{Releases: map[string]bumpRecord{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if s.Releases == nil {
+		s.Releases = map[string]bumpRecord{}
+	}
+	return s, nil
+}
+
+// saveState records updates of the helmwave file in s and writes it to path.
+func saveState(path string, s bumpState, now time.Time, updates []releaseUpdate) error {
+	if path == "" || len(updates) == 0 {
+		return nil
+	}
+	for _, u := range updates {
+		if u.File != "" {
+			// Chart.yaml dependencies are not subject to the cooldown
+			continue
+		}
+		s.Releases[u.ID()] = bumpRecord{LastBumped: now.UTC(), Version: u.ToVersion}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	logDebugf("recorded %d updates in state file %s", len(updates), path)
+	return nil
+}
+
+// cooldownUntil returns when the cooldown of the release with the given ID ends, if it has not yet.
+func cooldownUntil(s bumpState, id string, now time.Time) (time.Time, bool) {
+	if cooldown <= 0 {
+		return time.Time{}, false
+	}
+	rec, ok := s.Releases[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	until := rec.LastBumped.Add(cooldown)
+	return until, now.Before(until)
+}
+
+// durationValue is a flag.Value for durations that also accepts whole days ("7d").
+type durationValue time.Duration
+
+func (d *durationValue) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *durationValue) Set(s string) error {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number of days %q", s)
+		}
+		*d = durationValue(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
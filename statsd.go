@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// statsdAddr is the host:port of a StatsD or DogStatsD agent receiving run metrics (-statsd)
+var statsdAddr string
+
+// statsdFormat is statsd (importance in the metric name) or dogstatsd (tags) (-statsd-format)
+var statsdFormat = "statsd"
+
+// statsdPrefix prefixes every metric name (-statsd-prefix)
+var statsdPrefix = "helmwave_updater"
+
+// statsdTags are extra DogStatsD tags (key:value) added to every metric (-statsd-tags)
+var statsdTags string
+
+// renderStatsd renders the outdated release counts by importance, the status counts and the
+// run duration as StatsD lines. Every importance is sent, so dashboards see zeros too.
+func renderStatsd(result checkResult, duration time.Duration) (string, error) {
+	var common []string
+	for _, t := range strings.Split(statsdTags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			common = append(common, t)
+		}
+	}
+	var line func(name, value, kind string, tags ...string) string
+	switch statsdFormat {
+	case "statsd":
+		line = func(name, value, kind string, tags ...string) string {
+			for _, t := range tags {
+				_, v, _ := strings.Cut(t, ":")
+				name += "." + v
+			}
+			return fmt.Sprintf("%s.%s:%s|%s\n", statsdPrefix, name, value, kind)
+		}
+	case "dogstatsd":
+		line = func(name, value, kind string, tags ...string) string {
+			tags = append(tags, common...)
+			if len(tags) == 0 {
+				return fmt.Sprintf("%s.%s:%s|%s\n", statsdPrefix, name, value, kind)
+			}
+			return fmt.Sprintf("%s.%s:%s|%s|#%s\n", statsdPrefix, name, value, kind, strings.Join(tags, ","))
+		}
+	default:
+		return "", fmt.Errorf("unknown -statsd-format %q (expected statsd or dogstatsd)", statsdFormat)
+	}
+
+	byImportance := make(map[string]int)
+	for _, u := range result.Updates() {
+		byImportance[u.Importance]++
+	}
+	var sb strings.Builder
+	for _, importance := range []string{"major", "minor", "patch", "none", "unknown"} {
+		sb.WriteString(line("outdated", fmt.Sprint(byImportance[importance]), "g", "importance:"+importance))
+	}
+	for _, status := range []releaseStatus{statusUpdated, statusUpToDate, statusSkipped, statusFailed} {
+		sb.WriteString(line("releases", fmt.Sprint(result.Count(status)), "g", "status:"+string(status)))
+	}
+	sb.WriteString(line("run.duration", fmt.Sprint(duration.Milliseconds()), "ms"))
+	return sb.String(), nil
+}
+
+// emitStatsd sends the run's metrics to -statsd over UDP. Failures are returned for the
+// caller to log; they never fail the run.
+func emitStatsd(result checkResult, duration time.Duration) error {
+	if statsdAddr == "" {
+		return nil
+	}
+	payload, err := renderStatsd(result, duration)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("udp", statsdAddr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// one datagram per line keeps each packet well below the agents' size limits
+	for _, l := range strings.SplitAfter(strings.TrimSuffix(payload, "\n"), "\n") {
+		if _, err := conn.Write([]byte(strings.TrimSuffix(l, "\n"))); err != nil {
+			return err
+		}
+	}
+	logDebugf("sent %s metrics to %s", statsdFormat, statsdAddr)
+	return nil
+}
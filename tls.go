@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tlsOptions are the per-repository / per-chart TLS settings.
+type tlsOptions struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	Insecure bool
+}
+
+func (o tlsOptions) isZero() bool {
+	return o == tlsOptions{}
+}
+
+// key identifies clients that can be shared between releases.
+func (o tlsOptions) key() string {
+	return fmt.Sprintf("%s|%s|%s|%v", o.CAFile, o.CertFile, o.KeyFile, o.Insecure)
+}
+
+// tlsConfig builds a tls.Config that trusts the system pool plus CAFile.
+func (o tlsOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: o.Insecure} //nolint:gosec // explicitly requested per repository
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" && o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// httpClient returns an http.Client using these TLS options (outboundClient when zero), with
+// the per-host limits applied.
+func (o tlsOptions) httpClient() (*http.Client, error) {
+	if o.isZero() {
+		return outboundClient(), nil
+	}
+	cfg, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return &http.Client{Transport: limitedTransport{base: transport}}, nil
+}
+
+// chartTLSOptions reads helmwave chart options (cafile, certfile, keyfile, insecureskiptlsverify)
+// captured in Chart.Other.
+func chartTLSOptions(c Chart) tlsOptions {
+	return tlsOptions{
+		CAFile:   otherString(c.Other, "cafile", "caFile", "ca_file"),
+		CertFile: otherString(c.Other, "certfile", "certFile", "cert_file"),
+		KeyFile:  otherString(c.Other, "keyfile", "keyFile", "key_file"),
+		Insecure: otherBool(c.Other, "insecureskiptlsverify", "insecureSkipTLSVerify", "insecure_skip_tls_verify"),
+	}
+}
+
+func otherString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := m[k]; ok && v != nil {
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+	}
+	return ""
+}
+
+func otherBool(m map[string]interface{}, keys ...string) bool {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case bool:
+			return v
+		case string:
+			b, _ := strconv.ParseBool(strings.TrimSpace(v))
+			return b
+		}
+	}
+	return false
+}
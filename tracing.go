@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/sovigod/helmwave-updater"
+
+var otlpEndpoint string
+
+// tracer returns the package tracer; it is a no-op until initTracing installs a provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// initTracing installs an OTLP/HTTP trace exporter when an endpoint is configured either
+// via -otlp-endpoint or the standard OTEL_EXPORTER_OTLP_* environment variables.
+// The returned shutdown func flushes pending spans and must be called before exit.
+func initTracing(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if otlpEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if otlpEndpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return noop, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("helmwave-updater"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return noop, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	logDebugf("OTLP tracing enabled")
+	return tp.Shutdown, nil
+}
+
+// startSpan starts a child span of ctx with the given attributes.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// spanError records err on span, with credentials masked, and marks the span as failed.
+func spanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	msg := redactSecrets(err.Error())
+	span.RecordError(errors.New(msg))
+	span.SetStatus(codes.Error, msg)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// checkUpdate enables the opt-in "new version available" notice (-check-update)
+var checkUpdate bool
+
+// updateCheckTTL limits GitHub API calls to one per day per machine.
+const updateCheckTTL = 24 * time.Hour
+
+type updateCheckCache struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	LatestTag string    `json:"latestTag"`
+}
+
+// notifyNewVersion prints a one-line hint to w (stderr) when a newer release exists.
+// Failures are only logged at debug level: the notice must never break a run.
+func notifyNewVersion(ctx context.Context, w io.Writer, currentVersion string) {
+	if !checkUpdate || offline || currentVersion == "dev" {
+		return
+	}
+	latest, err := latestReleaseTagCached(ctx)
+	if err != nil {
+		logDebugf("update check failed: %v", err)
+		return
+	}
+	cur, err1 := semver.NewVersion(currentVersion)
+	lat, err2 := semver.NewVersion(latest)
+	if err1 != nil || err2 != nil || !lat.GreaterThan(cur) {
+		return
+	}
+	fmt.Fprintf(w, "new version available: %s -> %s (run `helmwave-updater self-update`)\n", currentVersion, latest)
+}
+
+func latestReleaseTagCached(ctx context.Context) (string, error) {
+	cachePath := updateCheckCachePath()
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var cached updateCheckCache
+			if json.Unmarshal(data, &cached) == nil && cached.LatestTag != "" && time.Since(cached.CheckedAt) < updateCheckTTL {
+				logDebugf("using cached latest release %s from %s", cached.LatestTag, cachePath)
+				return cached.LatestTag, nil
+			}
+		}
+	}
+
+	release, err := fetchLatestRelease(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if cachePath != "" {
+		data, _ := json.Marshal(updateCheckCache{CheckedAt: time.Now().UTC(), LatestTag: release.TagName})
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			_ = os.WriteFile(cachePath, data, 0644)
+		}
+	}
+	return release.TagName, nil
+}
+
+func updateCheckCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "helmwave-updater", "latest-release.json")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+	"helm.sh/helm/v4/pkg/cli"
+)
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.StringVar(&filename, "file", "helmwave.yml.tpl", "path to helmwave yaml file")
+	fs.BoolVar(&strict, "strict", false, "fail on warnings (unknown keys) too")
+	addLoggingFlags(fs)
+	parseFlags(fs, args)
+	if err := applyLoggingFlags(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+
+	errs, warns, err := validateFile(filename)
+	if err != nil {
+		logErrorf("validate: %v", err)
+		os.Exit(exitFatal)
+	}
+	if errs > 0 || (strict && warns > 0) {
+		os.Exit(exitFatal)
+	}
+}
+
+// validateFile prints the problems of a helmwave file as file:line: severity: message and
+// returns how many errors and warnings there were. Repositories may be defined in the file
+// or in helm's repositories.yaml.
+func validateFile(path string) (errs, warns int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if helmwaveRepositories, err = updater.ParseRepositories(data); err != nil {
+		logWarnf("⚠️ failed to parse repositories section: %v", err)
+	}
+	defined := make(map[string]bool)
+	entries, err := repoEntries(cli.New())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logWarnf("⚠️ failed to load helm repositories: %v", err)
+	}
+	for _, e := range entries {
+		defined[e.Name] = true
+	}
+	for _, e := range helmwaveRepositories {
+		defined[e.Name] = true
+	}
+
+	problems, err := updater.Validate(data, func(name string) bool { return defined[name] })
+	var perr *updater.ParseError
+	if errors.As(err, &perr) {
+		perr.File = path
+		fmt.Printf("%v\n", perr)
+		return 1, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range problems {
+		fmt.Printf("%s:%d: %s: %s\n", path, p.Line, p.Severity, p.Message)
+		if p.Severity == "error" {
+			errs++
+		} else {
+			warns++
+		}
+	}
+	if len(problems) == 0 {
+		logInfof("%s is valid", path)
+	}
+	return errs, warns, nil
+}
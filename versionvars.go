@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sovigod/helmwave-updater/pkg/updater"
+)
+
+// versionVars resolves chart versions read from a variable and updates the variable instead
+// of skipping the release (-version-vars)
+var versionVars bool
+
+// versionEnvFile is the .env file behind {{ env "X" }} versions (-version-env-file); by
+// default .env next to -file
+var versionEnvFile string
+
+// versionVarFile returns the file holding v: the .env file for environment variables, the
+// versions file relative to dir, the helmwave file's directory, otherwise.
+func versionVarFile(dir string, v updater.VersionVar) string {
+	switch {
+	case !v.Env && filepath.IsAbs(v.File):
+		return v.File
+	case !v.Env:
+		return filepath.Join(dir, v.File)
+	case versionEnvFile != "":
+		return versionEnvFile
+	}
+	return filepath.Join(dir, ".env")
+}
+
+// readVersionVar returns the current value of v from its file.
+func readVersionVar(dir string, v updater.VersionVar) (string, error) {
+	path := versionVarFile(dir, v)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !v.Env {
+		value, err := updater.VersionsFileValue(data, v.Key)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return value, nil
+	}
+	value, ok := updater.ReadEnvFile(data)[v.Key]
+	if !ok {
+		return "", fmt.Errorf("%s does not set %s", path, v.Key)
+	}
+	return value, nil
+}
+
+// resolveVersionVars replaces the templated chart versions of hw, read from a file in dir,
+// that read a variable with the variable's value and returns the variables by release ID.
+// Releases whose variable cannot be read keep their templated version and are skipped.
+func resolveVersionVars(dir string, hw *Helmwave) map[string]updater.VersionVar {
+	if !versionVars {
+		return nil
+	}
+	vars := make(map[string]updater.VersionVar)
+	for i, r := range hw.Releases {
+		v, ok := updater.ParseVersionVar(r.Chart.Version)
+		if !ok {
+			continue
+		}
+		value, err := readVersionVar(dir, v)
+		if err != nil {
+			logWarnf("⚠️ release %s: cannot resolve version %s: %v", r.Name, r.Chart.Version, err)
+			continue
+		}
+		logDebugf("release %s: version %s from %s", r.Name, value, v)
+		hw.Releases[i].Chart.Version = value
+		vars[r.ID()] = v
+	}
+	return vars
+}
+
+// writeVersionVars sets the variables behind updated versions in their files. Like the
+// helmwave file, a file is written with writeEdited. Releases sharing a variable must move to the same version.
+func writeVersionVars(dir string, vars map[string]updater.VersionVar, updates []releaseUpdate, inplace bool) error {
+	edits := make(map[string]map[string]string)
+	env := make(map[string]bool)
+	for _, u := range updates {
+		v, ok := vars[u.ID()]
+		if !ok {
+			continue
+		}
+		path := versionVarFile(dir, v)
+		if edits[path] == nil {
+			edits[path] = make(map[string]string)
+		}
+		if prev, ok := edits[path][v.Key]; ok && prev != u.ToVersion {
+			return fmt.Errorf("%s is updated to both %s and %s", v, prev, u.ToVersion)
+		}
+		edits[path][v.Key] = u.ToVersion
+		env[path] = v.Env
+	}
+	for _, path := range sortedKeys(edits) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var out string
+		if env[path] {
+			var missing []string
+			if out, missing = updater.UpdateEnvFile(data, edits[path]); len(missing) > 0 {
+				return fmt.Errorf("%s does not set %v", path, missing)
+			}
+		} else if out, err = updater.UpdateVersionsFile(data, edits[path]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if _, err := writeEdited(path, out, inplace); err != nil {
+			return err
+		}
+	}
+	return nil
+}